@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ankur22/medium-picker/internal/backup"
+	"github.com/ankur22/medium-picker/internal/logging"
+	"github.com/ankur22/medium-picker/internal/migrator"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+var (
+	Version string
+	Commit  string
+)
+
+// command is an admin subcommand. It parses its own flags.
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"migrate": {
+		usage: "copy every user and medium source from one store to another",
+		run:   migrate,
+	},
+	"backup": {
+		usage: "write every user and medium source in a store to an archive",
+		run:   backupStore,
+	},
+	"restore": {
+		usage: "restore an archive into a store",
+		run:   restore,
+	},
+	"fsck": {
+		usage: "check a store for inconsistencies and optionally repair them",
+		run:   fsck,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	ctx, sync := logging.NewContext(ctx, zap.String("command", os.Args[1]))
+	defer func() {
+		if err := sync(); err != nil {
+			logging.Error(ctx, "Can't sync logs", zap.Error(err))
+		}
+	}()
+
+	logging.Info(ctx, "Starting medium-picker admin", zap.String("version", Version), zap.String("commit", Commit))
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := cmd.run(ctx, os.Args[2:]); err != nil {
+		logging.Error(ctx, "medium-picker admin failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
+	}
+}
+
+// migrate streams the records from one store to another and checks
+// that both hold the same records afterwards. Run it again with the
+// same checkpoint to carry on after it's been interrupted.
+func migrate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := fs.String("from", "", "dsn of the store to copy from, e.g. file:///var/lib/picker")
+	to := fs.String("to", "", "dsn of the store to copy to, e.g. postgres://...")
+	checkpoint := fs.String("checkpoint", "migrate.checkpoint", "file that the progress is kept in so that an interrupted migration can resume")
+	pageSize := fs.Int("page-size", 100, "number of medium sources that are read at a time")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		fs.Usage()
+		return fmt.Errorf("-from and -to are required")
+	}
+
+	opts := store.Options{ElemsInPage: *pageSize, FlushInterval: time.Minute}
+
+	src, err := store.Open(ctx, *from, store.Options{ElemsInPage: *pageSize, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer closeBackend(ctx, src)
+
+	dst, err := store.Open(ctx, *to, opts)
+	if err != nil {
+		return err
+	}
+	defer closeBackend(ctx, dst)
+
+	s := migrator.Store{Users: src.Users, Medium: src.Medium}
+	d := migrator.Store{Users: dst.Users, Medium: dst.Medium}
+
+	if err := migrator.NewMigrator(*checkpoint).Run(ctx, s, d); err != nil {
+		return err
+	}
+
+	sSum, dSum, err := migrator.Verify(ctx, s, d)
+	if err != nil {
+		return err
+	}
+
+	logging.Info(ctx, "Migration verified",
+		zap.Int("users", sSum.Users), zap.Int("sources", sSum.Sources),
+		zap.String("checksum", sSum.Checksum), zap.String("copiedChecksum", dSum.Checksum))
+
+	return nil
+}
+
+// backupStore writes a backup of the store to a file, in the same
+// format as the admin endpoint and the scheduled backups
+func backupStore(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dsn := fs.String("store", "", "dsn of the store to back up, e.g. file:///var/lib/picker")
+	out := fs.String("o", "", "file to write the backup to, the default is named after the time")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dsn == "" {
+		fs.Usage()
+		return fmt.Errorf("-store is required")
+	}
+	if *out == "" {
+		*out = backup.Filename(time.Now())
+	}
+
+	b, err := store.Open(ctx, *dsn, store.Options{ElemsInPage: 100, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer closeBackend(ctx, b)
+
+	m, err := backup.WriteFile(ctx, b, *out)
+	if err != nil {
+		return err
+	}
+
+	logging.Info(ctx, "Wrote backup", zap.String("filename", *out),
+		zap.Int("users", m.Users), zap.Int("sources", m.Sources))
+
+	return nil
+}
+
+// restore imports a backup into a store. The archive is checked
+// against its checksums before anything is imported.
+func restore(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dsn := fs.String("store", "", "dsn of the store to restore into, e.g. sqlite:///data/picker.db")
+	in := fs.String("i", "", "backup file to restore")
+	merge := fs.Bool("merge", false, "add the backup to a store that isn't empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dsn == "" || *in == "" {
+		fs.Usage()
+		return fmt.Errorf("-store and -i are required")
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b, err := store.Open(ctx, *dsn, store.Options{ElemsInPage: 100, FlushInterval: time.Minute})
+	if err != nil {
+		return err
+	}
+	defer closeBackend(ctx, b)
+
+	m, err := backup.Restore(ctx, f, backup.Store{Users: b.Users, Medium: b.Medium}, *merge)
+	if err != nil {
+		return err
+	}
+
+	logging.Info(ctx, "Restored backup", zap.String("filename", *in), zap.Time("createdDate", m.CreatedDate),
+		zap.Int("users", m.Users), zap.Int("sources", m.Sources))
+
+	return nil
+}
+
+// fsck reports the inconsistencies in a store, one per line. It fails
+// if any of them are left unrepaired.
+func fsck(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("fsck", flag.ExitOnError)
+	dsn := fs.String("store", "", "dsn of the store to check, e.g. file:///var/lib/picker")
+	repair := fs.Bool("repair", false, "repair the problems that are found")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dsn == "" {
+		fs.Usage()
+		return fmt.Errorf("-store is required")
+	}
+
+	b, err := store.Open(ctx, *dsn, store.Options{ElemsInPage: 100, FlushInterval: time.Minute, ReadOnly: !*repair})
+	if err != nil {
+		return err
+	}
+	defer closeBackend(ctx, b)
+
+	problems, err := b.Check(ctx, *repair)
+	if err != nil {
+		return err
+	}
+
+	unrepaired := 0
+	for _, p := range problems {
+		state := "found"
+		if p.Repaired {
+			state = "repaired"
+		} else {
+			unrepaired++
+		}
+		fmt.Printf("%s\t%s\tuser=%s\tsource=%s\t%s\n", state, p.Kind, p.UserID, p.SourceID, p.Detail)
+	}
+
+	logging.Info(ctx, "Checked store", zap.Int("problems", len(problems)), zap.Int("unrepaired", unrepaired))
+
+	if unrepaired > 0 {
+		return fmt.Errorf("%d problems haven't been repaired", unrepaired)
+	}
+
+	return nil
+}
+
+// closeBackend closes the backend on the way out. The context may
+// have been cancelled by then, but the backend still has to be saved.
+func closeBackend(ctx context.Context, b *store.Backend) {
+	if err := b.Close(context.Background()); err != nil {
+		logging.Error(ctx, "Can't close store", zap.Error(err))
+	}
+}
@@ -2,10 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/gorilla/mux"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 
+	"github.com/ankur22/medium-picker/internal/backup"
+	"github.com/ankur22/medium-picker/internal/crawler"
 	"github.com/ankur22/medium-picker/internal/logging"
+	"github.com/ankur22/medium-picker/internal/rest"
+	"github.com/ankur22/medium-picker/internal/service"
+	"github.com/ankur22/medium-picker/internal/store"
 )
 
 var (
@@ -13,7 +28,39 @@ var (
 	Commit  string
 )
 
+type config struct {
+	addr            string
+	store           string
+	flushInterval   time.Duration
+	pageSize        int
+	shutdownTimeout time.Duration
+	crawlInterval   time.Duration
+	crawlWorkers    int
+	crawlTimeout    time.Duration
+	adminAddr       string
+	backupDir       string
+	backupInterval  time.Duration
+	backupKeep      int
+	pickStrategy    string
+}
+
 func main() {
+	var cfg config
+	flag.StringVar(&cfg.addr, "addr", ":8080", "address the HTTP server listens on")
+	flag.StringVar(&cfg.store, "store", "file://.", "dsn of the store, e.g. file:///var/lib/picker, sqlite:///data/picker.db or postgres://...")
+	flag.DurationVar(&cfg.flushInterval, "flush-interval", 5*time.Second, "how often the store journals are compacted into snapshots")
+	flag.IntVar(&cfg.pageSize, "page-size", 20, "number of medium sources in a page")
+	flag.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 10*time.Second, "how long to wait for in-flight requests on shutdown")
+	flag.DurationVar(&cfg.crawlInterval, "crawl-interval", time.Hour, "how often every medium source is checked for changes")
+	flag.IntVar(&cfg.crawlWorkers, "crawl-workers", 8, "number of medium sources that are fetched concurrently")
+	flag.DurationVar(&cfg.crawlTimeout, "crawl-timeout", 30*time.Second, "how long to wait for a medium source to respond")
+	flag.StringVar(&cfg.adminAddr, "admin-addr", "localhost:8081", "address the admin HTTP server listens on, it's disabled when empty")
+	flag.StringVar(&cfg.backupDir, "backup-dir", "", "dir that backups are written to, they're disabled when empty")
+	flag.DurationVar(&cfg.backupInterval, "backup-interval", 24*time.Hour, "how often a backup is written to -backup-dir")
+	flag.IntVar(&cfg.backupKeep, "backup-keep", 7, "number of backups that are kept in -backup-dir")
+	flag.StringVar(&cfg.pickStrategy, "pick-strategy", service.DefaultStrategy, "strategy that sources are picked with when neither the request nor the user names one")
+	flag.Parse()
+
 	ctx := context.Background()
 	ctx, sync := logging.NewContext(ctx)
 	defer func() {
@@ -24,5 +71,129 @@ func main() {
 
 	logging.Info(ctx, "Starting medium-picker", zap.String("version", Version), zap.String("commit", Commit))
 
+	if err := run(ctx, cfg); err != nil {
+		logging.Error(ctx, "medium-picker failed", zap.Error(err))
+		os.Exit(1)
+	}
+
 	logging.Info(ctx, "Shutting down medium-picker")
 }
+
+// run builds the dependency graph and blocks until a SIGINT or SIGTERM
+// is received, or one of the background jobs fails
+func run(ctx context.Context, cfg config) error {
+	strategy, err := service.LookupStrategy(cfg.pickStrategy)
+	if err != nil {
+		return err
+	}
+
+	b, err := store.Open(ctx, cfg.store, store.Options{
+		ElemsInPage:   cfg.pageSize,
+		FlushInterval: cfg.flushInterval,
+	})
+	if err != nil {
+		return err
+	}
+	u, m := b.Users, b.Medium
+
+	var bs *backup.Scheduler
+	if cfg.backupDir != "" {
+		if bs, err = backup.NewScheduler(b, cfg.backupDir, cfg.backupInterval, cfg.backupKeep); err != nil {
+			_ = b.Close(ctx)
+			return err
+		}
+	}
+
+	c := crawler.NewCrawler(m, &http.Client{Timeout: cfg.crawlTimeout}, cfg.crawlInterval, cfg.crawlWorkers)
+	p := service.NewPicker(m, service.WithStrategy(strategy))
+	l := service.NewLearner(m)
+	h := rest.NewHandler(u, c.WatchAdds(m), p, l)
+
+	r := mux.NewRouter()
+	h.Add(r)
+
+	srv := &http.Server{
+		Addr:    cfg.addr,
+		Handler: r,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	// The admin endpoints can read every user's data, so they are
+	// served separately from the API, by default only on localhost
+	ar := mux.NewRouter()
+	rest.NewAdminHandler(b).Add(ar)
+
+	adminSrv := &http.Server{
+		Addr:    cfg.adminAddr,
+		Handler: ar,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	g, gCtx := errgroup.WithContext(sigCtx)
+
+	g.Go(func() error {
+		return ignoreCanceled(b.Start(gCtx))
+	})
+	g.Go(func() error {
+		return ignoreCanceled(c.Start(gCtx))
+	})
+	if bs != nil {
+		g.Go(func() error {
+			return ignoreCanceled(bs.Start(gCtx))
+		})
+	}
+	if cfg.adminAddr != "" {
+		g.Go(func() error {
+			logging.Info(ctx, "Admin HTTP server listening", zap.String("addr", cfg.adminAddr))
+			if err := adminSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		logging.Info(ctx, "HTTP server listening", zap.String("addr", cfg.addr))
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+	g.Go(func() error {
+		<-gCtx.Done()
+
+		logging.Info(ctx, "Draining in-flight requests")
+
+		shutdownCtx, cancel := context.WithTimeout(ctx, cfg.shutdownTimeout)
+		defer cancel()
+
+		err := srv.Shutdown(shutdownCtx)
+		if aerr := adminSrv.Shutdown(shutdownCtx); aerr != nil && err == nil {
+			err = aerr
+		}
+		return err
+	})
+
+	err = g.Wait()
+
+	// The store is closed once the HTTP servers have drained and the
+	// crawler and backups have stopped, so that the final save includes
+	// everything they wrote and nothing writes to a closed store
+	if cerr := b.Close(ctx); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func ignoreCanceled(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
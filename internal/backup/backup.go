@@ -0,0 +1,276 @@
+//go:generate mockgen -destination=mock_backup.go -package=backup github.com/ankur22/medium-picker/internal/backup Dumper,UserStorer,MediumSourceStorer
+
+// Package backup writes every user and medium source in a store to a
+// single archive, and restores the archive into any store backend.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/ankur22/medium-picker/internal/err"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+const (
+	ErrCannotDumpStore          = err.Const("cannot dump store")
+	ErrCannotWriteBackup        = err.Const("cannot write backup")
+	ErrCannotReadBackup         = err.Const("cannot read backup")
+	ErrUnsupportedBackupVersion = err.Const("backup was written by a newer version")
+	ErrChecksumMismatch         = err.Const("backup checksum doesn't match")
+	ErrStoreNotEmpty            = err.Const("store isn't empty")
+	ErrCannotRestore            = err.Const("cannot restore backup")
+)
+
+// The files in the archive. The manifest is always the first one.
+const (
+	manifestFile = "manifest.json"
+	usersFile    = "users.json"
+	sourcesFile  = "sources.json"
+)
+
+// version is the version of the archive format that is written
+const version = 1
+
+// Dumper interface to read every user and source at one point in time
+type Dumper interface {
+	Dump(ctx context.Context) (*store.Dump, error)
+}
+
+// UserStorer interface to check for and import users
+type UserStorer interface {
+	ListUsers(ctx context.Context) ([]store.User, error)
+	ImportUser(ctx context.Context, user store.User) error
+}
+
+// MediumSourceStorer interface to check for and import medium sources
+type MediumSourceStorer interface {
+	GetUserIDs(ctx context.Context) ([]string, error)
+	ImportSource(ctx context.Context, source store.Medium) error
+}
+
+// Store is a user and a medium store that a backup is restored into
+type Store struct {
+	Users  UserStorer
+	Medium MediumSourceStorer
+}
+
+// Manifest describes what is in a backup
+type Manifest struct {
+	Version     int       `json:"version"`
+	CreatedDate time.Time `json:"created_date"`
+	Users       int       `json:"users"`
+	Sources     int       `json:"sources"`
+	// Checksums are the sha256 of every other file in the archive
+	Checksums map[string]string `json:"checksums"`
+}
+
+// Write dumps the store and writes it to w as a gzipped tar archive
+func Write(ctx context.Context, d Dumper, w io.Writer) (Manifest, error) {
+	dump, err := d.Dump(ctx)
+	if err != nil {
+		return Manifest{}, ErrCannotDumpStore.Wrap(err)
+	}
+
+	users, err := json.Marshal(dump.Users)
+	if err != nil {
+		return Manifest{}, ErrCannotWriteBackup.Wrap(err)
+	}
+	sources, err := json.Marshal(dump.Sources)
+	if err != nil {
+		return Manifest{}, ErrCannotWriteBackup.Wrap(err)
+	}
+
+	m := Manifest{
+		Version:     version,
+		CreatedDate: time.Now().UTC(),
+		Users:       len(dump.Users),
+		Sources:     len(dump.Sources),
+		Checksums: map[string]string{
+			usersFile:   checksum(users),
+			sourcesFile: checksum(sources),
+		},
+	}
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		return Manifest{}, ErrCannotWriteBackup.Wrap(err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{name: manifestFile, data: manifest},
+		{name: usersFile, data: users},
+		{name: sourcesFile, data: sources},
+	} {
+		hdr := &tar.Header{
+			Name:    f.name,
+			Mode:    0600,
+			Size:    int64(len(f.data)),
+			ModTime: m.CreatedDate,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return Manifest{}, ErrCannotWriteBackup.Wrap(err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return Manifest{}, ErrCannotWriteBackup.Wrap(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return Manifest{}, ErrCannotWriteBackup.Wrap(err)
+	}
+	if err := gz.Close(); err != nil {
+		return Manifest{}, ErrCannotWriteBackup.Wrap(err)
+	}
+
+	return m, nil
+}
+
+// Read reads an archive that was written by Write. Every file is
+// checked against its checksum before anything is returned.
+func Read(r io.Reader) (*store.Dump, Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, Manifest{}, ErrCannotReadBackup.Wrap(err)
+	}
+	defer gz.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, Manifest{}, ErrCannotReadBackup.Wrap(err)
+		}
+
+		bb, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, Manifest{}, ErrCannotReadBackup.Wrap(err)
+		}
+		files[hdr.Name] = bb
+	}
+
+	var m Manifest
+	bb, ok := files[manifestFile]
+	if !ok {
+		return nil, Manifest{}, ErrCannotReadBackup.Wrap(fmt.Errorf("no %s", manifestFile))
+	}
+	if err := json.Unmarshal(bb, &m); err != nil {
+		return nil, Manifest{}, ErrCannotReadBackup.Wrap(err)
+	}
+	if m.Version > version {
+		return nil, m, ErrUnsupportedBackupVersion.Wrap(fmt.Errorf("version %d, this build reads up to version %d", m.Version, version))
+	}
+
+	for _, name := range []string{usersFile, sourcesFile} {
+		bb, ok := files[name]
+		if !ok {
+			return nil, m, ErrCannotReadBackup.Wrap(fmt.Errorf("no %s", name))
+		}
+		if got := checksum(bb); got != m.Checksums[name] {
+			return nil, m, ErrChecksumMismatch.Wrap(fmt.Errorf("%s is %s, expected %s", name, got, m.Checksums[name]))
+		}
+	}
+
+	dump := &store.Dump{}
+	if err := json.Unmarshal(files[usersFile], &dump.Users); err != nil {
+		return nil, m, ErrCannotReadBackup.Wrap(err)
+	}
+	if err := json.Unmarshal(files[sourcesFile], &dump.Sources); err != nil {
+		return nil, m, ErrCannotReadBackup.Wrap(err)
+	}
+	if len(dump.Users) != m.Users || len(dump.Sources) != m.Sources {
+		return nil, m, ErrChecksumMismatch.Wrap(fmt.Errorf("%d users and %d sources, expected %d and %d",
+			len(dump.Users), len(dump.Sources), m.Users, m.Sources))
+	}
+
+	return dump, m, nil
+}
+
+// Restore reads the archive and imports it into dst. The store has to
+// be empty unless merge is set, in which case the backup is added to
+// what is already there. The records keep their ids, dates and counters.
+func Restore(ctx context.Context, r io.Reader, dst Store, merge bool) (Manifest, error) {
+	dump, m, err := Read(r)
+	if err != nil {
+		return m, err
+	}
+
+	if !merge {
+		users, err := dst.Users.ListUsers(ctx)
+		if err != nil {
+			return m, ErrCannotRestore.Wrap(err)
+		}
+		userIDs, err := dst.Medium.GetUserIDs(ctx)
+		if err != nil {
+			return m, ErrCannotRestore.Wrap(err)
+		}
+		if len(users) > 0 || len(userIDs) > 0 {
+			return m, ErrStoreNotEmpty
+		}
+	}
+
+	for _, u := range dump.Users {
+		if err := dst.Users.ImportUser(ctx, u); err != nil {
+			return m, ErrCannotRestore.Wrap(fmt.Errorf("user %s: %w", u.ID, err))
+		}
+	}
+	for _, s := range dump.Sources {
+		if err := dst.Medium.ImportSource(ctx, s); err != nil {
+			return m, ErrCannotRestore.Wrap(fmt.Errorf("source %s: %w", s.ID, err))
+		}
+	}
+
+	return m, nil
+}
+
+// WriteFile writes the backup to a file. The archive is written
+// to a temp file first, so a failed backup never looks like a
+// complete one.
+func WriteFile(ctx context.Context, d Dumper, filename string) (Manifest, error) {
+	tmp := filename + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return Manifest{}, ErrCannotWriteBackup.Wrap(err)
+	}
+	defer os.Remove(tmp)
+
+	m, err := Write(ctx, d, f)
+	if err != nil {
+		_ = f.Close()
+		return m, err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return m, ErrCannotWriteBackup.Wrap(err)
+	}
+	if err := f.Close(); err != nil {
+		return m, ErrCannotWriteBackup.Wrap(err)
+	}
+
+	if err := os.Rename(tmp, filename); err != nil {
+		return m, ErrCannotWriteBackup.Wrap(err)
+	}
+
+	return m, nil
+}
+
+func checksum(bb []byte) string {
+	h := sha256.Sum256(bb)
+	return hex.EncodeToString(h[:])
+}
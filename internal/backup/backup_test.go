@@ -0,0 +1,216 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/backup"
+	"github.com/ankur22/medium-picker/internal/logging"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+func TestMain(m *testing.M) {
+	_, _ = logging.TestContext(context.Background())
+	os.Exit(m.Run())
+}
+
+var dump = &store.Dump{
+	Users: []store.User{
+		{ID: "some-user-id", Email: "test@example.com"},
+		{ID: "another-user-id", Email: "another@example.com"},
+	},
+	Sources: []store.Medium{
+		{
+			ID:           "some-id",
+			URL:          "google.com",
+			UserID:       "some-user-id",
+			Hash:         "some-hash",
+			Hit:          3,
+			Multiplier:   1.5,
+			CreatedDate:  time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC),
+			ModifiedDate: time.Date(2020, 10, 2, 12, 0, 0, 0, time.UTC),
+		},
+	},
+}
+
+func TestWrite_Read(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	d := backup.NewMockDumper(ctrl)
+	d.EXPECT().Dump(gomock.Any()).Return(dump, nil)
+
+	var buf bytes.Buffer
+	m, err := backup.Write(context.Background(), d, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, 1, m.Version)
+	assert.Equal(t, 2, m.Users)
+	assert.Equal(t, 1, m.Sources)
+
+	got, gotM, err := backup.Read(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, dump, got)
+	assert.Equal(t, m.Checksums, gotM.Checksums)
+	assert.True(t, m.CreatedDate.Equal(gotM.CreatedDate))
+}
+
+func TestWrite_Failure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	d := backup.NewMockDumper(ctrl)
+	d.EXPECT().Dump(gomock.Any()).Return(nil, errors.New("some error"))
+
+	var buf bytes.Buffer
+	_, err := backup.Write(context.Background(), d, &buf)
+	assert.True(t, errors.Is(err, backup.ErrCannotDumpStore), err)
+	assert.Zero(t, buf.Len())
+}
+
+func TestRead_Failure(t *testing.T) {
+	const (
+		users   = `[{"id":"some-user-id","email":"test@example.com"}]`
+		sources = `[]`
+	)
+
+	tests := []struct {
+		name    string
+		archive func(t *testing.T) []byte
+		wantErr error
+	}{
+		{
+			name: "Not an archive",
+			archive: func(t *testing.T) []byte {
+				return []byte("some data")
+			},
+			wantErr: backup.ErrCannotReadBackup,
+		},
+		{
+			name: "No manifest",
+			archive: func(t *testing.T) []byte {
+				return archive(t, map[string]string{"users.json": users, "sources.json": sources})
+			},
+			wantErr: backup.ErrCannotReadBackup,
+		},
+		{
+			name: "Newer version",
+			archive: func(t *testing.T) []byte {
+				return archive(t, map[string]string{
+					"manifest.json": `{"version":2}`,
+				})
+			},
+			wantErr: backup.ErrUnsupportedBackupVersion,
+		},
+		{
+			name: "Checksum mismatch",
+			archive: func(t *testing.T) []byte {
+				return archive(t, map[string]string{
+					"manifest.json": `{"version":1,"users":1,"checksums":{"users.json":"some-checksum","sources.json":"another-checksum"}}`,
+					"users.json":    `[{"id":"another-user-id","email":"test@example.com"}]`,
+					"sources.json":  sources,
+				})
+			},
+			wantErr: backup.ErrChecksumMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := backup.Read(bytes.NewReader(tt.archive(t)))
+			assert.True(t, errors.Is(err, tt.wantErr), err)
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	for _, scheme := range []string{"file", "sqlite"} {
+		t.Run(scheme, func(t *testing.T) {
+			ctx := context.Background()
+
+			src := openBackend(t, "file")
+			uid, err := src.Users.CreateNewUser(ctx, "test@example.com")
+			require.NoError(t, err)
+			require.NoError(t, src.Medium.AddSource(ctx, uid, "google.com"))
+			require.NoError(t, src.Medium.AddSource(ctx, uid, "bing.com"))
+			_, err = src.Users.CreateNewUser(ctx, "another@example.com")
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			_, err = backup.Write(ctx, src, &buf)
+			require.NoError(t, err)
+
+			dst := openBackend(t, scheme)
+			m, err := backup.Restore(ctx, bytes.NewReader(buf.Bytes()), toStore(dst), false)
+			require.NoError(t, err)
+			assert.Equal(t, 2, m.Users)
+			assert.Equal(t, 2, m.Sources)
+
+			want, err := src.Dump(ctx)
+			require.NoError(t, err)
+			got, err := dst.Dump(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, want.Users, got.Users)
+			require.Len(t, got.Sources, len(want.Sources))
+			for i := range want.Sources {
+				assert.Equal(t, want.Sources[i].ID, got.Sources[i].ID)
+				assert.Equal(t, want.Sources[i].URL, got.Sources[i].URL)
+				assert.True(t, want.Sources[i].CreatedDate.Equal(got.Sources[i].CreatedDate))
+			}
+
+			// The store isn't empty any more
+			_, err = backup.Restore(ctx, bytes.NewReader(buf.Bytes()), toStore(dst), false)
+			assert.True(t, errors.Is(err, backup.ErrStoreNotEmpty), err)
+
+			// Unless the backup is merged into it, which is
+			// harmless as the records are already there
+			_, err = backup.Restore(ctx, bytes.NewReader(buf.Bytes()), toStore(dst), true)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func openBackend(t *testing.T, scheme string) *store.Backend {
+	t.Helper()
+
+	b, err := store.Open(context.Background(), scheme+"://"+filepath.ToSlash(filepath.Join(t.TempDir(), "picker")), store.Options{ElemsInPage: 10, FlushInterval: time.Hour})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, b.Close(context.Background()))
+	})
+
+	return b
+}
+
+func toStore(b *store.Backend) backup.Store {
+	return backup.Store{Users: b.Users, Medium: b.Medium}
+}
+
+// archive writes the files to a gzipped tar, so that
+// archives that Write wouldn't write can be read
+func archive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data))}))
+		_, err := tw.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	return buf.Bytes()
+}
@@ -0,0 +1,154 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/ankur22/medium-picker/internal/backup (interfaces: Dumper,UserStorer,MediumSourceStorer)
+
+// Package backup is a generated GoMock package.
+package backup
+
+import (
+	context "context"
+	store "github.com/ankur22/medium-picker/internal/store"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockDumper is a mock of Dumper interface
+type MockDumper struct {
+	ctrl     *gomock.Controller
+	recorder *MockDumperMockRecorder
+}
+
+// MockDumperMockRecorder is the mock recorder for MockDumper
+type MockDumperMockRecorder struct {
+	mock *MockDumper
+}
+
+// NewMockDumper creates a new mock instance
+func NewMockDumper(ctrl *gomock.Controller) *MockDumper {
+	mock := &MockDumper{ctrl: ctrl}
+	mock.recorder = &MockDumperMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockDumper) EXPECT() *MockDumperMockRecorder {
+	return m.recorder
+}
+
+// Dump mocks base method
+func (m *MockDumper) Dump(arg0 context.Context) (*store.Dump, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dump", arg0)
+	ret0, _ := ret[0].(*store.Dump)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Dump indicates an expected call of Dump
+func (mr *MockDumperMockRecorder) Dump(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dump", reflect.TypeOf((*MockDumper)(nil).Dump), arg0)
+}
+
+// MockUserStorer is a mock of UserStorer interface
+type MockUserStorer struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserStorerMockRecorder
+}
+
+// MockUserStorerMockRecorder is the mock recorder for MockUserStorer
+type MockUserStorerMockRecorder struct {
+	mock *MockUserStorer
+}
+
+// NewMockUserStorer creates a new mock instance
+func NewMockUserStorer(ctrl *gomock.Controller) *MockUserStorer {
+	mock := &MockUserStorer{ctrl: ctrl}
+	mock.recorder = &MockUserStorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockUserStorer) EXPECT() *MockUserStorerMockRecorder {
+	return m.recorder
+}
+
+// ImportUser mocks base method
+func (m *MockUserStorer) ImportUser(arg0 context.Context, arg1 store.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportUser", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ImportUser indicates an expected call of ImportUser
+func (mr *MockUserStorerMockRecorder) ImportUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportUser", reflect.TypeOf((*MockUserStorer)(nil).ImportUser), arg0, arg1)
+}
+
+// ListUsers mocks base method
+func (m *MockUserStorer) ListUsers(arg0 context.Context) ([]store.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", arg0)
+	ret0, _ := ret[0].([]store.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers
+func (mr *MockUserStorerMockRecorder) ListUsers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockUserStorer)(nil).ListUsers), arg0)
+}
+
+// MockMediumSourceStorer is a mock of MediumSourceStorer interface
+type MockMediumSourceStorer struct {
+	ctrl     *gomock.Controller
+	recorder *MockMediumSourceStorerMockRecorder
+}
+
+// MockMediumSourceStorerMockRecorder is the mock recorder for MockMediumSourceStorer
+type MockMediumSourceStorerMockRecorder struct {
+	mock *MockMediumSourceStorer
+}
+
+// NewMockMediumSourceStorer creates a new mock instance
+func NewMockMediumSourceStorer(ctrl *gomock.Controller) *MockMediumSourceStorer {
+	mock := &MockMediumSourceStorer{ctrl: ctrl}
+	mock.recorder = &MockMediumSourceStorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockMediumSourceStorer) EXPECT() *MockMediumSourceStorerMockRecorder {
+	return m.recorder
+}
+
+// GetUserIDs mocks base method
+func (m *MockMediumSourceStorer) GetUserIDs(arg0 context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserIDs", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserIDs indicates an expected call of GetUserIDs
+func (mr *MockMediumSourceStorerMockRecorder) GetUserIDs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserIDs", reflect.TypeOf((*MockMediumSourceStorer)(nil).GetUserIDs), arg0)
+}
+
+// ImportSource mocks base method
+func (m *MockMediumSourceStorer) ImportSource(arg0 context.Context, arg1 store.Medium) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportSource", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ImportSource indicates an expected call of ImportSource
+func (mr *MockMediumSourceStorerMockRecorder) ImportSource(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportSource", reflect.TypeOf((*MockMediumSourceStorer)(nil).ImportSource), arg0, arg1)
+}
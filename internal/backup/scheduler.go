@@ -0,0 +1,131 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ankur22/medium-picker/internal/err"
+	"github.com/ankur22/medium-picker/internal/logging"
+)
+
+const (
+	ErrCannotRotateBackups = err.Const("cannot rotate backups")
+	ErrKeepSmallerThanOne  = err.Const("number of backups to keep is smaller than 1")
+)
+
+// The backups that the scheduler writes are named after when they
+// were taken, so that sorting the names sorts them by age
+const (
+	filePrefix = "picker-"
+	fileSuffix = ".tar.gz"
+	timeFormat = "20060102T150405Z"
+)
+
+// Filename is the name of a backup that was taken at t
+func Filename(t time.Time) string {
+	return filePrefix + t.UTC().Format(timeFormat) + fileSuffix
+}
+
+// Scheduler periodically writes a backup to a dir and
+// removes the oldest ones, keeping the newest few
+type Scheduler struct {
+	d        Dumper
+	dir      string
+	interval time.Duration
+	keep     int
+	now      func() time.Time
+}
+
+// NewScheduler creates a new Scheduler that writes a backup to dir every
+// interval and keeps the newest keep backups. At least one has to be kept,
+// or the backup that has just been written would be removed.
+func NewScheduler(d Dumper, dir string, interval time.Duration, keep int) (*Scheduler, error) {
+	if keep < 1 {
+		return nil, ErrKeepSmallerThanOne
+	}
+
+	return &Scheduler{
+		d:        d,
+		dir:      dir,
+		interval: interval,
+		keep:     keep,
+		now:      time.Now,
+	}, nil
+}
+
+// Start writes a backup every interval until ctx is cancelled.
+// Failed backups are logged and tried again on the next tick.
+func (s *Scheduler) Start(ctx context.Context) error {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			if _, err := s.Backup(ctx); err != nil {
+				logging.Error(ctx, "Backup failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Backup writes a backup to the dir now and then removes the
+// backups that are older than the newest keep. It returns the
+// name of the file that was written.
+func (s *Scheduler) Backup(ctx context.Context) (string, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", ErrCannotWriteBackup.Wrap(err)
+	}
+
+	filename := filepath.Join(s.dir, Filename(s.now()))
+
+	m, err := WriteFile(ctx, s.d, filename)
+	if err != nil {
+		return "", err
+	}
+
+	logging.Info(ctx, "Wrote backup", zap.String("filename", filename),
+		zap.Int("users", m.Users), zap.Int("sources", m.Sources))
+
+	if err := s.rotate(ctx); err != nil {
+		return filename, err
+	}
+
+	return filename, nil
+}
+
+// rotate removes all but the newest keep backups
+func (s *Scheduler) rotate(ctx context.Context) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return ErrCannotRotateBackups.Wrap(err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), filePrefix) && strings.HasSuffix(e.Name(), fileSuffix) {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) <= s.keep {
+		return nil
+	}
+	sort.Strings(names)
+
+	for _, name := range names[:len(names)-s.keep] {
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+			return ErrCannotRotateBackups.Wrap(err)
+		}
+		logging.Info(ctx, "Removed old backup", zap.String("filename", name))
+	}
+
+	return nil
+}
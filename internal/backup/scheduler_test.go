@@ -0,0 +1,114 @@
+package backup_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/backup"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+func TestNewScheduler(t *testing.T) {
+	tests := []struct {
+		name    string
+		keep    int
+		wantErr error
+	}{
+		{
+			name: "keeps one",
+			keep: 1,
+		},
+		{
+			name:    "keeps none",
+			keep:    0,
+			wantErr: backup.ErrKeepSmallerThanOne,
+		},
+		{
+			name:    "negative",
+			keep:    -1,
+			wantErr: backup.ErrKeepSmallerThanOne,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s, err := backup.NewScheduler(backup.NewMockDumper(ctrl), t.TempDir(), time.Hour, tt.keep)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), err)
+				assert.Nil(t, s)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, s)
+		})
+	}
+}
+
+func TestScheduler_Backup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	d := backup.NewMockDumper(ctrl)
+	d.EXPECT().Dump(gomock.Any()).Return(dump, nil)
+
+	dir := t.TempDir()
+	old := []string{
+		backup.Filename(time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)),
+		backup.Filename(time.Date(2020, 10, 2, 12, 0, 0, 0, time.UTC)),
+		backup.Filename(time.Date(2020, 10, 3, 12, 0, 0, 0, time.UTC)),
+	}
+	for _, name := range append(old, "some-other-file.txt") {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("some data"), 0600))
+	}
+
+	s, err := backup.NewScheduler(d, dir, time.Hour, 2)
+	require.NoError(t, err)
+	filename, err := s.Backup(context.Background())
+	require.NoError(t, err)
+
+	f, err := os.Open(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	got, _, err := backup.Read(f)
+	require.NoError(t, err)
+	assert.Equal(t, dump, got)
+
+	// The newest two backups are kept, and
+	// files that aren't backups are left alone
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{old[2], filepath.Base(filename), "some-other-file.txt"}, names)
+}
+
+func TestScheduler_Start(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := backup.NewMockDumper(ctrl)
+	d.EXPECT().Dump(gomock.Any()).DoAndReturn(func(context.Context) (*store.Dump, error) {
+		cancel()
+		return dump, nil
+	}).MinTimes(1)
+
+	s, err := backup.NewScheduler(d, t.TempDir(), time.Millisecond, 2)
+	require.NoError(t, err)
+	err = s.Start(ctx)
+	assert.True(t, errors.Is(err, context.Canceled), err)
+}
@@ -0,0 +1,360 @@
+//go:generate mockgen -destination=mock_crawler.go -package=crawler github.com/ankur22/medium-picker/internal/crawler MediumSourceStorer
+
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ankur22/medium-picker/internal/err"
+	"github.com/ankur22/medium-picker/internal/feed"
+	"github.com/ankur22/medium-picker/internal/fingerprint"
+	"github.com/ankur22/medium-picker/internal/logging"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+const (
+	ErrFailedGetUserIDs    = err.Const("failed to retrieve user ids")
+	ErrFailedGetAllSources = err.Const("failed to retrieve all records")
+	ErrCannotFingerprint   = err.Const("cannot fingerprint source")
+	ErrCannotParseFeed     = err.Const("cannot parse feed")
+)
+
+// MediumSourceStorer interface to retrieve and update medium sources
+type MediumSourceStorer interface {
+	GetUserIDs(ctx context.Context) ([]string, error)
+	GetAllSourceData(ctx context.Context, userID string, cursor string) ([]store.Medium, string, error)
+	ModifySource(ctx context.Context, userID string, sourceID string, fn func(source *store.Medium) error) error
+}
+
+// Crawler periodically fetches every medium source and keeps
+// the Hash and ModifiedDate up to date when the content changes.
+// Sources that advertise a feed are followed through their feed
+// instead of their page.
+type Crawler struct {
+	store     MediumSourceStorer
+	fetcher   *fetcher
+	ticker    time.Duration
+	workers   int
+	threshold int
+	added     chan string
+}
+
+// NewCrawler will create a new instance of Crawler
+// workers is the number of sites that are fetched concurrently
+func NewCrawler(store MediumSourceStorer, client HTTPClient, ticker time.Duration, workers int) *Crawler {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Crawler{
+		store:     store,
+		fetcher:   newFetcher(client),
+		ticker:    ticker,
+		workers:   workers,
+		threshold: fingerprint.DefaultThreshold,
+		added:     make(chan string, 100),
+	}
+}
+
+// Start will crawl all the sources straight away and then
+// again on every tick until ctx is cancelled. Sources that are
+// added through WatchAdds are crawled as soon as they are added.
+func (c *Crawler) Start(ctx context.Context) error {
+	t := time.NewTicker(c.ticker)
+	defer t.Stop()
+
+	if err := c.Crawl(ctx); err != nil {
+		logging.Error(ctx, "crawl failed", zap.Error(err))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			if err := c.Crawl(ctx); err != nil {
+				logging.Error(ctx, "crawl failed", zap.Error(err))
+			}
+		case userID := <-c.added:
+			if err := c.crawlNew(ctx, userID); err != nil {
+				logging.Error(ctx, "crawl of new sources failed", zap.Error(err), zap.String("userId", userID))
+			}
+		}
+	}
+}
+
+// Crawl fetches every source once. Failures to fetch a single
+// source are logged and don't stop the rest of the crawl.
+func (c *Crawler) Crawl(ctx context.Context) error {
+	ids, err := c.store.GetUserIDs(ctx)
+	if err != nil {
+		return ErrFailedGetUserIDs.Wrap(err)
+	}
+
+	var all []store.Medium
+	for _, id := range ids {
+		sources, err := c.allSources(ctx, id)
+		if err != nil {
+			return err
+		}
+		all = append(all, sources...)
+	}
+
+	logging.Info(ctx, "Crawling medium sources", zap.Int("count", len(all)))
+
+	c.crawl(ctx, all)
+
+	return nil
+}
+
+// crawlNew fetches the sources of the user that have never been crawled
+func (c *Crawler) crawlNew(ctx context.Context, userID string) error {
+	sources, err := c.allSources(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var fresh []store.Medium
+	for _, m := range sources {
+		if m.Hash == "" && m.FeedURL == "" {
+			fresh = append(fresh, m)
+		}
+	}
+
+	c.crawl(ctx, fresh)
+
+	return nil
+}
+
+func (c *Crawler) crawl(ctx context.Context, sources []store.Medium) {
+	work := make(chan store.Medium)
+
+	var wg sync.WaitGroup
+	for i := 0; i < c.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range work {
+				c.check(ctx, m)
+			}
+		}()
+	}
+
+	for _, m := range sources {
+		select {
+		case work <- m:
+		case <-ctx.Done():
+		}
+	}
+	close(work)
+
+	wg.Wait()
+}
+
+func (c *Crawler) check(ctx context.Context, m store.Medium) {
+	ctx = logging.With(ctx, zap.String("userId", m.UserID), zap.String("sourceID", m.ID), zap.String("url", m.URL))
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	var updated store.Medium
+	var err error
+	if m.FeedURL != "" {
+		updated, err = c.checkFeed(ctx, m)
+		if err != nil {
+			// The feed may have moved, so look for it on the page again
+			logging.Error(ctx, "Failed to fetch feed", zap.Error(err), zap.String("feedURL", m.FeedURL))
+			retry := m
+			retry.FeedURL = ""
+			retry.ETag = ""
+			retry.LastModified = ""
+			updated, err = c.checkPage(ctx, retry)
+		}
+	} else {
+		updated, err = c.checkPage(ctx, m)
+	}
+	if err != nil {
+		logging.Error(ctx, "Failed to fetch medium source", zap.Error(err))
+		return
+	}
+
+	if updated == m {
+		logging.Info(ctx, "Medium source unchanged")
+		return
+	}
+
+	// The fetch can take a while, so only what was crawled is written, to
+	// the source as it is now, rather than undoing the picks and feedback
+	// that were made in the meantime
+	err = c.store.ModifySource(ctx, m.UserID, m.ID, func(source *store.Medium) error {
+		crawled(source, updated)
+		return nil
+	})
+	if err != nil {
+		logging.Error(ctx, "Failed to update medium source", zap.Error(err))
+		return
+	}
+
+	if updated.ModifiedDate != m.ModifiedDate {
+		logging.Info(ctx, "Medium source changed")
+	} else if updated.Hash != m.Hash {
+		logging.Info(ctx, "Medium source had a minor edit")
+	}
+}
+
+// crawled copies the fields that a crawl sets from updated to source
+func crawled(source *store.Medium, updated store.Medium) {
+	source.Hash = updated.Hash
+	source.Simhash = updated.Simhash
+	source.ModifiedDate = updated.ModifiedDate
+	source.ETag = updated.ETag
+	source.LastModified = updated.LastModified
+	source.FeedURL = updated.FeedURL
+	source.LatestItemID = updated.LatestItemID
+	source.LatestItemTitle = updated.LatestItemTitle
+	source.LatestItemLink = updated.LatestItemLink
+	source.LatestItemDate = updated.LatestItemDate
+}
+
+// checkPage fetches the source's page. When the page advertises a
+// feed, or is a feed itself, the feed is used from then on.
+func (c *Crawler) checkPage(ctx context.Context, m store.Medium) (store.Medium, error) {
+	resp, err := c.fetcher.fetch(ctx, m.URL, m.ETag, m.LastModified)
+	if err != nil {
+		return m, err
+	}
+
+	if resp.notModified {
+		return m, nil
+	}
+
+	if resp.isFeed() {
+		if f, err := feed.Parse(bytes.NewReader(resp.body)); err == nil {
+			m.FeedURL = m.URL
+			return c.applyFeed(m, f, resp), nil
+		}
+	}
+
+	if resp.isHTML() {
+		links, err := feed.Discover(bytes.NewReader(resp.body), resp.url)
+		if err != nil {
+			logging.Error(ctx, "Failed to look for feeds", zap.Error(err))
+		}
+
+		for _, l := range links {
+			fm := m
+			fm.FeedURL = l
+			fm.ETag = ""
+			fm.LastModified = ""
+
+			updated, err := c.checkFeed(ctx, fm)
+			if err != nil {
+				logging.Error(ctx, "Failed to fetch discovered feed", zap.Error(err), zap.String("feedURL", l))
+				continue
+			}
+
+			logging.Info(ctx, "Discovered feed", zap.String("feedURL", l))
+			return updated, nil
+		}
+	}
+
+	return c.applyPage(m, resp)
+}
+
+// applyPage updates the hash when the text has changed, but only edits
+// that move the simhash far enough from the one recorded at the last
+// meaningful change bump the ModifiedDate. Small edits add up until they do.
+func (c *Crawler) applyPage(m store.Medium, resp response) (store.Medium, error) {
+	var fp fingerprint.Fingerprint
+	var err error
+	if resp.isHTML() {
+		fp, err = fingerprint.FromHTML(bytes.NewReader(resp.body))
+	} else {
+		fp, err = fingerprint.FromReader(bytes.NewReader(resp.body))
+	}
+	if err != nil {
+		return m, ErrCannotFingerprint.Wrap(err)
+	}
+
+	// a page that has never been crawled has nothing to compare with
+	prev := fingerprint.Fingerprint{Hash: m.Hash, Simhash: m.Simhash}
+	changed := m.Hash == "" || fingerprint.Changed(prev, fp, c.threshold)
+
+	m.ETag = resp.etag
+	m.LastModified = resp.lastModified
+	m.Hash = fp.Hash
+	if changed {
+		m.Simhash = fp.Simhash
+		m.ModifiedDate = time.Now().UTC()
+	}
+
+	return m, nil
+}
+
+// checkFeed fetches the source's feed
+func (c *Crawler) checkFeed(ctx context.Context, m store.Medium) (store.Medium, error) {
+	resp, err := c.fetcher.fetch(ctx, m.FeedURL, m.ETag, m.LastModified)
+	if err != nil {
+		return m, err
+	}
+
+	if resp.notModified {
+		return m, nil
+	}
+
+	f, err := feed.Parse(bytes.NewReader(resp.body))
+	if err != nil {
+		return m, ErrCannotParseFeed.Wrap(err)
+	}
+
+	return c.applyFeed(m, f, resp), nil
+}
+
+// applyFeed records the newest item in the feed. The source has only
+// changed when the newest item is a different one to last time.
+func (c *Crawler) applyFeed(m store.Medium, f *feed.Feed, resp response) store.Medium {
+	m.ETag = resp.etag
+	m.LastModified = resp.lastModified
+
+	item, ok := f.Latest()
+	if !ok || item.ID == m.LatestItemID {
+		return m
+	}
+
+	m.LatestItemID = item.ID
+	m.LatestItemTitle = item.Title
+	m.LatestItemLink = item.Link
+	m.LatestItemDate = item.Published
+	m.Hash = fingerprint.FromText(item.ID).Hash
+	m.Simhash = 0
+	m.ModifiedDate = time.Now().UTC()
+	if !item.Published.IsZero() {
+		m.ModifiedDate = item.Published
+	}
+
+	return m
+}
+
+func (c *Crawler) allSources(ctx context.Context, userID string) ([]store.Medium, error) {
+	var all []store.Medium
+	var cursor string
+	for {
+		ss, next, err := c.store.GetAllSourceData(ctx, userID, cursor)
+		if err != nil {
+			return nil, ErrFailedGetAllSources.Wrap(err)
+		}
+		all = append(all, ss...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	return all, nil
+}
@@ -0,0 +1,440 @@
+package crawler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ankur22/medium-picker/internal/crawler"
+	"github.com/ankur22/medium-picker/internal/fingerprint"
+	"github.com/ankur22/medium-picker/internal/logging"
+	"github.com/ankur22/medium-picker/internal/rest"
+	"github.com/ankur22/medium-picker/internal/service"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+// modify makes a ModifySource that changes current and checks the result
+func modify(current store.Medium, check func(source store.Medium)) func(ctx context.Context, userID string, sourceID string, fn func(source *store.Medium) error) error {
+	return func(ctx context.Context, userID string, sourceID string, fn func(source *store.Medium) error) error {
+		m := current
+		if err := fn(&m); err != nil {
+			return err
+		}
+		check(m)
+		return nil
+	}
+}
+
+func TestNewCrawler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := crawler.NewMockMediumSourceStorer(ctrl)
+
+	c := crawler.NewCrawler(s, http.DefaultClient, time.Minute, 2)
+	assert.NotNil(t, c)
+}
+
+const page = `<html><body><main><p>%s</p></main></body></html>`
+
+const post = "The type parameters proposal has been accepted and we take a tour through the draft design, " +
+	"writing a handful of generic data structures and comparing them with the interface based versions " +
+	"that we use today. Since Go 1.13 the standard library can wrap and unwrap errors, so we also look at " +
+	"when to wrap, when to define sentinel errors and how constant errors can't be reassigned."
+
+func TestCrawler_Crawl(t *testing.T) {
+	_, _ = logging.TestContext(context.Background())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/post":
+			fmt.Fprintf(w, page, post)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	current := fingerprint.FromText(post)
+	old := fingerprint.FromText("Replace directives, workspaces and vendoring all have their place. This post covers " +
+		"the setup that our team settled on after migrating forty services away from a single GOPATH.")
+	typo := fingerprint.FromText(strings.Replace(post, "handful", "handfull", 1))
+	modified := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		source       store.Medium
+		wantUpdated  bool
+		wantModified bool
+	}{
+		{
+			name: "never crawled",
+			source: store.Medium{
+				ID:     "1",
+				URL:    srv.URL + "/post",
+				UserID: "some-user-id",
+			},
+			wantUpdated:  true,
+			wantModified: true,
+		},
+		{
+			name: "content changed",
+			source: store.Medium{
+				ID:           "2",
+				URL:          srv.URL + "/post",
+				Hash:         old.Hash,
+				Simhash:      old.Simhash,
+				ModifiedDate: modified,
+				UserID:       "some-user-id",
+			},
+			wantUpdated:  true,
+			wantModified: true,
+		},
+		{
+			name: "minor edit",
+			source: store.Medium{
+				ID:           "3",
+				URL:          srv.URL + "/post",
+				Hash:         typo.Hash,
+				Simhash:      typo.Simhash,
+				ModifiedDate: modified,
+				UserID:       "some-user-id",
+			},
+			wantUpdated:  true,
+			wantModified: false,
+		},
+		{
+			name: "content unchanged",
+			source: store.Medium{
+				ID:           "4",
+				URL:          srv.URL + "/post",
+				Hash:         current.Hash,
+				Simhash:      current.Simhash,
+				ModifiedDate: modified,
+				UserID:       "some-user-id",
+			},
+			wantUpdated: false,
+		},
+		{
+			name: "fetch failed",
+			source: store.Medium{
+				ID:     "5",
+				URL:    srv.URL + "/missing",
+				Hash:   old.Hash,
+				UserID: "some-user-id",
+			},
+			wantUpdated: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := crawler.NewMockMediumSourceStorer(ctrl)
+			s.EXPECT().GetUserIDs(gomock.Any()).Return([]string{tt.source.UserID}, nil)
+			s.EXPECT().GetAllSourceData(gomock.Any(), tt.source.UserID, "").Return([]store.Medium{tt.source}, "some-cursor", nil)
+			s.EXPECT().GetAllSourceData(gomock.Any(), tt.source.UserID, "some-cursor").Return(nil, "", nil)
+
+			if tt.wantUpdated {
+				s.EXPECT().ModifySource(gomock.Any(), tt.source.UserID, tt.source.ID, gomock.Any()).DoAndReturn(modify(tt.source, func(source store.Medium) {
+					assert.Equal(t, tt.source.ID, source.ID)
+					assert.Equal(t, current.Hash, source.Hash)
+					if tt.wantModified {
+						assert.Equal(t, current.Simhash, source.Simhash)
+						assert.True(t, source.ModifiedDate.After(modified))
+					} else {
+						assert.Equal(t, tt.source.Simhash, source.Simhash)
+						assert.Equal(t, modified, source.ModifiedDate)
+					}
+				}))
+			}
+
+			c := crawler.NewCrawler(s, srv.Client(), time.Minute, 2)
+
+			err := c.Crawl(ctx)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCrawler_Crawl_Conditional(t *testing.T) {
+	_, _ = logging.TestContext(context.Background())
+
+	const (
+		etag         = `"v1"`
+		lastModified = "Wed, 21 Oct 2015 07:28:00 GMT"
+	)
+
+	var notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag || r.Header.Get("If-Modified-Since") == lastModified {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		fmt.Fprint(w, "some content")
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	source := store.Medium{ID: "1", URL: srv.URL, UserID: "some-user-id"}
+
+	s := crawler.NewMockMediumSourceStorer(ctrl)
+	s.EXPECT().GetUserIDs(gomock.Any()).Return([]string{source.UserID}, nil).Times(2)
+	s.EXPECT().GetAllSourceData(gomock.Any(), source.UserID, "").DoAndReturn(func(ctx context.Context, userID string, cursor string) ([]store.Medium, string, error) {
+		return []store.Medium{source}, "", nil
+	}).Times(2)
+	s.EXPECT().ModifySource(gomock.Any(), source.UserID, source.ID, gomock.Any()).DoAndReturn(modify(source, func(m store.Medium) {
+		assert.Equal(t, etag, m.ETag)
+		assert.Equal(t, lastModified, m.LastModified)
+		assert.NotEmpty(t, m.Hash)
+		source = m
+	})).Times(1)
+
+	c := crawler.NewCrawler(s, srv.Client(), time.Minute, 1)
+
+	assert.NoError(t, c.Crawl(ctx))
+	assert.Equal(t, 0, notModified)
+
+	assert.NoError(t, c.Crawl(ctx))
+	assert.Equal(t, 1, notModified)
+}
+
+const rssFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+  <channel>
+    <title>The Gopher Gazette</title>
+    <item>
+      <title>A first look at generics in Go</title>
+      <link>https://gazette.example.com/posts/generics</link>
+      <guid>post-42</guid>
+      <pubDate>Thu, 15 Oct 2020 09:00:00 +0000</pubDate>
+    </item>
+    <item>
+      <title>Wrapping errors without losing your mind</title>
+      <link>https://gazette.example.com/posts/errors</link>
+      <guid>post-41</guid>
+      <pubDate>Thu, 08 Oct 2020 09:00:00 +0000</pubDate>
+    </item>
+  </channel>
+</rss>`
+
+func TestCrawler_Crawl_Feed(t *testing.T) {
+	_, _ = logging.TestContext(context.Background())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/blog":
+			fmt.Fprint(w, `<html><head><link rel="alternate" type="application/rss+xml" href="/feed.xml"></head><body>hello</body></html>`)
+		case "/feed.xml":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			fmt.Fprint(w, rssFeed)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	published := time.Date(2020, 10, 15, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		source      store.Medium
+		wantUpdated bool
+		wantFeedURL string
+	}{
+		{
+			name: "feed discovered on the page",
+			source: store.Medium{
+				ID:     "1",
+				URL:    srv.URL + "/blog",
+				UserID: "some-user-id",
+			},
+			wantUpdated: true,
+			wantFeedURL: srv.URL + "/feed.xml",
+		},
+		{
+			name: "source is a feed",
+			source: store.Medium{
+				ID:     "2",
+				URL:    srv.URL + "/feed.xml",
+				UserID: "some-user-id",
+			},
+			wantUpdated: true,
+			wantFeedURL: srv.URL + "/feed.xml",
+		},
+		{
+			name: "new item in the feed",
+			source: store.Medium{
+				ID:           "3",
+				URL:          srv.URL + "/blog",
+				FeedURL:      srv.URL + "/feed.xml",
+				LatestItemID: "post-41",
+				UserID:       "some-user-id",
+			},
+			wantUpdated: true,
+			wantFeedURL: srv.URL + "/feed.xml",
+		},
+		{
+			name: "no new items in the feed",
+			source: store.Medium{
+				ID:              "4",
+				URL:             srv.URL + "/blog",
+				FeedURL:         srv.URL + "/feed.xml",
+				LatestItemID:    "post-42",
+				LatestItemTitle: "A first look at generics in Go",
+				LatestItemLink:  "https://gazette.example.com/posts/generics",
+				LatestItemDate:  published,
+				UserID:          "some-user-id",
+			},
+			wantUpdated: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := crawler.NewMockMediumSourceStorer(ctrl)
+			s.EXPECT().GetUserIDs(gomock.Any()).Return([]string{tt.source.UserID}, nil)
+			s.EXPECT().GetAllSourceData(gomock.Any(), tt.source.UserID, "").Return([]store.Medium{tt.source}, "some-cursor", nil)
+			s.EXPECT().GetAllSourceData(gomock.Any(), tt.source.UserID, "some-cursor").Return(nil, "", nil)
+
+			if tt.wantUpdated {
+				s.EXPECT().ModifySource(gomock.Any(), tt.source.UserID, tt.source.ID, gomock.Any()).DoAndReturn(modify(tt.source, func(source store.Medium) {
+					assert.Equal(t, tt.wantFeedURL, source.FeedURL)
+					assert.Equal(t, "post-42", source.LatestItemID)
+					assert.Equal(t, "A first look at generics in Go", source.LatestItemTitle)
+					assert.Equal(t, "https://gazette.example.com/posts/generics", source.LatestItemLink)
+					assert.Equal(t, published, source.LatestItemDate)
+					assert.Equal(t, published, source.ModifiedDate)
+					assert.NotEmpty(t, source.Hash)
+				}))
+			}
+
+			c := crawler.NewCrawler(s, srv.Client(), time.Minute, 1)
+
+			err := c.Crawl(ctx)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCrawler_WatchAdds(t *testing.T) {
+	_, _ = logging.TestContext(context.Background())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, rssFeed)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := crawler.NewMockMediumSourceStorer(ctrl)
+	s.EXPECT().GetUserIDs(gomock.Any()).Return(nil, nil)
+	s.EXPECT().GetAllSourceData(gomock.Any(), "some-user-id", "").Return([]store.Medium{
+		{ID: "1", URL: srv.URL, UserID: "some-user-id"},
+		{ID: "2", URL: srv.URL, FeedURL: srv.URL, Hash: "some-hash", UserID: "some-user-id"},
+	}, "some-cursor", nil)
+	s.EXPECT().GetAllSourceData(gomock.Any(), "some-user-id", "some-cursor").Return(nil, "", nil)
+
+	updated := make(chan store.Medium, 1)
+	s.EXPECT().ModifySource(gomock.Any(), "some-user-id", "1", gomock.Any()).DoAndReturn(modify(store.Medium{ID: "1", UserID: "some-user-id"}, func(source store.Medium) {
+		updated <- source
+	}))
+
+	r := rest.NewMockMediumSourceStorer(ctrl)
+	r.EXPECT().AddSource(gomock.Any(), "some-user-id", srv.URL).Return(nil)
+
+	c := crawler.NewCrawler(s, srv.Client(), time.Hour, 1)
+
+	done := make(chan error)
+	go func() {
+		done <- c.Start(ctx)
+	}()
+
+	err := c.WatchAdds(r).AddSource(ctx, "some-user-id", srv.URL)
+	assert.NoError(t, err)
+
+	select {
+	case m := <-updated:
+		assert.Equal(t, "1", m.ID)
+		assert.Equal(t, srv.URL, m.FeedURL)
+	case <-time.After(5 * time.Second):
+		t.Fatal("new source wasn't crawled")
+	}
+
+	cancel()
+	assert.True(t, errors.Is(<-done, context.Canceled))
+}
+
+func TestCrawler_Crawl_PickDuringFetch(t *testing.T) {
+	_, _ = logging.TestContext(context.Background())
+	ctx := context.Background()
+
+	m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "medium.json"), time.Second, 10)
+	assert.NoError(t, err)
+	defer func() { assert.NoError(t, m.Close(ctx)) }()
+
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			close(fetching)
+			<-release
+		})
+		_, _ = fmt.Fprint(w, "<html><body>some-body</body></html>")
+	}))
+	defer srv.Close()
+
+	assert.NoError(t, m.AddSource(ctx, "some-user", srv.URL))
+
+	c := crawler.NewCrawler(m, srv.Client(), time.Minute, 1)
+
+	done := make(chan error)
+	go func() {
+		done <- c.Crawl(ctx)
+	}()
+
+	// The source is picked after the crawler has read it but before the
+	// fetch has finished, and the pick isn't undone when it has
+	<-fetching
+	ss, err := service.NewPicker(m).Pick(ctx, "some-user", 1, service.PickOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, ss, 1)
+	close(release)
+	assert.NoError(t, <-done)
+
+	got, err := m.GetSource(ctx, "some-user", ss[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, got.Hit)
+	assert.False(t, got.LastPickedAt.IsZero())
+	assert.NotEmpty(t, got.Hash)
+}
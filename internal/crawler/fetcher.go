@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ankur22/medium-picker/internal/err"
+)
+
+const (
+	ErrCannotCreateRequest = err.Const("cannot create request")
+	ErrCannotFetchSource   = err.Const("cannot fetch source")
+	ErrUnexpectedStatus    = err.Const("unexpected status code")
+	ErrCannotReadBody      = err.Const("cannot read body")
+)
+
+// maxBodySize is the most that will be read from a site
+const maxBodySize = 10 << 20
+
+// HTTPClient is the subset of http.Client that is needed to fetch a site
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type fetcher struct {
+	client HTTPClient
+}
+
+func newFetcher(client HTTPClient) *fetcher {
+	return &fetcher{client: client}
+}
+
+// response is what was found when fetching a url
+type response struct {
+	// notModified is true when the site responded with a 304,
+	// in which case none of the other fields are set
+	notModified  bool
+	url          *url.URL
+	contentType  string
+	body         []byte
+	etag         string
+	lastModified string
+}
+
+func (r response) isHTML() bool {
+	return r.contentType == "" || strings.Contains(r.contentType, "html")
+}
+
+func (r response) isFeed() bool {
+	return strings.Contains(r.contentType, "xml") || strings.Contains(r.contentType, "json")
+}
+
+// fetch will GET the url. The validators from the previous fetch are
+// sent so that the site can tell us that nothing has changed without
+// sending the body again.
+func (f *fetcher) fetch(ctx context.Context, rawURL, etag, lastModified string) (response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, normaliseURL(rawURL), nil)
+	if err != nil {
+		return response{}, ErrCannotCreateRequest.Wrap(err)
+	}
+	req.Header.Set("User-Agent", "medium-picker")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return response{}, ErrCannotFetchSource.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return response{notModified: true}, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return response{}, ErrUnexpectedStatus.Wrap(fmt.Errorf("%d", resp.StatusCode))
+	}
+
+	bb, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return response{}, ErrCannotReadBody.Wrap(err)
+	}
+
+	return response{
+		url:          resp.Request.URL,
+		contentType:  strings.ToLower(resp.Header.Get("Content-Type")),
+		body:         bb,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// normaliseURL adds a scheme to sources that were added without one,
+// e.g. google.com/news
+func normaliseURL(url string) string {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return url
+	}
+	return "https://" + url
+}
@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/ankur22/medium-picker/internal/crawler (interfaces: MediumSourceStorer)
+
+// Package crawler is a generated GoMock package.
+package crawler
+
+import (
+	context "context"
+	store "github.com/ankur22/medium-picker/internal/store"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockMediumSourceStorer is a mock of MediumSourceStorer interface
+type MockMediumSourceStorer struct {
+	ctrl     *gomock.Controller
+	recorder *MockMediumSourceStorerMockRecorder
+}
+
+// MockMediumSourceStorerMockRecorder is the mock recorder for MockMediumSourceStorer
+type MockMediumSourceStorerMockRecorder struct {
+	mock *MockMediumSourceStorer
+}
+
+// NewMockMediumSourceStorer creates a new mock instance
+func NewMockMediumSourceStorer(ctrl *gomock.Controller) *MockMediumSourceStorer {
+	mock := &MockMediumSourceStorer{ctrl: ctrl}
+	mock.recorder = &MockMediumSourceStorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockMediumSourceStorer) EXPECT() *MockMediumSourceStorerMockRecorder {
+	return m.recorder
+}
+
+// GetAllSourceData mocks base method
+func (m *MockMediumSourceStorer) GetAllSourceData(arg0 context.Context, arg1, arg2 string) ([]store.Medium, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllSourceData", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]store.Medium)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetAllSourceData indicates an expected call of GetAllSourceData
+func (mr *MockMediumSourceStorerMockRecorder) GetAllSourceData(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllSourceData", reflect.TypeOf((*MockMediumSourceStorer)(nil).GetAllSourceData), arg0, arg1, arg2)
+}
+
+// GetUserIDs mocks base method
+func (m *MockMediumSourceStorer) GetUserIDs(arg0 context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserIDs", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserIDs indicates an expected call of GetUserIDs
+func (mr *MockMediumSourceStorerMockRecorder) GetUserIDs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserIDs", reflect.TypeOf((*MockMediumSourceStorer)(nil).GetUserIDs), arg0)
+}
+
+// ModifySource mocks base method
+func (m *MockMediumSourceStorer) ModifySource(arg0 context.Context, arg1, arg2 string, arg3 func(*store.Medium) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ModifySource", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ModifySource indicates an expected call of ModifySource
+func (mr *MockMediumSourceStorerMockRecorder) ModifySource(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ModifySource", reflect.TypeOf((*MockMediumSourceStorer)(nil).ModifySource), arg0, arg1, arg2, arg3)
+}
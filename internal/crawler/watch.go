@@ -0,0 +1,34 @@
+package crawler
+
+import (
+	"context"
+
+	"github.com/ankur22/medium-picker/internal/rest"
+)
+
+// WatchAdds wraps s so that new sources are crawled as soon as they
+// are added, which is when their feed is discovered, rather than
+// waiting for the next scheduled crawl
+func (c *Crawler) WatchAdds(s rest.MediumSourceStorer) rest.MediumSourceStorer {
+	return &addWatcher{MediumSourceStorer: s, c: c}
+}
+
+type addWatcher struct {
+	rest.MediumSourceStorer
+	c *Crawler
+}
+
+// AddSource will add the source and queue it to be crawled
+func (a *addWatcher) AddSource(ctx context.Context, userID string, source string) error {
+	if err := a.MediumSourceStorer.AddSource(ctx, userID, source); err != nil {
+		return err
+	}
+
+	select {
+	case a.c.added <- userID:
+	default:
+		// The queue is full, the next scheduled crawl will pick it up
+	}
+
+	return nil
+}
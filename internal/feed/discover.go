@@ -0,0 +1,72 @@
+package feed
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/ankur22/medium-picker/internal/err"
+)
+
+const ErrCannotParseHTML = err.Const("cannot parse html")
+
+// feedTypes are the content types that mark a link as a feed
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/json":      true,
+}
+
+// Discover finds the feeds that the page advertises with
+// <link rel="alternate">, in the order that they appear.
+// Relative links are resolved against base.
+func Discover(r io.Reader, base *url.URL) ([]string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, ErrCannotParseHTML.Wrap(err)
+	}
+
+	var found []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "link" {
+			if href, ok := feedLink(n); ok {
+				if u, err := base.Parse(href); err == nil {
+					found = append(found, u.String())
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return found, nil
+}
+
+func feedLink(n *html.Node) (string, bool) {
+	var rel, typ, href string
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "rel":
+			rel = strings.ToLower(a.Val)
+		case "type":
+			typ = strings.ToLower(strings.TrimSpace(a.Val))
+		case "href":
+			href = strings.TrimSpace(a.Val)
+		}
+	}
+
+	isAlternate := false
+	for _, r := range strings.Fields(rel) {
+		if r == "alternate" {
+			isAlternate = true
+		}
+	}
+
+	return href, isAlternate && feedTypes[typ] && href != ""
+}
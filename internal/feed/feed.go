@@ -0,0 +1,226 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ankur22/medium-picker/internal/err"
+)
+
+const (
+	ErrCannotReadFeed    = err.Const("cannot read feed")
+	ErrCannotParseFeed   = err.Const("cannot parse feed")
+	ErrUnknownFeedFormat = err.Const("unknown feed format")
+)
+
+// Feed is the format independent representation of
+// an RSS 2.0, Atom 1.0 or JSON Feed document
+type Feed struct {
+	Title string
+	Items []Item
+}
+
+// Item is a single entry in a feed
+type Item struct {
+	ID        string
+	Title     string
+	Link      string
+	Published time.Time
+}
+
+// Latest returns the most recently published item. If none of the
+// items have a date then the first item in the feed is returned,
+// as feeds list their newest items first.
+func (f *Feed) Latest() (Item, bool) {
+	if len(f.Items) == 0 {
+		return Item{}, false
+	}
+
+	latest := f.Items[0]
+	for _, i := range f.Items[1:] {
+		if i.Published.After(latest.Published) {
+			latest = i
+		}
+	}
+
+	return latest, true
+}
+
+// Parse will detect the format of the feed and parse it
+func Parse(r io.Reader) (*Feed, error) {
+	bb, err := io.ReadAll(r)
+	if err != nil {
+		return nil, ErrCannotReadFeed.Wrap(err)
+	}
+
+	bb = bytes.TrimSpace(bb)
+	if len(bb) > 0 && bb[0] == '{' {
+		return parseJSON(bb)
+	}
+
+	return parseXML(bb)
+}
+
+func parseXML(bb []byte) (*Feed, error) {
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(bb, &root); err != nil {
+		return nil, ErrCannotParseFeed.Wrap(err)
+	}
+
+	switch root.XMLName.Local {
+	case "rss":
+		return parseRSS(bb)
+	case "feed":
+		return parseAtom(bb)
+	default:
+		return nil, ErrUnknownFeedFormat
+	}
+}
+
+type rss struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			GUID    string `xml:"guid"`
+			Title   string `xml:"title"`
+			Link    string `xml:"link"`
+			PubDate string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+func parseRSS(bb []byte) (*Feed, error) {
+	var doc rss
+	if err := xml.Unmarshal(bb, &doc); err != nil {
+		return nil, ErrCannotParseFeed.Wrap(err)
+	}
+
+	f := Feed{Title: strings.TrimSpace(doc.Channel.Title)}
+	for _, i := range doc.Channel.Items {
+		f.Items = append(f.Items, newItem(i.GUID, i.Title, i.Link, parseTime(i.PubDate)))
+	}
+
+	return &f, nil
+}
+
+type atom struct {
+	Title   string `xml:"title"`
+	Entries []struct {
+		ID        string `xml:"id"`
+		Title     string `xml:"title"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+		Links     []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+func parseAtom(bb []byte) (*Feed, error) {
+	var doc atom
+	if err := xml.Unmarshal(bb, &doc); err != nil {
+		return nil, ErrCannotParseFeed.Wrap(err)
+	}
+
+	f := Feed{Title: strings.TrimSpace(doc.Title)}
+	for _, e := range doc.Entries {
+		var link string
+		for _, l := range e.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = l.Href
+				break
+			}
+		}
+
+		published := parseTime(e.Published)
+		if published.IsZero() {
+			published = parseTime(e.Updated)
+		}
+
+		f.Items = append(f.Items, newItem(e.ID, e.Title, link, published))
+	}
+
+	return &f, nil
+}
+
+type jsonFeed struct {
+	Version string `json:"version"`
+	Title   string `json:"title"`
+	Items   []struct {
+		ID            string `json:"id"`
+		Title         string `json:"title"`
+		URL           string `json:"url"`
+		DatePublished string `json:"date_published"`
+		DateModified  string `json:"date_modified"`
+	} `json:"items"`
+}
+
+func parseJSON(bb []byte) (*Feed, error) {
+	var doc jsonFeed
+	if err := json.Unmarshal(bb, &doc); err != nil {
+		return nil, ErrCannotParseFeed.Wrap(err)
+	}
+
+	if !strings.HasPrefix(doc.Version, "https://jsonfeed.org/version/") {
+		return nil, ErrUnknownFeedFormat
+	}
+
+	f := Feed{Title: strings.TrimSpace(doc.Title)}
+	for _, i := range doc.Items {
+		published := parseTime(i.DatePublished)
+		if published.IsZero() {
+			published = parseTime(i.DateModified)
+		}
+
+		f.Items = append(f.Items, newItem(i.ID, i.Title, i.URL, published))
+	}
+
+	return &f, nil
+}
+
+// newItem falls back to the link as the id, as the id
+// is optional in RSS
+func newItem(id, title, link string, published time.Time) Item {
+	id = strings.TrimSpace(id)
+	link = strings.TrimSpace(link)
+	if id == "" {
+		id = link
+	}
+
+	return Item{
+		ID:        id,
+		Title:     strings.TrimSpace(title),
+		Link:      link,
+		Published: published,
+	}
+}
+
+var timeFormats = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	"2 Jan 2006 15:04:05 -0700",
+}
+
+// parseTime returns the zero time when s isn't in any
+// of the formats that are seen in the wild
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, f := range timeFormats {
+		if t, err := time.Parse(f, s); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
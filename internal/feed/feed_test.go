@@ -0,0 +1,135 @@
+package feed_test
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/feed"
+)
+
+func TestParse_Success(t *testing.T) {
+	tests := []struct {
+		name       string
+		fixture    string
+		wantItems  int
+		wantLatest feed.Item
+	}{
+		{
+			name:      "RSS 2.0",
+			fixture:   "rss.xml",
+			wantItems: 3,
+			wantLatest: feed.Item{
+				ID:        "post-42",
+				Title:     "A first look at generics in Go",
+				Link:      "https://gazette.example.com/posts/generics",
+				Published: time.Date(2020, 10, 15, 9, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:      "Atom 1.0",
+			fixture:   "atom.xml",
+			wantItems: 2,
+			wantLatest: feed.Item{
+				ID:        "urn:uuid:1225c695-cfb8-4ebb-aaaa-80da344efa6a",
+				Title:     "A first look at generics in Go",
+				Link:      "https://gazette.example.com/posts/generics",
+				Published: time.Date(2020, 10, 15, 9, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:      "JSON Feed",
+			fixture:   "feed.json",
+			wantItems: 2,
+			wantLatest: feed.Item{
+				ID:        "42",
+				Title:     "A first look at generics in Go",
+				Link:      "https://gazette.example.com/posts/generics",
+				Published: time.Date(2020, 10, 15, 9, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.fixture))
+			require.NoError(t, err)
+			defer f.Close()
+
+			got, err := feed.Parse(f)
+			require.NoError(t, err)
+
+			assert.Equal(t, "The Gopher Gazette", got.Title)
+			assert.Len(t, got.Items, tt.wantItems)
+
+			latest, ok := got.Latest()
+			assert.True(t, ok)
+			assert.Equal(t, tt.wantLatest, latest)
+		})
+	}
+}
+
+func TestParse_Failure(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want error
+	}{
+		{
+			name: "html page",
+			body: "<html><body>hello</body></html>",
+			want: feed.ErrUnknownFeedFormat,
+		},
+		{
+			name: "json that isn't a feed",
+			body: `{"hello": "world"}`,
+			want: feed.ErrUnknownFeedFormat,
+		},
+		{
+			name: "not xml",
+			body: "hello",
+			want: feed.ErrCannotParseFeed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := feed.Parse(strings.NewReader(tt.body))
+			assert.True(t, errors.Is(err, tt.want))
+			assert.Nil(t, got)
+		})
+	}
+}
+
+func TestFeed_Latest(t *testing.T) {
+	f := feed.Feed{Items: []feed.Item{{ID: "2"}, {ID: "1"}}}
+
+	got, ok := f.Latest()
+	assert.True(t, ok)
+	assert.Equal(t, "2", got.ID)
+
+	_, ok = (&feed.Feed{}).Latest()
+	assert.False(t, ok)
+}
+
+func TestDiscover(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "page.html"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	base, err := url.Parse("https://gazette.example.com/blog/")
+	require.NoError(t, err)
+
+	got, err := feed.Discover(f, base)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"https://gazette.example.com/rss.xml",
+		"https://gazette.example.com/atom.xml",
+		"https://gazette.example.com/blog/feed.json",
+	}, got)
+}
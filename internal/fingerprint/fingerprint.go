@@ -0,0 +1,268 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"io"
+	"math/bits"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+
+	"github.com/ankur22/medium-picker/internal/err"
+)
+
+const (
+	ErrCannotParseHTML = err.Const("cannot parse html")
+	ErrCannotReadBody  = err.Const("cannot read body")
+)
+
+// DefaultThreshold is the number of bits that two simhashes can differ by
+// before the content is considered to have meaningfully changed
+const DefaultThreshold = 8
+
+// shingleSize is the number of words in each feature of the simhash
+const shingleSize = 3
+
+// Fingerprint identifies the main textual content of a page
+type Fingerprint struct {
+	// Hash is the sha256 of the normalised text. It changes on any
+	// edit to the text, no matter how small.
+	Hash string
+	// Simhash is a locality sensitive hash of the normalised text.
+	// Similar text will result in simhashes that differ by a few bits.
+	Simhash uint64
+}
+
+// FromHTML extracts the main textual content from the page, ignoring
+// scripts, styles, comments and boilerplate, and fingerprints it
+func FromHTML(r io.Reader) (Fingerprint, error) {
+	text, err := Extract(r)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	return FromText(text), nil
+}
+
+// FromReader fingerprints the whole of r as plain text
+func FromReader(r io.Reader) (Fingerprint, error) {
+	bb, err := io.ReadAll(r)
+	if err != nil {
+		return Fingerprint{}, ErrCannotReadBody.Wrap(err)
+	}
+	return FromText(string(bb)), nil
+}
+
+// FromText fingerprints the text
+func FromText(text string) Fingerprint {
+	words := normalise(text)
+
+	h := sha256.Sum256([]byte(strings.Join(words, " ")))
+
+	return Fingerprint{
+		Hash:    hex.EncodeToString(h[:]),
+		Simhash: simhash(words),
+	}
+}
+
+// Distance is the number of bits that differ between two simhashes
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Changed reports whether next is a meaningful change from prev,
+// i.e. the text is different and the simhashes are more than
+// threshold bits apart
+func Changed(prev, next Fingerprint, threshold int) bool {
+	if prev.Hash == next.Hash {
+		return false
+	}
+	return Distance(prev.Simhash, next.Simhash) > threshold
+}
+
+// Extract returns the main textual content of the page. If the page has
+// a main element, or failing that article elements, then only they are
+// used, otherwise the whole body is.
+func Extract(r io.Reader) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", ErrCannotParseHTML.Wrap(err)
+	}
+
+	roots := findAll(doc, isMain)
+	if len(roots) == 0 {
+		roots = findAll(doc, func(n *html.Node) bool { return n.Data == "article" })
+	}
+	if len(roots) == 0 {
+		roots = []*html.Node{doc}
+	}
+
+	var sb strings.Builder
+	for _, n := range roots {
+		text(&sb, n)
+	}
+
+	return sb.String(), nil
+}
+
+func isMain(n *html.Node) bool {
+	if n.Data == "main" {
+		return true
+	}
+	for _, a := range n.Attr {
+		if a.Key == "role" && a.Val == "main" {
+			return true
+		}
+	}
+	return false
+}
+
+// findAll returns the outermost element nodes that match
+func findAll(n *html.Node, match func(*html.Node) bool) []*html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return []*html.Node{n}
+	}
+
+	var found []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		found = append(found, findAll(c, match)...)
+	}
+	return found
+}
+
+func text(sb *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.CommentNode, html.DoctypeNode:
+		return
+	case html.TextNode:
+		sb.WriteString(n.Data)
+		sb.WriteByte(' ')
+		return
+	case html.ElementNode:
+		if skipElements[n.Data] || isBoilerplate(n) {
+			return
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		text(sb, c)
+	}
+}
+
+// skipElements never contain the content that someone wants to read
+var skipElements = map[string]bool{
+	"head":     true,
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"iframe":   true,
+	"svg":      true,
+	"canvas":   true,
+	"form":     true,
+	"button":   true,
+	"input":    true,
+	"select":   true,
+	"textarea": true,
+	"nav":      true,
+	"header":   true,
+	"footer":   true,
+	"aside":    true,
+	"time":     true,
+}
+
+// boilerplateWords are the words in a class or id that mark
+// an element as not being part of the content
+var boilerplateWords = map[string]bool{
+	"ad":          true,
+	"ads":         true,
+	"advert":      true,
+	"advertising": true,
+	"banner":      true,
+	"sponsored":   true,
+	"promo":       true,
+	"related":     true,
+	"recommended": true,
+	"popular":     true,
+	"trending":    true,
+	"sidebar":     true,
+	"cookie":      true,
+	"cookies":     true,
+	"consent":     true,
+	"share":       true,
+	"social":      true,
+	"newsletter":  true,
+	"subscribe":   true,
+	"comments":    true,
+	"timestamp":   true,
+	"date":        true,
+	"breadcrumb":  true,
+	"breadcrumbs": true,
+}
+
+func isBoilerplate(n *html.Node) bool {
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "hidden":
+			return true
+		case "aria-hidden":
+			if a.Val == "true" {
+				return true
+			}
+		case "class", "id":
+			for _, w := range strings.FieldsFunc(strings.ToLower(a.Val), isSeparator) {
+				if boilerplateWords[w] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// normalise lower cases the text and splits it into words,
+// dropping any punctuation
+func normalise(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), isSeparator)
+}
+
+func simhash(words []string) uint64 {
+	if len(words) == 0 {
+		return 0
+	}
+
+	var v [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(feature))
+		x := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if x&(1<<uint(i)) != 0 {
+				v[i]++
+			} else {
+				v[i]--
+			}
+		}
+	}
+
+	if len(words) < shingleSize {
+		add(strings.Join(words, " "))
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		add(strings.Join(words[i:i+shingleSize], " "))
+	}
+
+	var s uint64
+	for i := 0; i < 64; i++ {
+		if v[i] > 0 {
+			s |= 1 << uint(i)
+		}
+	}
+	return s
+}
@@ -0,0 +1,127 @@
+package fingerprint_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/fingerprint"
+)
+
+func fromFixture(t *testing.T, name string) fingerprint.Fingerprint {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+
+	fp, err := fingerprint.FromHTML(f)
+	require.NoError(t, err)
+
+	return fp
+}
+
+func TestChanged(t *testing.T) {
+	tests := []struct {
+		name        string
+		fixture     string
+		wantChanged bool
+	}{
+		{
+			name:        "csrf token and script nonce rotated",
+			fixture:     "csrf_token.html",
+			wantChanged: false,
+		},
+		{
+			name:        "timestamps and cache busters changed",
+			fixture:     "timestamps.html",
+			wantChanged: false,
+		},
+		{
+			name:        "different advert shown",
+			fixture:     "ad_slot.html",
+			wantChanged: false,
+		},
+		{
+			name:        "related posts rotated",
+			fixture:     "related_posts.html",
+			wantChanged: false,
+		},
+		{
+			name:        "typo fixed in a post",
+			fixture:     "typo_fix.html",
+			wantChanged: false,
+		},
+		{
+			name:        "new post published",
+			fixture:     "new_post.html",
+			wantChanged: true,
+		},
+		{
+			name:        "post rewritten",
+			fixture:     "rewritten_post.html",
+			wantChanged: true,
+		},
+	}
+
+	base := fromFixture(t, "base.html")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fromFixture(t, tt.fixture)
+
+			assert.Equal(t, tt.wantChanged, fingerprint.Changed(base, got, fingerprint.DefaultThreshold))
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "base.html"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	got, err := fingerprint.Extract(f)
+	require.NoError(t, err)
+
+	assert.Contains(t, got, "A first look at generics in Go")
+	assert.Contains(t, got, "accidental quadratic loop")
+
+	for _, noise := range []string{"__BUILD__", "font-family", "rendered by", "keyboards", "Related posts", "Subscribe", "Copyright", "15 October 2020"} {
+		assert.NotContains(t, got, noise)
+	}
+}
+
+func TestFromText(t *testing.T) {
+	a := fingerprint.FromText("Hello, World!")
+	b := fingerprint.FromText("  hello world ")
+
+	assert.Equal(t, a, b)
+	assert.Equal(t, 0, fingerprint.Distance(a.Simhash, b.Simhash))
+}
+
+func TestFromReader(t *testing.T) {
+	got, err := fingerprint.FromReader(strings.NewReader("Hello, World!"))
+	assert.NoError(t, err)
+	assert.Equal(t, fingerprint.FromText("Hello, World!"), got)
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b uint64
+		want int
+	}{
+		{name: "identical", a: 0xff, b: 0xff, want: 0},
+		{name: "one bit", a: 0x1, b: 0x0, want: 1},
+		{name: "all bits", a: 0, b: ^uint64(0), want: 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, fingerprint.Distance(tt.a, tt.b))
+		})
+	}
+}
@@ -0,0 +1,312 @@
+//go:generate mockgen -destination=mock_migrator.go -package=migrator github.com/ankur22/medium-picker/internal/migrator UserStorer,MediumSourceStorer
+
+// Package migrator copies every user and medium source from one store
+// backend to another, e.g. when moving from the JSON files to a database.
+package migrator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ankur22/medium-picker/internal/err"
+	"github.com/ankur22/medium-picker/internal/logging"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+const (
+	ErrCannotReadCheckpoint  = err.Const("cannot read migration checkpoint")
+	ErrCannotWriteCheckpoint = err.Const("cannot write migration checkpoint")
+	ErrCannotCopyUsers       = err.Const("cannot copy users")
+	ErrCannotCopySources     = err.Const("cannot copy medium sources")
+	ErrCannotSummarise       = err.Const("cannot summarise store")
+	ErrCountMismatch         = err.Const("record counts don't match")
+	ErrChecksumMismatch      = err.Const("checksums don't match")
+)
+
+// UserStorer interface to list and import users
+type UserStorer interface {
+	ListUsers(ctx context.Context) ([]store.User, error)
+	ImportUser(ctx context.Context, user store.User) error
+}
+
+// MediumSourceStorer interface to list and import medium sources
+type MediumSourceStorer interface {
+	GetUserIDs(ctx context.Context) ([]string, error)
+	GetAllSourceData(ctx context.Context, userID string, cursor string) ([]store.Medium, string, error)
+	ImportSource(ctx context.Context, source store.Medium) error
+}
+
+// Store is a user and a medium store that are migrated together
+type Store struct {
+	Users  UserStorer
+	Medium MediumSourceStorer
+}
+
+// Summary is the number of records in a store and a checksum over all of them
+type Summary struct {
+	Users    int
+	Sources  int
+	Checksum string
+}
+
+// checkpoint is what has already been copied. It's saved after every
+// page so that an interrupted migration carries on where it stopped.
+type checkpoint struct {
+	Users bool `json:"users"`
+	// Done are the users whose sources have all been copied
+	Done []string `json:"done"`
+	// UserID is the user whose sources are being copied, and
+	// Cursor is the page to carry on from
+	UserID string `json:"userId,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// Migrator streams the records from one store to another
+type Migrator struct {
+	checkpointFile string
+}
+
+// NewMigrator creates a Migrator that keeps its progress in checkpointFile
+func NewMigrator(checkpointFile string) *Migrator {
+	return &Migrator{checkpointFile: checkpointFile}
+}
+
+// Run copies every user and then every medium source from src to dst.
+// The records keep their ids, dates and counters. If a previous run was
+// interrupted it carries on from its checkpoint, which is removed once
+// everything has been copied. Records are upserted, so copying one twice
+// is harmless.
+func (m *Migrator) Run(ctx context.Context, src, dst Store) error {
+	cp, err := m.readCheckpoint()
+	if err != nil {
+		return err
+	}
+	if cp.Users || len(cp.Done) > 0 || cp.UserID != "" {
+		logging.Info(ctx, "Resuming migration", zap.Int("usersDone", len(cp.Done)), zap.String("userID", cp.UserID))
+	}
+
+	if !cp.Users {
+		n, err := copyUsers(ctx, src.Users, dst.Users)
+		if err != nil {
+			return err
+		}
+		cp.Users = true
+		if err := m.writeCheckpoint(cp); err != nil {
+			return err
+		}
+		logging.Info(ctx, "Copied users", zap.Int("users", n))
+	}
+
+	userIDs, err := src.Medium.GetUserIDs(ctx)
+	if err != nil {
+		return ErrCannotCopySources.Wrap(err)
+	}
+
+	done := make(map[string]bool, len(cp.Done))
+	for _, id := range cp.Done {
+		done[id] = true
+	}
+
+	for _, userID := range userIDs {
+		if done[userID] {
+			continue
+		}
+
+		cursor := ""
+		if cp.UserID == userID {
+			cursor = cp.Cursor
+		}
+
+		n := 0
+		for {
+			sources, next, err := src.Medium.GetAllSourceData(ctx, userID, cursor)
+			if err != nil {
+				return ErrCannotCopySources.Wrap(fmt.Errorf("user %s: %w", userID, err))
+			}
+			for _, s := range sources {
+				if err := dst.Medium.ImportSource(ctx, s); err != nil {
+					return ErrCannotCopySources.Wrap(fmt.Errorf("source %s: %w", s.ID, err))
+				}
+			}
+			n += len(sources)
+
+			if next == "" {
+				break
+			}
+			cursor = next
+
+			cp.UserID, cp.Cursor = userID, cursor
+			if err := m.writeCheckpoint(cp); err != nil {
+				return err
+			}
+		}
+
+		cp.Done = append(cp.Done, userID)
+		cp.UserID, cp.Cursor = "", ""
+		if err := m.writeCheckpoint(cp); err != nil {
+			return err
+		}
+		logging.Info(ctx, "Copied medium sources", zap.String("userID", userID), zap.Int("sources", n))
+	}
+
+	if err := os.Remove(m.checkpointFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return ErrCannotWriteCheckpoint.Wrap(err)
+	}
+
+	return nil
+}
+
+// Verify checks that dst holds the same records as src. It returns
+// the summary of both stores, and an error if they don't match.
+func Verify(ctx context.Context, src, dst Store) (Summary, Summary, error) {
+	s, err := Summarise(ctx, src)
+	if err != nil {
+		return Summary{}, Summary{}, err
+	}
+	d, err := Summarise(ctx, dst)
+	if err != nil {
+		return Summary{}, Summary{}, err
+	}
+
+	if s.Users != d.Users || s.Sources != d.Sources {
+		return s, d, ErrCountMismatch.Wrap(fmt.Errorf("%d users and %d sources were copied to %d users and %d sources",
+			s.Users, s.Sources, d.Users, d.Sources))
+	}
+	if s.Checksum != d.Checksum {
+		return s, d, ErrChecksumMismatch.Wrap(fmt.Errorf("%s != %s", s.Checksum, d.Checksum))
+	}
+
+	return s, d, nil
+}
+
+// Summarise counts the records in the store and checksums them. The
+// checksum doesn't depend on the order that the store returns the
+// records in, or on how precisely it keeps the dates.
+func Summarise(ctx context.Context, s Store) (Summary, error) {
+	var sum Summary
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+
+	users, err := s.Users.ListUsers(ctx)
+	if err != nil {
+		return Summary{}, ErrCannotSummarise.Wrap(err)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	for _, u := range users {
+		if err := enc.Encode(u); err != nil {
+			return Summary{}, ErrCannotSummarise.Wrap(err)
+		}
+	}
+	sum.Users = len(users)
+
+	userIDs, err := s.Medium.GetUserIDs(ctx)
+	if err != nil {
+		return Summary{}, ErrCannotSummarise.Wrap(err)
+	}
+	sort.Strings(userIDs)
+
+	for _, userID := range userIDs {
+		var all []store.Medium
+		cursor := ""
+		for {
+			sources, next, err := s.Medium.GetAllSourceData(ctx, userID, cursor)
+			if err != nil {
+				return Summary{}, ErrCannotSummarise.Wrap(err)
+			}
+			all = append(all, sources...)
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+
+		sort.Slice(all, func(i, j int) bool {
+			return all[i].ID < all[j].ID
+		})
+		for _, m := range all {
+			if err := enc.Encode(canonical(m)); err != nil {
+				return Summary{}, ErrCannotSummarise.Wrap(err)
+			}
+		}
+		sum.Sources += len(all)
+	}
+
+	sum.Checksum = hex.EncodeToString(h.Sum(nil))
+
+	return sum, nil
+}
+
+// canonical returns the medium with its dates in UTC, to the microsecond,
+// which is as precise as the databases keep them
+func canonical(m store.Medium) store.Medium {
+	m.CreatedDate = m.CreatedDate.UTC().Truncate(time.Microsecond)
+	m.ModifiedDate = m.ModifiedDate.UTC().Truncate(time.Microsecond)
+	m.LatestItemDate = m.LatestItemDate.UTC().Truncate(time.Microsecond)
+	m.LastPickedAt = m.LastPickedAt.UTC().Truncate(time.Microsecond)
+	m.LastFeedbackAt = m.LastFeedbackAt.UTC().Truncate(time.Microsecond)
+	return m
+}
+
+func (m *Migrator) readCheckpoint() (checkpoint, error) {
+	var cp checkpoint
+
+	bb, err := ioutil.ReadFile(m.checkpointFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, ErrCannotReadCheckpoint.Wrap(err)
+	}
+
+	if err := json.Unmarshal(bb, &cp); err != nil {
+		return cp, ErrCannotReadCheckpoint.Wrap(err)
+	}
+
+	return cp, nil
+}
+
+// writeCheckpoint replaces the checkpoint file, so that it's never
+// left half written
+func (m *Migrator) writeCheckpoint(cp checkpoint) error {
+	bb, err := json.Marshal(cp)
+	if err != nil {
+		return ErrCannotWriteCheckpoint.Wrap(err)
+	}
+
+	tmp := m.checkpointFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, bb, 0600); err != nil {
+		return ErrCannotWriteCheckpoint.Wrap(err)
+	}
+	if err := os.Rename(tmp, m.checkpointFile); err != nil {
+		return ErrCannotWriteCheckpoint.Wrap(err)
+	}
+
+	return nil
+}
+
+func copyUsers(ctx context.Context, src, dst UserStorer) (int, error) {
+	users, err := src.ListUsers(ctx)
+	if err != nil {
+		return 0, ErrCannotCopyUsers.Wrap(err)
+	}
+
+	for _, u := range users {
+		if err := dst.ImportUser(ctx, u); err != nil {
+			return 0, ErrCannotCopyUsers.Wrap(fmt.Errorf("user %s: %w", u.ID, err))
+		}
+	}
+
+	return len(users), nil
+}
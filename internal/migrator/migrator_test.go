@@ -0,0 +1,280 @@
+package migrator_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/logging"
+	"github.com/ankur22/medium-picker/internal/migrator"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+func TestMain(m *testing.M) {
+	_, _ = logging.TestContext(context.Background())
+	os.Exit(m.Run())
+}
+
+func TestMigrator_Run(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+	}{
+		{name: "file to sqlite", from: "file", to: "sqlite"},
+		{name: "sqlite to file", from: "sqlite", to: "file"},
+		{name: "file to file", from: "file", to: "file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			src := openBackend(t, tt.from, filepath.Join(dir, "src"))
+			want := fill(t, src)
+			dst := openBackend(t, tt.to, filepath.Join(dir, "dst"))
+
+			checkpoint := filepath.Join(dir, "migrate.checkpoint")
+			err := migrator.NewMigrator(checkpoint).Run(ctx, toStore(src), toStore(dst))
+			require.NoError(t, err)
+
+			_, err = os.Stat(checkpoint)
+			assert.True(t, errors.Is(err, os.ErrNotExist), err)
+
+			s, d, err := migrator.Verify(ctx, toStore(src), toStore(dst))
+			require.NoError(t, err)
+			assert.Equal(t, s, d)
+			assert.Equal(t, 3, d.Users)
+			assert.Equal(t, 5, d.Sources)
+
+			id, err := dst.Users.GetUser(ctx, "a@example.com")
+			require.NoError(t, err)
+			sources, _, err := dst.Medium.GetAllSourceData(ctx, id, "")
+			require.NoError(t, err)
+			require.Len(t, sources, 1)
+			assert.Equal(t, want.ID, sources[0].ID)
+			assert.Equal(t, want.Hit, sources[0].Hit)
+			assert.Equal(t, want.Multiplier, sources[0].Multiplier)
+			assert.Equal(t, want.Hash, sources[0].Hash)
+			assert.True(t, want.CreatedDate.Equal(sources[0].CreatedDate))
+			assert.True(t, want.ModifiedDate.Equal(sources[0].ModifiedDate))
+		})
+	}
+}
+
+func TestMigrator_Run_Resume(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	src := openBackend(t, "file", filepath.Join(dir, "src"))
+	fill(t, src)
+	dst := openBackend(t, "sqlite", filepath.Join(dir, "dst"))
+
+	// The copy fails part way through the sources of a user
+	failing := toStore(dst)
+	failing.Medium = &failAfter{MediumSourceStorer: dst.Medium, n: 3}
+
+	checkpoint := filepath.Join(dir, "migrate.checkpoint")
+	m := migrator.NewMigrator(checkpoint)
+
+	err := m.Run(ctx, toStore(src), failing)
+	assert.True(t, errors.Is(err, migrator.ErrCannotCopySources), err)
+	_, err = os.Stat(checkpoint)
+	require.NoError(t, err)
+
+	_, _, err = migrator.Verify(ctx, toStore(src), toStore(dst))
+	assert.True(t, errors.Is(err, migrator.ErrCountMismatch), err)
+
+	// It carries on from the checkpoint without copying everything again
+	counting := toStore(dst)
+	c := &failAfter{MediumSourceStorer: dst.Medium, n: -1}
+	counting.Medium = c
+
+	err = m.Run(ctx, toStore(src), counting)
+	require.NoError(t, err)
+	assert.Less(t, c.calls, 5)
+
+	_, _, err = migrator.Verify(ctx, toStore(src), toStore(dst))
+	assert.NoError(t, err)
+}
+
+func TestMigrator_Run_Failure(t *testing.T) {
+	tests := []struct {
+		name    string
+		mocks   func(su, du *migrator.MockUserStorer, sm, dm *migrator.MockMediumSourceStorer)
+		wantErr error
+	}{
+		{
+			name: "list users",
+			mocks: func(su, du *migrator.MockUserStorer, sm, dm *migrator.MockMediumSourceStorer) {
+				su.EXPECT().ListUsers(gomock.Any()).Return(nil, errors.New("some error"))
+			},
+			wantErr: migrator.ErrCannotCopyUsers,
+		},
+		{
+			name: "import user",
+			mocks: func(su, du *migrator.MockUserStorer, sm, dm *migrator.MockMediumSourceStorer) {
+				su.EXPECT().ListUsers(gomock.Any()).Return([]store.User{{ID: "some-user-id", Email: "test@example.com"}}, nil)
+				du.EXPECT().ImportUser(gomock.Any(), store.User{ID: "some-user-id", Email: "test@example.com"}).Return(store.ErrUserAlreadyExists)
+			},
+			wantErr: store.ErrUserAlreadyExists,
+		},
+		{
+			name: "get user ids",
+			mocks: func(su, du *migrator.MockUserStorer, sm, dm *migrator.MockMediumSourceStorer) {
+				su.EXPECT().ListUsers(gomock.Any()).Return(nil, nil)
+				sm.EXPECT().GetUserIDs(gomock.Any()).Return(nil, errors.New("some error"))
+			},
+			wantErr: migrator.ErrCannotCopySources,
+		},
+		{
+			name: "get sources",
+			mocks: func(su, du *migrator.MockUserStorer, sm, dm *migrator.MockMediumSourceStorer) {
+				su.EXPECT().ListUsers(gomock.Any()).Return(nil, nil)
+				sm.EXPECT().GetUserIDs(gomock.Any()).Return([]string{"some-user-id"}, nil)
+				sm.EXPECT().GetAllSourceData(gomock.Any(), "some-user-id", "").Return(nil, "", errors.New("some error"))
+			},
+			wantErr: migrator.ErrCannotCopySources,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			su := migrator.NewMockUserStorer(ctrl)
+			du := migrator.NewMockUserStorer(ctrl)
+			sm := migrator.NewMockMediumSourceStorer(ctrl)
+			dm := migrator.NewMockMediumSourceStorer(ctrl)
+			tt.mocks(su, du, sm, dm)
+
+			m := migrator.NewMigrator(filepath.Join(t.TempDir(), "migrate.checkpoint"))
+			err := m.Run(context.Background(), migrator.Store{Users: su, Medium: sm}, migrator.Store{Users: du, Medium: dm})
+			assert.True(t, errors.Is(err, tt.wantErr), err)
+		})
+	}
+}
+
+func TestVerify_Mismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(t *testing.T, b *store.Backend, userID string)
+		wantErr error
+	}{
+		{
+			name: "missing source",
+			change: func(t *testing.T, b *store.Backend, userID string) {
+				sources, _, err := b.Medium.GetAllSourceData(context.Background(), userID, "")
+				require.NoError(t, err)
+				require.NoError(t, b.Medium.DeleteSource(context.Background(), userID, sources[0].ID))
+			},
+			wantErr: migrator.ErrCountMismatch,
+		},
+		{
+			name: "changed source",
+			change: func(t *testing.T, b *store.Backend, userID string) {
+				sources, _, err := b.Medium.GetAllSourceData(context.Background(), userID, "")
+				require.NoError(t, err)
+				sources[0].Hit++
+				require.NoError(t, b.Medium.UpdateSource(context.Background(), userID, sources[0]))
+			},
+			wantErr: migrator.ErrChecksumMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			src := openBackend(t, "file", filepath.Join(dir, "src"))
+			fill(t, src)
+			dst := openBackend(t, "sqlite", filepath.Join(dir, "dst"))
+
+			err := migrator.NewMigrator(filepath.Join(dir, "migrate.checkpoint")).Run(ctx, toStore(src), toStore(dst))
+			require.NoError(t, err)
+
+			id, err := dst.Users.GetUser(ctx, "b@example.com")
+			require.NoError(t, err)
+			tt.change(t, dst, id)
+
+			_, _, err = migrator.Verify(ctx, toStore(src), toStore(dst))
+			assert.True(t, errors.Is(err, tt.wantErr), err)
+		})
+	}
+}
+
+// failAfter fails every import after the first n, unless n is negative.
+// It counts the imports that it lets through.
+type failAfter struct {
+	migrator.MediumSourceStorer
+	n     int
+	calls int
+}
+
+func (f *failAfter) ImportSource(ctx context.Context, source store.Medium) error {
+	if f.n >= 0 && f.calls >= f.n {
+		return errors.New("some error")
+	}
+	f.calls++
+	return f.MediumSourceStorer.ImportSource(ctx, source)
+}
+
+func openBackend(t *testing.T, scheme, path string) *store.Backend {
+	t.Helper()
+
+	if scheme == "sqlite" {
+		path += ".db"
+	}
+
+	b, err := store.Open(context.Background(), scheme+"://"+filepath.ToSlash(path), store.Options{ElemsInPage: 2, FlushInterval: time.Hour})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, b.Close(context.Background()))
+	})
+
+	return b
+}
+
+func toStore(b *store.Backend) migrator.Store {
+	return migrator.Store{Users: b.Users, Medium: b.Medium}
+}
+
+// fill adds three users, one without any sources and one with four, so
+// that its sources span pages. It returns the source of the first user
+// after it has been crawled.
+func fill(t *testing.T, b *store.Backend) store.Medium {
+	t.Helper()
+	ctx := context.Background()
+
+	a, err := b.Users.CreateNewUser(ctx, "a@example.com")
+	require.NoError(t, err)
+	bID, err := b.Users.CreateNewUser(ctx, "b@example.com")
+	require.NoError(t, err)
+	_, err = b.Users.CreateNewUser(ctx, "c@example.com")
+	require.NoError(t, err)
+
+	require.NoError(t, b.Medium.AddSource(ctx, a, "google.com"))
+	for _, s := range []string{"bing.com", "duckduckgo.com", "yahoo.com", "ask.com"} {
+		require.NoError(t, b.Medium.AddSource(ctx, bID, s))
+	}
+
+	sources, _, err := b.Medium.GetAllSourceData(ctx, a, "")
+	require.NoError(t, err)
+	require.Len(t, sources, 1)
+
+	m := sources[0]
+	m.Hit = 3
+	m.Multiplier = 1.5
+	m.Hash = "some-hash"
+	m.ModifiedDate = time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, b.Medium.UpdateSource(ctx, a, m))
+
+	return m
+}
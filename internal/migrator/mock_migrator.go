@@ -0,0 +1,132 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/ankur22/medium-picker/internal/migrator (interfaces: UserStorer,MediumSourceStorer)
+
+// Package migrator is a generated GoMock package.
+package migrator
+
+import (
+	context "context"
+	store "github.com/ankur22/medium-picker/internal/store"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockUserStorer is a mock of UserStorer interface
+type MockUserStorer struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserStorerMockRecorder
+}
+
+// MockUserStorerMockRecorder is the mock recorder for MockUserStorer
+type MockUserStorerMockRecorder struct {
+	mock *MockUserStorer
+}
+
+// NewMockUserStorer creates a new mock instance
+func NewMockUserStorer(ctrl *gomock.Controller) *MockUserStorer {
+	mock := &MockUserStorer{ctrl: ctrl}
+	mock.recorder = &MockUserStorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockUserStorer) EXPECT() *MockUserStorerMockRecorder {
+	return m.recorder
+}
+
+// ImportUser mocks base method
+func (m *MockUserStorer) ImportUser(arg0 context.Context, arg1 store.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportUser", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ImportUser indicates an expected call of ImportUser
+func (mr *MockUserStorerMockRecorder) ImportUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportUser", reflect.TypeOf((*MockUserStorer)(nil).ImportUser), arg0, arg1)
+}
+
+// ListUsers mocks base method
+func (m *MockUserStorer) ListUsers(arg0 context.Context) ([]store.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", arg0)
+	ret0, _ := ret[0].([]store.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers
+func (mr *MockUserStorerMockRecorder) ListUsers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockUserStorer)(nil).ListUsers), arg0)
+}
+
+// MockMediumSourceStorer is a mock of MediumSourceStorer interface
+type MockMediumSourceStorer struct {
+	ctrl     *gomock.Controller
+	recorder *MockMediumSourceStorerMockRecorder
+}
+
+// MockMediumSourceStorerMockRecorder is the mock recorder for MockMediumSourceStorer
+type MockMediumSourceStorerMockRecorder struct {
+	mock *MockMediumSourceStorer
+}
+
+// NewMockMediumSourceStorer creates a new mock instance
+func NewMockMediumSourceStorer(ctrl *gomock.Controller) *MockMediumSourceStorer {
+	mock := &MockMediumSourceStorer{ctrl: ctrl}
+	mock.recorder = &MockMediumSourceStorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockMediumSourceStorer) EXPECT() *MockMediumSourceStorerMockRecorder {
+	return m.recorder
+}
+
+// GetAllSourceData mocks base method
+func (m *MockMediumSourceStorer) GetAllSourceData(arg0 context.Context, arg1, arg2 string) ([]store.Medium, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllSourceData", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]store.Medium)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetAllSourceData indicates an expected call of GetAllSourceData
+func (mr *MockMediumSourceStorerMockRecorder) GetAllSourceData(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllSourceData", reflect.TypeOf((*MockMediumSourceStorer)(nil).GetAllSourceData), arg0, arg1, arg2)
+}
+
+// GetUserIDs mocks base method
+func (m *MockMediumSourceStorer) GetUserIDs(arg0 context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserIDs", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserIDs indicates an expected call of GetUserIDs
+func (mr *MockMediumSourceStorerMockRecorder) GetUserIDs(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserIDs", reflect.TypeOf((*MockMediumSourceStorer)(nil).GetUserIDs), arg0)
+}
+
+// ImportSource mocks base method
+func (m *MockMediumSourceStorer) ImportSource(arg0 context.Context, arg1 store.Medium) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportSource", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ImportSource indicates an expected call of ImportSource
+func (mr *MockMediumSourceStorerMockRecorder) ImportSource(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportSource", reflect.TypeOf((*MockMediumSourceStorer)(nil).ImportSource), arg0, arg1)
+}
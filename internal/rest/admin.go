@@ -0,0 +1,69 @@
+//go:generate mockgen -destination=mock_admin.go -package=rest github.com/ankur22/medium-picker/internal/rest StoreDumper
+
+package rest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/ankur22/medium-picker/internal/backup"
+	"github.com/ankur22/medium-picker/internal/logging"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+// StoreDumper interface to read every user and source for a backup
+type StoreDumper interface {
+	Dump(ctx context.Context) (*store.Dump, error)
+}
+
+// AdminHandler type for the endpoints that operate on the whole store.
+// They aren't scoped to a user, so they should be served on an address
+// that only the operators can reach.
+type AdminHandler struct {
+	d StoreDumper
+}
+
+// NewAdminHandler creates a new admin handler
+// The store cannot be nil
+func NewAdminHandler(d StoreDumper) *AdminHandler {
+	return &AdminHandler{d: d}
+}
+
+// Add will wire up the endpoints to the handler methods
+func (h *AdminHandler) Add(r *mux.Router) {
+	r.HandleFunc("/v1/admin/backup", h.Backup).Methods("GET")
+}
+
+// Backup responds with a backup of every user and source, in
+// the same archive format that the admin backup command writes
+func (h *AdminHandler) Backup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	defer r.Body.Close()
+
+	// The archive is built before anything is written so that
+	// a failure can still be responded to with a 500
+	var buf bytes.Buffer
+	m, err := backup.Write(ctx, h.d, &buf)
+	if err != nil {
+		logging.Error(ctx, "Failed to write backup", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", backup.Filename(m.CreatedDate)))
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		logging.Error(ctx, "failed to write backup response", zap.Error(err))
+		return
+	}
+
+	logging.Info(ctx, "Backup downloaded", zap.Int("users", m.Users), zap.Int("sources", m.Sources))
+}
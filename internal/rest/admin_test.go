@@ -0,0 +1,75 @@
+package rest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/backup"
+	"github.com/ankur22/medium-picker/internal/logging"
+	"github.com/ankur22/medium-picker/internal/rest"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+func TestAdminHandler_Backup(t *testing.T) {
+	_, _ = logging.TestContext(context.Background())
+
+	dump := &store.Dump{
+		Users: []store.User{{ID: "a09sd09sa8d0a8sd", Email: "test@email.com"}},
+		Sources: []store.Medium{
+			{ID: "1", URL: "google.com", UserID: "a09sd09sa8d0a8sd", CreatedDate: time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		dump     *store.Dump
+		dumpErr  error
+		wantCode int
+	}{
+		{
+			name:     "Backup downloaded",
+			dump:     dump,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Dump fails",
+			dumpErr:  errors.New("some error"),
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			d := rest.NewMockStoreDumper(ctrl)
+			d.EXPECT().Dump(gomock.Any()).Return(tt.dump, tt.dumpErr)
+
+			h := rest.NewAdminHandler(d)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/v1/admin/backup", nil)
+
+			h.Backup(resp, req)
+
+			assert.Equal(t, tt.wantCode, resp.Result().StatusCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "application/gzip", resp.Header().Get("Content-Type"))
+			got, _, err := backup.Read(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.dump, got)
+		})
+	}
+}
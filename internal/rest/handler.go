@@ -1,4 +1,4 @@
-//go:generate mockgen -destination=mock_store.go -package=rest github.com/ankur22/medium-picker/internal/rest UserStorer,MediumSourceStorer,MediumSourcePicker
+//go:generate mockgen -destination=mock_store.go -package=rest github.com/ankur22/medium-picker/internal/rest UserStorer,MediumSourceStorer,MediumSourcePicker,FeedbackLearner
 
 package rest
 
@@ -10,11 +10,13 @@ import (
 	"net/http"
 	"net/mail"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 
 	"github.com/ankur22/medium-picker/internal/logging"
+	"github.com/ankur22/medium-picker/internal/service"
 	"github.com/ankur22/medium-picker/internal/store"
 	pkgRest "github.com/ankur22/medium-picker/pkg/rest"
 )
@@ -24,18 +26,26 @@ type UserStorer interface {
 	CreateNewUser(ctx context.Context, email string) (string, error)
 	GetUser(ctx context.Context, email string) (string, error)
 	IsUser(ctx context.Context, userID string) (bool, error)
+	GetSettings(ctx context.Context, userID string) (store.Settings, error)
+	UpdateSettings(ctx context.Context, userID string, settings store.Settings) error
 }
 
 // MediumSourceStorer interface to retrieve medium sources
 type MediumSourceStorer interface {
 	AddSource(ctx context.Context, userID string, source string) error
-	GetSources(ctx context.Context, userID string, page int) ([]store.Source, error)
+	GetSources(ctx context.Context, userID string, cursor string) ([]store.Source, string, error)
+	GetSource(ctx context.Context, userID string, sourceID string) (store.Medium, error)
 	DeleteSource(ctx context.Context, userID string, sourceID string) error
 }
 
 // MediumSourcePicker interface to select the sources that are ready to be read
 type MediumSourcePicker interface {
-	Pick(ctx context.Context, userID string, count int) ([]store.Source, error)
+	Pick(ctx context.Context, userID string, count int, opts service.PickOptions) ([]store.Source, error)
+}
+
+// FeedbackLearner interface to learn from the feedback on the sources that were picked
+type FeedbackLearner interface {
+	Feedback(ctx context.Context, userID string, sourceID string, signal service.Signal) error
 }
 
 // Handler type for the REST service's endpoints
@@ -43,22 +53,27 @@ type Handler struct {
 	s UserStorer
 	m MediumSourceStorer
 	p MediumSourcePicker
+	l FeedbackLearner
 }
 
 // NewHandler creates a new handler
 // The stores cannot be nil
-func NewHandler(s UserStorer, m MediumSourceStorer, p MediumSourcePicker) *Handler {
-	return &Handler{s: s, m: m, p: p}
+func NewHandler(s UserStorer, m MediumSourceStorer, p MediumSourcePicker, l FeedbackLearner) *Handler {
+	return &Handler{s: s, m: m, p: p, l: l}
 }
 
 // Add will wire up the endpoints to the handler methods
 func (h *Handler) Add(r *mux.Router) {
 	r.HandleFunc("/v1/user", h.Signup).Methods("POST")
 	r.HandleFunc("/v1/user/login", h.SignIn).Methods("PUT")
+	r.HandleFunc("/v1/user/{userID}/settings", h.GetSettings).Methods("GET")
+	r.HandleFunc("/v1/user/{userID}/settings", h.UpdateSettings).Methods("PUT")
 	r.HandleFunc("/v1/user/{userID}/medium", h.AddMediumSource).Methods("POST")
-	r.HandleFunc("/v1/user/{userID}/medium", h.GetMediumSource).Methods("GET").Queries("p", "{page:[0-9]+}")
+	r.HandleFunc("/v1/user/{userID}/medium", h.GetMediumSource).Methods("GET")
 	r.HandleFunc("/v1/user/{userID}/medium/{sourceID}", h.DeleteMediumSource).Methods("DELETE")
 	r.HandleFunc("/v1/user/{userID}/medium/pick", h.PickSources).Methods("GET").Queries("c", "{count:[0-9]+}")
+	r.HandleFunc("/v1/user/{userID}/medium/{sourceID}", h.GetMediumSourceByID).Methods("GET")
+	r.HandleFunc("/v1/user/{userID}/medium/{sourceID}/feedback", h.SourceFeedback).Methods("POST")
 }
 
 // Signup is the handler that will create a new user
@@ -185,6 +200,121 @@ func (h *Handler) SignIn(w http.ResponseWriter, r *http.Request) {
 	logging.Info(ctx, "User signed in", zap.String("userId", id))
 }
 
+// GetSettings retrieves the settings of the specified userID
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	defer r.Body.Close()
+
+	params := mux.Vars(r)
+	userID := params["userID"]
+
+	ctx = logging.With(ctx, zap.String("userId", userID))
+
+	if err := h.isUser(ctx, userID, w); err != nil {
+		return
+	}
+
+	settings, err := h.s.GetSettings(ctx, userID)
+	if errors.Is(err, store.ErrUserNotFound) {
+		logging.Info(ctx, "UserID not found")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.Error(ctx, "Error from store", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resp := pkgRest.Settings{Strategy: settings.Strategy}
+	if settings.Cooldown > 0 {
+		resp.Cooldown = settings.Cooldown.String()
+	}
+
+	respB, err := json.Marshal(resp)
+	if err != nil {
+		logging.Error(ctx, "failed to marshall get settings response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(respB)
+	if err != nil {
+		logging.Error(ctx, "failed to write get settings response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateSettings replaces the settings of the specified userID. An empty
+// strategy goes back to the server's default, and an empty cooldown turns
+// it off.
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	defer r.Body.Close()
+
+	params := mux.Vars(r)
+	userID := params["userID"]
+
+	ctx = logging.With(ctx, zap.String("userId", userID))
+
+	if err := h.isUser(ctx, userID, w); err != nil {
+		return
+	}
+
+	rb := pkgRest.Settings{}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		logging.Error(ctx, "Can't read body", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = json.Unmarshal(b, &rb)
+	if err != nil {
+		logging.Error(ctx, "Can't unmarshall body", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if rb.Strategy != "" {
+		if _, err := service.LookupStrategy(rb.Strategy); err != nil {
+			logging.Info(ctx, "Strategy failed validation", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	settings := store.Settings{Strategy: rb.Strategy}
+	if rb.Cooldown != "" {
+		settings.Cooldown, err = time.ParseDuration(rb.Cooldown)
+		if err != nil || settings.Cooldown < 0 {
+			logging.Info(ctx, "Cooldown failed validation", zap.String("cooldown", rb.Cooldown))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = h.s.UpdateSettings(ctx, userID, settings)
+	if errors.Is(err, store.ErrUserNotFound) {
+		logging.Info(ctx, "UserID not found")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.Error(ctx, "Error from store", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	logging.Info(ctx, "Updated the settings")
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // AddMediumSource will add a new medium source for the userID
 func (h *Handler) AddMediumSource(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -233,7 +363,7 @@ func (h *Handler) AddMediumSource(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetMediumSource retrieves all the medium sources for the specified userID
-// The response is paginated and if nextPage in the response is non-nil then keep performing the request with query p=value of nextPage
+// The response is paginated and if nextPage in the response is non-empty then keep performing the request with query p=value of nextPage
 func (h *Handler) GetMediumSource(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -241,7 +371,7 @@ func (h *Handler) GetMediumSource(w http.ResponseWriter, r *http.Request) {
 
 	params := mux.Vars(r)
 	userID := params["userID"]
-	page := params["page"]
+	cursor := r.URL.Query().Get("p")
 
 	ctx = logging.With(ctx, zap.String("userId", userID))
 
@@ -249,27 +379,91 @@ func (h *Handler) GetMediumSource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	p, err := strconv.ParseInt(page, 10, 32)
+	srcs, next, err := h.m.GetSources(ctx, userID, cursor)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		logging.Info(ctx, "Page query is not a valid cursor", zap.String("page", cursor))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// The user exists, so a store that doesn't know them just
+	// means that they have no sources
+	if errors.Is(err, store.ErrUserNotFound) {
+		srcs, next, err = nil, "", nil
+	}
 	if err != nil {
-		logging.Error(ctx, "Page query cannot be parsed to int", zap.Error(err))
+		logging.Error(ctx, "Error from store", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	if p < 0 {
-		logging.Info(ctx, "Page query is less than 0", zap.Int("page", int(p)))
-		w.WriteHeader(http.StatusBadRequest)
+	resp := pkgRest.GetMediumSourcesResponse{
+		Sources:  toSourceResponse(srcs),
+		NextPage: next,
+	}
+
+	respB, err := json.Marshal(resp)
+	if err != nil {
+		logging.Error(ctx, "failed to marshall get sources response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(respB)
+	if err != nil {
+		logging.Error(ctx, "failed to write get sources response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetMediumSourceByID retrieves the source for the specified userID with sourceID
+func (h *Handler) GetMediumSourceByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	defer r.Body.Close()
+
+	params := mux.Vars(r)
+	userID := params["userID"]
+	sourceID := params["sourceID"]
+
+	ctx = logging.With(ctx, zap.String("userId", userID), zap.String("sourceID", sourceID))
+
+	if err := h.isUser(ctx, userID, w); err != nil {
 		return
 	}
 
-	srcs, err := h.m.GetSources(ctx, userID, int(p))
+	src, err := h.m.GetSource(ctx, userID, sourceID)
+	if errors.Is(err, store.ErrUserNotFound) || errors.Is(err, store.ErrCannotFindMedium) {
+		logging.Info(ctx, "Medium source not found")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		logging.Error(ctx, "Error from store", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	h.writeSourceResponse(ctx, w, srcs)
+	respB, err := json.Marshal(pkgRest.Source{
+		ID:        src.ID,
+		URL:       src.URL,
+		ItemTitle: src.LatestItemTitle,
+		ItemLink:  src.LatestItemLink,
+	})
+	if err != nil {
+		logging.Error(ctx, "failed to marshall get source response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(respB)
+	if err != nil {
+		logging.Error(ctx, "failed to write get source response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 }
 
 // DeleteMediumSource deletes the source for the specified userID with sourceID
@@ -298,7 +492,9 @@ func (h *Handler) DeleteMediumSource(w http.ResponseWriter, r *http.Request) {
 }
 
 // PickSources will return a list of sources that have recently updated
-// and haven't been read in a while
+// and haven't been read in a while. They're picked with the strategy in
+// the strategy query, or else the user's own default. With changed=true
+// the sites that changed since the user last read them are picked first.
 func (h *Handler) PickSources(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -327,7 +523,41 @@ func (h *Handler) PickSources(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	srcs, err := h.p.Pick(ctx, userID, int(c))
+	changedOnly := false
+	if changed := r.URL.Query().Get("changed"); changed != "" {
+		if changedOnly, err = strconv.ParseBool(changed); err != nil {
+			logging.Info(ctx, "Changed query cannot be parsed to bool", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	settings, err := h.settings(ctx, userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	strategy := r.URL.Query().Get("strategy")
+	if strategy == "" {
+		strategy = settings.Strategy
+	}
+
+	srcs, err := h.p.Pick(ctx, userID, int(c), service.PickOptions{
+		Strategy:    strategy,
+		ChangedOnly: changedOnly,
+		Cooldown:    settings.Cooldown,
+	})
+	if errors.Is(err, service.ErrUnknownStrategy) {
+		logging.Info(ctx, "Strategy query is not a known strategy", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// The user exists, so a store that doesn't know them just
+	// means that they have no sources to pick from
+	if errors.Is(err, store.ErrUserNotFound) {
+		srcs, err = nil, nil
+	}
 	if err != nil {
 		logging.Error(ctx, "Error from pickerer", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
@@ -337,6 +567,80 @@ func (h *Handler) PickSources(w http.ResponseWriter, r *http.Request) {
 	h.writeSourceResponse(ctx, w, srcs)
 }
 
+// settings are the user's own settings that a pick is made with. A
+// strategy that's no longer registered is left out, so that the picker's
+// default is used rather than failing every pick until the user changes it.
+func (h *Handler) settings(ctx context.Context, userID string) (store.Settings, error) {
+	settings, err := h.s.GetSettings(ctx, userID)
+	if err != nil {
+		logging.Error(ctx, "Error from store", zap.Error(err))
+		return store.Settings{}, err
+	}
+
+	if settings.Strategy != "" {
+		if _, err := service.LookupStrategy(settings.Strategy); err != nil {
+			logging.Error(ctx, "User's strategy is not a known strategy", zap.Error(err))
+			settings.Strategy = ""
+		}
+	}
+
+	return settings, nil
+}
+
+// SourceFeedback records how the user found the source with sourceID,
+// which changes how likely it is to be picked for them in the future
+func (h *Handler) SourceFeedback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	defer r.Body.Close()
+
+	params := mux.Vars(r)
+	userID := params["userID"]
+	sourceID := params["sourceID"]
+
+	ctx = logging.With(ctx, zap.String("userId", userID), zap.String("sourceID", sourceID))
+
+	if err := h.isUser(ctx, userID, w); err != nil {
+		return
+	}
+
+	rb := pkgRest.FeedbackRequest{}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		logging.Error(ctx, "Can't read body", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = json.Unmarshal(b, &rb)
+	if err != nil {
+		logging.Error(ctx, "Can't unmarshall body", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = h.l.Feedback(ctx, userID, sourceID, service.Signal(rb.Signal))
+	if errors.Is(err, service.ErrUnknownSignal) {
+		logging.Info(ctx, "Signal failed validation", zap.String("signal", rb.Signal))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, store.ErrUserNotFound) || errors.Is(err, store.ErrCannotFindMedium) {
+		logging.Info(ctx, "Medium source not found")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.Error(ctx, "Error from learner", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	logging.Info(ctx, "Recorded feedback", zap.String("signal", rb.Signal))
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *Handler) isUser(ctx context.Context, userID string, w http.ResponseWriter) error {
 	if ok, err := h.s.IsUser(ctx, userID); err != nil {
 		logging.Error(ctx, "Error from store", zap.Error(err))
@@ -351,15 +655,7 @@ func (h *Handler) isUser(ctx context.Context, userID string, w http.ResponseWrit
 }
 
 func (h *Handler) writeSourceResponse(ctx context.Context, w http.ResponseWriter, srcs []store.Source) {
-	resp := make([]pkgRest.Source, len(srcs))
-	for i, s := range srcs {
-		resp[i] = pkgRest.Source{
-			ID:  s.ID,
-			URL: s.URL,
-		}
-	}
-
-	respB, err := json.Marshal(resp)
+	respB, err := json.Marshal(toSourceResponse(srcs))
 	if err != nil {
 		logging.Error(ctx, "failed to marshall pick sources response", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
@@ -374,3 +670,17 @@ func (h *Handler) writeSourceResponse(ctx context.Context, w http.ResponseWriter
 		return
 	}
 }
+
+func toSourceResponse(srcs []store.Source) []pkgRest.Source {
+	resp := make([]pkgRest.Source, len(srcs))
+	for i, s := range srcs {
+		resp[i] = pkgRest.Source{
+			ID:               s.ID,
+			URL:              s.URL,
+			ItemTitle:        s.ItemTitle,
+			ItemLink:         s.ItemLink,
+			NewSinceLastRead: s.NewSinceLastRead,
+		}
+	}
+	return resp
+}
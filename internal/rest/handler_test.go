@@ -8,8 +8,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"testing"
+	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
@@ -17,6 +19,7 @@ import (
 
 	"github.com/ankur22/medium-picker/internal/logging"
 	"github.com/ankur22/medium-picker/internal/rest"
+	"github.com/ankur22/medium-picker/internal/service"
 	"github.com/ankur22/medium-picker/internal/store"
 	pkgRest "github.com/ankur22/medium-picker/pkg/rest"
 )
@@ -45,7 +48,7 @@ func TestHandler_Signup_Success(t *testing.T) {
 		s := rest.NewMockUserStorer(ctrl)
 		s.EXPECT().CreateNewUser(gomock.Any(), tt.email).Return(tt.userID, nil)
 
-		h := rest.NewHandler(s, nil, nil)
+		h := rest.NewHandler(s, nil, nil, nil)
 
 		reqB, err := json.Marshal(tt.body)
 		assert.NoError(t, err)
@@ -113,7 +116,7 @@ func TestHandler_Signup_Failure(t *testing.T) {
 			s.EXPECT().CreateNewUser(gomock.Any(), gomock.Any()).Return("", tt.storeError)
 		}
 
-		h := rest.NewHandler(s, nil, nil)
+		h := rest.NewHandler(s, nil, nil, nil)
 
 		reqB, err := json.Marshal(tt.body)
 		assert.NoError(t, err)
@@ -151,7 +154,7 @@ func TestHandler_SignIn_Success(t *testing.T) {
 		s := rest.NewMockUserStorer(ctrl)
 		s.EXPECT().GetUser(gomock.Any(), tt.email).Return(tt.userID, nil)
 
-		h := rest.NewHandler(s, nil, nil)
+		h := rest.NewHandler(s, nil, nil, nil)
 
 		reqB, err := json.Marshal(tt.body)
 		assert.NoError(t, err)
@@ -219,7 +222,7 @@ func TestHandler_SignIn_Failure(t *testing.T) {
 			s.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return("", tt.storeError)
 		}
 
-		h := rest.NewHandler(s, nil, nil)
+		h := rest.NewHandler(s, nil, nil, nil)
 
 		reqB, err := json.Marshal(tt.body)
 		assert.NoError(t, err)
@@ -233,6 +236,214 @@ func TestHandler_SignIn_Failure(t *testing.T) {
 	}
 }
 
+func TestHandler_GetSettings(t *testing.T) {
+	_, _ = logging.TestContext(context.Background())
+
+	tests := []struct {
+		name           string
+		userID         string
+		settings       store.Settings
+		userNotFound   bool
+		userStoreError error
+		storeError     error
+		expectedCode   int
+		expectedBody   pkgRest.Settings
+	}{
+		{
+			name:         "Default settings",
+			userID:       "ds098fa0s98fd0sa",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "With a strategy",
+			userID:       "ds098fa0s98fd0sa",
+			settings:     store.Settings{Strategy: service.StrategyFreshest},
+			expectedCode: http.StatusOK,
+			expectedBody: pkgRest.Settings{Strategy: service.StrategyFreshest},
+		},
+		{
+			name:         "With a cooldown",
+			userID:       "ds098fa0s98fd0sa",
+			settings:     store.Settings{Cooldown: 72 * time.Hour},
+			expectedCode: http.StatusOK,
+			expectedBody: pkgRest.Settings{Cooldown: "72h0m0s"},
+		},
+		{
+			name:         "User not found",
+			userID:       "ds098fa0s98fd0sa",
+			userNotFound: true,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:           "User store failed",
+			userID:         "ds098fa0s98fd0sa",
+			userStoreError: errors.New("some error"),
+			expectedCode:   http.StatusInternalServerError,
+		},
+		{
+			name:         "User removed during the request",
+			userID:       "ds098fa0s98fd0sa",
+			storeError:   store.ErrUserNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Store failed",
+			userID:       "ds098fa0s98fd0sa",
+			storeError:   errors.New("some error"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := rest.NewMockUserStorer(ctrl)
+			s.EXPECT().IsUser(gomock.Any(), tt.userID).Return(!tt.userNotFound, tt.userStoreError)
+			if !tt.userNotFound && tt.userStoreError == nil {
+				s.EXPECT().GetSettings(gomock.Any(), tt.userID).Return(tt.settings, tt.storeError)
+			}
+
+			h := rest.NewHandler(s, nil, nil, nil)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			req = mux.SetURLVars(req, map[string]string{"userID": tt.userID})
+
+			h.GetSettings(resp, req)
+
+			assert.Equal(t, tt.expectedCode, resp.Result().StatusCode)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			defer resp.Result().Body.Close()
+			var rBody pkgRest.Settings
+			err := json.NewDecoder(resp.Result().Body).Decode(&rBody)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedBody, rBody)
+		})
+	}
+}
+
+func TestHandler_UpdateSettings(t *testing.T) {
+	_, _ = logging.TestContext(context.Background())
+
+	tests := []struct {
+		name           string
+		userID         string
+		body           interface{}
+		wantSettings   *store.Settings
+		userNotFound   bool
+		userStoreError error
+		storeError     error
+		expectedCode   int
+	}{
+		{
+			name:         "Set a strategy",
+			userID:       "ds098fa0s98fd0sa",
+			body:         pkgRest.Settings{Strategy: service.StrategyLeastRecentlyRead},
+			wantSettings: &store.Settings{Strategy: service.StrategyLeastRecentlyRead},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Go back to the default",
+			userID:       "ds098fa0s98fd0sa",
+			body:         pkgRest.Settings{},
+			wantSettings: &store.Settings{},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Set a cooldown",
+			userID:       "ds098fa0s98fd0sa",
+			body:         pkgRest.Settings{Cooldown: "72h"},
+			wantSettings: &store.Settings{Cooldown: 72 * time.Hour},
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Unknown strategy",
+			userID:       "ds098fa0s98fd0sa",
+			body:         pkgRest.Settings{Strategy: "unknown"},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Invalid cooldown",
+			userID:       "ds098fa0s98fd0sa",
+			body:         pkgRest.Settings{Cooldown: "3 days"},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Negative cooldown",
+			userID:       "ds098fa0s98fd0sa",
+			body:         pkgRest.Settings{Cooldown: "-1h"},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Invalid body",
+			userID:       "ds098fa0s98fd0sa",
+			body:         "not settings",
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "User not found",
+			userID:       "ds098fa0s98fd0sa",
+			body:         pkgRest.Settings{Strategy: service.StrategyHits},
+			userNotFound: true,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:           "User store failed",
+			userID:         "ds098fa0s98fd0sa",
+			body:           pkgRest.Settings{Strategy: service.StrategyHits},
+			userStoreError: errors.New("some error"),
+			expectedCode:   http.StatusInternalServerError,
+		},
+		{
+			name:         "User removed during the request",
+			userID:       "ds098fa0s98fd0sa",
+			body:         pkgRest.Settings{Strategy: service.StrategyHits},
+			wantSettings: &store.Settings{Strategy: service.StrategyHits},
+			storeError:   store.ErrUserNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Store failed",
+			userID:       "ds098fa0s98fd0sa",
+			body:         pkgRest.Settings{Strategy: service.StrategyHits},
+			wantSettings: &store.Settings{Strategy: service.StrategyHits},
+			storeError:   errors.New("some error"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := rest.NewMockUserStorer(ctrl)
+			s.EXPECT().IsUser(gomock.Any(), tt.userID).Return(!tt.userNotFound, tt.userStoreError)
+			if tt.wantSettings != nil {
+				s.EXPECT().UpdateSettings(gomock.Any(), tt.userID, *tt.wantSettings).Return(tt.storeError)
+			}
+
+			h := rest.NewHandler(s, nil, nil, nil)
+
+			reqB, err := json.Marshal(tt.body)
+			assert.NoError(t, err)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest("PUT", "/", bytes.NewBuffer(reqB))
+			req = mux.SetURLVars(req, map[string]string{"userID": tt.userID})
+
+			h.UpdateSettings(resp, req)
+
+			assert.Equal(t, tt.expectedCode, resp.Result().StatusCode)
+		})
+	}
+}
+
 func TestHandler_AddMediumSource_Success(t *testing.T) {
 	_, _ = logging.TestContext(context.Background())
 
@@ -260,7 +471,7 @@ func TestHandler_AddMediumSource_Success(t *testing.T) {
 		m := rest.NewMockMediumSourceStorer(ctrl)
 		m.EXPECT().AddSource(gomock.Any(), tt.userID, tt.source).Return(nil)
 
-		h := rest.NewHandler(s, m, nil)
+		h := rest.NewHandler(s, m, nil, nil)
 
 		reqB, err := json.Marshal(tt.body)
 		assert.NoError(t, err)
@@ -335,7 +546,7 @@ func TestHandler_AddMediumSource_Failure(t *testing.T) {
 			m.EXPECT().AddSource(gomock.Any(), tt.userID, gomock.Any()).Return(tt.sourceError)
 		}
 
-		h := rest.NewHandler(s, m, nil)
+		h := rest.NewHandler(s, m, nil, nil)
 
 		reqB, err := json.Marshal(tt.body)
 		assert.NoError(t, err)
@@ -355,17 +566,57 @@ func TestHandler_GetMediumSource_Success(t *testing.T) {
 
 	tests := []struct {
 		name        string
-		page        int
+		page        string
 		userID      string
 		storeResult []store.Source
+		storeNext   string
+		storeError  error
+		want        pkgRest.GetMediumSourcesResponse
 	}{
 		{
-			name:   "Get sources",
-			page:   0,
+			name:   "Get first page",
+			page:   "",
 			userID: "ds098fa0s98fd0sa",
 			storeResult: []store.Source{
 				{ID: "1", URL: "google.com"}, {ID: "2", URL: "yahoo.com"},
 			},
+			storeNext: "some-cursor",
+			want: pkgRest.GetMediumSourcesResponse{
+				Sources: []pkgRest.Source{
+					{ID: "1", URL: "google.com"}, {ID: "2", URL: "yahoo.com"},
+				},
+				NextPage: "some-cursor",
+			},
+		},
+		{
+			name:   "Get last page",
+			page:   "some-cursor",
+			userID: "ds098fa0s98fd0sa",
+			storeResult: []store.Source{
+				{ID: "3", URL: "bing.com"},
+			},
+			want: pkgRest.GetMediumSourcesResponse{
+				Sources: []pkgRest.Source{
+					{ID: "3", URL: "bing.com"},
+				},
+			},
+		},
+		{
+			name:   "No sources",
+			page:   "",
+			userID: "ds098fa0s98fd0sa",
+			want: pkgRest.GetMediumSourcesResponse{
+				Sources: []pkgRest.Source{},
+			},
+		},
+		{
+			name:       "User the store doesn't know",
+			page:       "",
+			userID:     "ds098fa0s98fd0sa",
+			storeError: store.ErrUserNotFound,
+			want: pkgRest.GetMediumSourcesResponse{
+				Sources: []pkgRest.Source{},
+			},
 		},
 	}
 
@@ -377,13 +628,13 @@ func TestHandler_GetMediumSource_Success(t *testing.T) {
 		s.EXPECT().IsUser(gomock.Any(), tt.userID).Return(true, nil)
 
 		m := rest.NewMockMediumSourceStorer(ctrl)
-		m.EXPECT().GetSources(gomock.Any(), tt.userID, tt.page).Return(tt.storeResult, nil)
+		m.EXPECT().GetSources(gomock.Any(), tt.userID, tt.page).Return(tt.storeResult, tt.storeNext, tt.storeError)
 
-		h := rest.NewHandler(s, m, nil)
+		h := rest.NewHandler(s, m, nil, nil)
 
 		resp := httptest.NewRecorder()
-		req := httptest.NewRequest("GET", "/", nil)
-		req = mux.SetURLVars(req, map[string]string{"userID": tt.userID, "page": strconv.Itoa(tt.page)})
+		req := httptest.NewRequest("GET", "/?p="+url.QueryEscape(tt.page), nil)
+		req = mux.SetURLVars(req, map[string]string{"userID": tt.userID})
 
 		h.GetMediumSource(resp, req)
 
@@ -393,10 +644,10 @@ func TestHandler_GetMediumSource_Success(t *testing.T) {
 		bs, err := ioutil.ReadAll(resp.Result().Body)
 		assert.NoError(t, err)
 
-		var rBody []store.Source
+		var rBody pkgRest.GetMediumSourcesResponse
 		err = json.Unmarshal(bs, &rBody)
 		assert.NoError(t, err)
-		assert.ElementsMatch(t, tt.storeResult, rBody)
+		assert.Equal(t, tt.want, rBody)
 	}
 }
 
@@ -405,9 +656,8 @@ func TestHandler_GetMediumSource_Failure(t *testing.T) {
 
 	tests := []struct {
 		name           string
-		body           interface{}
 		userID         string
-		page           int
+		page           string
 		expectedError  int
 		userFound      bool
 		userStoreError error
@@ -415,37 +665,30 @@ func TestHandler_GetMediumSource_Failure(t *testing.T) {
 	}{
 		{
 			name:           "User not found",
-			body:           pkgRest.NewMediumSourceRequest{Source: "google.com/news"},
 			userID:         "ds098fa0s98fd0sa",
-			page:           0,
 			expectedError:  http.StatusNotFound,
 			userFound:      false,
 			userStoreError: nil,
 		},
 		{
 			name:           "User store errors",
-			body:           pkgRest.NewMediumSourceRequest{Source: "google.com/news"},
 			userID:         "ds098fa0s98fd0sa",
-			page:           0,
 			expectedError:  http.StatusInternalServerError,
 			userFound:      false,
 			userStoreError: errors.New("some error"),
 		},
 		{
-			name:           "page less than 0",
-			body:           pkgRest.NewMediumSourceRequest{Source: "google.com/news"},
+			name:           "invalid page cursor",
 			userID:         "ds098fa0s98fd0sa",
-			page:           -1,
+			page:           "not-a-cursor",
 			expectedError:  http.StatusBadRequest,
 			userFound:      true,
 			userStoreError: nil,
-			sourceError:    nil,
+			sourceError:    store.ErrInvalidCursor,
 		},
 		{
 			name:           "Source store error",
-			body:           pkgRest.NewMediumSourceRequest{Source: "google.com/news"},
 			userID:         "ds098fa0s98fd0sa",
-			page:           0,
 			expectedError:  http.StatusInternalServerError,
 			userFound:      true,
 			userStoreError: nil,
@@ -462,14 +705,14 @@ func TestHandler_GetMediumSource_Failure(t *testing.T) {
 
 		m := rest.NewMockMediumSourceStorer(ctrl)
 		if tt.userFound && tt.sourceError != nil {
-			m.EXPECT().GetSources(gomock.Any(), tt.userID, tt.page).Return(nil, tt.sourceError)
+			m.EXPECT().GetSources(gomock.Any(), tt.userID, tt.page).Return(nil, "", tt.sourceError)
 		}
 
-		h := rest.NewHandler(s, m, nil)
+		h := rest.NewHandler(s, m, nil, nil)
 
 		resp := httptest.NewRecorder()
-		req := httptest.NewRequest("GET", "/", nil)
-		req = mux.SetURLVars(req, map[string]string{"userID": tt.userID, "page": strconv.Itoa(tt.page)})
+		req := httptest.NewRequest("GET", "/?p="+url.QueryEscape(tt.page), nil)
+		req = mux.SetURLVars(req, map[string]string{"userID": tt.userID})
 
 		h.GetMediumSource(resp, req)
 
@@ -477,6 +720,103 @@ func TestHandler_GetMediumSource_Failure(t *testing.T) {
 	}
 }
 
+func TestHandler_GetMediumSourceByID(t *testing.T) {
+	_, _ = logging.TestContext(context.Background())
+
+	tests := []struct {
+		name         string
+		userID       string
+		sourceID     string
+		userFound    bool
+		storeResult  store.Medium
+		storeError   error
+		expectedCode int
+		expectedBody pkgRest.Source
+	}{
+		{
+			name:      "Get source",
+			userID:    "ds098fa0s98fd0sa",
+			sourceID:  "kjn4t43wknt",
+			userFound: true,
+			storeResult: store.Medium{
+				ID:              "kjn4t43wknt",
+				URL:             "google.com",
+				LatestItemTitle: "Some post",
+				LatestItemLink:  "google.com/some-post",
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: pkgRest.Source{
+				ID:        "kjn4t43wknt",
+				URL:       "google.com",
+				ItemTitle: "Some post",
+				ItemLink:  "google.com/some-post",
+			},
+		},
+		{
+			name:         "User not found",
+			userID:       "ds098fa0s98fd0sa",
+			sourceID:     "kjn4t43wknt",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Source not found",
+			userID:       "ds098fa0s98fd0sa",
+			sourceID:     "kjn4t43wknt",
+			userFound:    true,
+			storeError:   store.ErrCannotFindMedium,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "User has no sources",
+			userID:       "ds098fa0s98fd0sa",
+			sourceID:     "kjn4t43wknt",
+			userFound:    true,
+			storeError:   store.ErrUserNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Source store error",
+			userID:       "ds098fa0s98fd0sa",
+			sourceID:     "kjn4t43wknt",
+			userFound:    true,
+			storeError:   errors.New("some error"),
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := rest.NewMockUserStorer(ctrl)
+			s.EXPECT().IsUser(gomock.Any(), tt.userID).Return(tt.userFound, nil)
+
+			m := rest.NewMockMediumSourceStorer(ctrl)
+			if tt.userFound {
+				m.EXPECT().GetSource(gomock.Any(), tt.userID, tt.sourceID).Return(tt.storeResult, tt.storeError)
+			}
+
+			h := rest.NewHandler(s, m, nil, nil)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			req = mux.SetURLVars(req, map[string]string{"userID": tt.userID, "sourceID": tt.sourceID})
+
+			h.GetMediumSourceByID(resp, req)
+
+			assert.Equal(t, tt.expectedCode, resp.Result().StatusCode)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var got pkgRest.Source
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+			assert.Equal(t, tt.expectedBody, got)
+		})
+	}
+}
+
 func TestHandler_DeleteMediumSource_Success(t *testing.T) {
 	_, _ = logging.TestContext(context.Background())
 
@@ -502,7 +842,7 @@ func TestHandler_DeleteMediumSource_Success(t *testing.T) {
 		m := rest.NewMockMediumSourceStorer(ctrl)
 		m.EXPECT().DeleteSource(gomock.Any(), tt.userID, tt.sourceID).Return(nil)
 
-		h := rest.NewHandler(s, m, nil)
+		h := rest.NewHandler(s, m, nil, nil)
 
 		resp := httptest.NewRecorder()
 		req := httptest.NewRequest("DELETE", "/", nil)
@@ -565,7 +905,7 @@ func TestHandler_DeleteMediumSource_Failure(t *testing.T) {
 			m.EXPECT().DeleteSource(gomock.Any(), tt.userID, tt.sourceID).Return(tt.sourceError)
 		}
 
-		h := rest.NewHandler(s, m, nil)
+		h := rest.NewHandler(s, m, nil, nil)
 
 		resp := httptest.NewRecorder()
 		req := httptest.NewRequest("DELETE", "/", nil)
@@ -581,18 +921,90 @@ func TestHandler_PickSources_Success(t *testing.T) {
 	_, _ = logging.TestContext(context.Background())
 
 	tests := []struct {
-		name        string
-		count       int
-		userID      string
-		storeResult []store.Source
+		name         string
+		count        int
+		userID       string
+		target       string
+		settings     store.Settings
+		wantStrategy string
+		wantChanged  bool
+		wantCooldown time.Duration
+		storeResult  []store.Source
+		storeError   error
 	}{
 		{
-			name:   "Pick sources",
-			count:  0,
-			userID: "ds098fa0s98fd0sa",
+			name:     "Pick sources",
+			count:    0,
+			userID:   "ds098fa0s98fd0sa",
+			target:   "/",
+			settings: store.Settings{},
 			storeResult: []store.Source{
 				{ID: "1", URL: "google.com"}, {ID: "2", URL: "yahoo.com"},
 			},
+		}, {
+			name:     "Pick sources with feeds",
+			count:    1,
+			userID:   "ds098fa0s98fd0sa",
+			target:   "/",
+			settings: store.Settings{},
+			storeResult: []store.Source{
+				{ID: "1", URL: "blog.golang.org", ItemTitle: "Go 1.15 is released", ItemLink: "https://blog.golang.org/go1.15"},
+			},
+		}, {
+			name:         "Pick sources with the strategy query",
+			count:        1,
+			userID:       "ds098fa0s98fd0sa",
+			target:       "/?strategy=freshest",
+			wantStrategy: service.StrategyFreshest,
+			storeResult:  []store.Source{{ID: "1", URL: "google.com"}},
+		}, {
+			name:         "Pick sources with the user's strategy",
+			count:        1,
+			userID:       "ds098fa0s98fd0sa",
+			target:       "/",
+			settings:     store.Settings{Strategy: service.StrategyRoundRobin},
+			wantStrategy: service.StrategyRoundRobin,
+			storeResult:  []store.Source{{ID: "1", URL: "google.com"}},
+		}, {
+			name:         "Strategy query overrides the user's strategy",
+			count:        1,
+			userID:       "ds098fa0s98fd0sa",
+			target:       "/?strategy=hits",
+			settings:     store.Settings{Strategy: service.StrategyRoundRobin},
+			wantStrategy: service.StrategyHits,
+			storeResult:  []store.Source{{ID: "1", URL: "google.com"}},
+		}, {
+			name:        "Pick the changed sources",
+			count:       2,
+			userID:      "ds098fa0s98fd0sa",
+			target:      "/?changed=true",
+			settings:    store.Settings{},
+			wantChanged: true,
+			storeResult: []store.Source{
+				{ID: "1", URL: "google.com", NewSinceLastRead: true}, {ID: "2", URL: "yahoo.com"},
+			},
+		}, {
+			name:         "Pick sources with the user's cooldown",
+			count:        1,
+			userID:       "ds098fa0s98fd0sa",
+			target:       "/",
+			settings:     store.Settings{Cooldown: 72 * time.Hour},
+			wantCooldown: 72 * time.Hour,
+			storeResult:  []store.Source{{ID: "1", URL: "google.com"}},
+		}, {
+			name:        "User's strategy that isn't registered is ignored",
+			count:       1,
+			userID:      "ds098fa0s98fd0sa",
+			target:      "/",
+			settings:    store.Settings{Strategy: "removed"},
+			storeResult: []store.Source{{ID: "1", URL: "google.com"}},
+		}, {
+			name:       "User the store doesn't know",
+			count:      1,
+			userID:     "ds098fa0s98fd0sa",
+			target:     "/",
+			settings:   store.Settings{},
+			storeError: service.ErrFailedGetAllSources.Wrap(store.ErrUserNotFound),
 		},
 	}
 
@@ -602,14 +1014,19 @@ func TestHandler_PickSources_Success(t *testing.T) {
 
 		s := rest.NewMockUserStorer(ctrl)
 		s.EXPECT().IsUser(gomock.Any(), tt.userID).Return(true, nil)
+		s.EXPECT().GetSettings(gomock.Any(), tt.userID).Return(tt.settings, nil)
 
 		p := rest.NewMockMediumSourcePicker(ctrl)
-		p.EXPECT().Pick(gomock.Any(), tt.userID, tt.count).Return(tt.storeResult, nil)
+		p.EXPECT().Pick(gomock.Any(), tt.userID, tt.count, service.PickOptions{
+			Strategy:    tt.wantStrategy,
+			ChangedOnly: tt.wantChanged,
+			Cooldown:    tt.wantCooldown,
+		}).Return(tt.storeResult, tt.storeError)
 
-		h := rest.NewHandler(s, nil, p)
+		h := rest.NewHandler(s, nil, p, nil)
 
 		resp := httptest.NewRecorder()
-		req := httptest.NewRequest("GET", "/", nil)
+		req := httptest.NewRequest("GET", tt.target, nil)
 		req = mux.SetURLVars(req, map[string]string{"userID": tt.userID, "count": strconv.Itoa(tt.count)})
 
 		h.PickSources(resp, req)
@@ -636,8 +1053,11 @@ func TestHandler_PickSources_Failure(t *testing.T) {
 		userID         string
 		count          int
 		expectedError  int
+		target         string
 		userFound      bool
 		userStoreError error
+		wantSettings   bool
+		settingsError  error
 		sourceError    error
 	}{
 		{
@@ -676,26 +1096,62 @@ func TestHandler_PickSources_Failure(t *testing.T) {
 			expectedError:  http.StatusInternalServerError,
 			userFound:      true,
 			userStoreError: nil,
+			wantSettings:   true,
 			sourceError:    errors.New("some error"),
 		},
+		{
+			name:          "Settings store error",
+			userID:        "ds098fa0s98fd0sa",
+			count:         0,
+			expectedError: http.StatusInternalServerError,
+			userFound:     true,
+			wantSettings:  true,
+			settingsError: errors.New("some error"),
+		},
+		{
+			name:          "Invalid changed query",
+			userID:        "ds098fa0s98fd0sa",
+			count:         0,
+			target:        "/?changed=maybe",
+			expectedError: http.StatusBadRequest,
+			userFound:     true,
+		},
+		{
+			name:          "Unknown strategy",
+			userID:        "ds098fa0s98fd0sa",
+			count:         0,
+			target:        "/?strategy=unknown",
+			expectedError: http.StatusBadRequest,
+			userFound:     true,
+			wantSettings:  true,
+			sourceError:   service.ErrUnknownStrategy.Wrap(errors.New("unknown")),
+		},
 	}
 
 	for _, tt := range tests {
 		ctrl := gomock.NewController(t)
 		defer ctrl.Finish()
 
+		target := tt.target
+		if target == "" {
+			target = "/"
+		}
+
 		s := rest.NewMockUserStorer(ctrl)
 		s.EXPECT().IsUser(gomock.Any(), tt.userID).Return(tt.userFound, tt.userStoreError)
+		if tt.wantSettings {
+			s.EXPECT().GetSettings(gomock.Any(), tt.userID).Return(store.Settings{}, tt.settingsError)
+		}
 
 		p := rest.NewMockMediumSourcePicker(ctrl)
 		if tt.userFound && tt.sourceError != nil {
-			p.EXPECT().Pick(gomock.Any(), tt.userID, tt.count).Return(nil, tt.sourceError)
+			p.EXPECT().Pick(gomock.Any(), tt.userID, tt.count, gomock.Any()).Return(nil, tt.sourceError)
 		}
 
-		h := rest.NewHandler(s, nil, p)
+		h := rest.NewHandler(s, nil, p, nil)
 
 		resp := httptest.NewRecorder()
-		req := httptest.NewRequest("GET", "/", nil)
+		req := httptest.NewRequest("GET", target, nil)
 		req = mux.SetURLVars(req, map[string]string{"userID": tt.userID, "count": strconv.Itoa(tt.count)})
 
 		h.PickSources(resp, req)
@@ -703,3 +1159,110 @@ func TestHandler_PickSources_Failure(t *testing.T) {
 		assert.Equal(t, tt.expectedError, resp.Result().StatusCode)
 	}
 }
+
+func TestHandler_SourceFeedback(t *testing.T) {
+	_, _ = logging.TestContext(context.Background())
+
+	tests := []struct {
+		name           string
+		userID         string
+		sourceID       string
+		body           interface{}
+		userFound      bool
+		userStoreError error
+		wantSignal     service.Signal
+		learnerError   error
+		expectedCode   int
+	}{
+		{
+			name:         "Loved",
+			userID:       "ds098fa0s98fd0sa",
+			sourceID:     "1",
+			body:         pkgRest.FeedbackRequest{Signal: "loved"},
+			userFound:    true,
+			wantSignal:   service.SignalLoved,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Unknown signal",
+			userID:       "ds098fa0s98fd0sa",
+			sourceID:     "1",
+			body:         pkgRest.FeedbackRequest{Signal: "meh"},
+			userFound:    true,
+			wantSignal:   "meh",
+			learnerError: service.ErrUnknownSignal,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Source not found",
+			userID:       "ds098fa0s98fd0sa",
+			sourceID:     "1",
+			body:         pkgRest.FeedbackRequest{Signal: "read"},
+			userFound:    true,
+			wantSignal:   service.SignalRead,
+			learnerError: store.ErrCannotFindMedium,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "Learner errors",
+			userID:       "ds098fa0s98fd0sa",
+			sourceID:     "1",
+			body:         pkgRest.FeedbackRequest{Signal: "skipped"},
+			userFound:    true,
+			wantSignal:   service.SignalSkipped,
+			learnerError: errors.New("some error"),
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "Invalid body",
+			userID:       "ds098fa0s98fd0sa",
+			sourceID:     "1",
+			body:         []string{"loved"},
+			userFound:    true,
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "User not found",
+			userID:       "ds098fa0s98fd0sa",
+			sourceID:     "1",
+			body:         pkgRest.FeedbackRequest{Signal: "loved"},
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:           "User store errors",
+			userID:         "ds098fa0s98fd0sa",
+			sourceID:       "1",
+			body:           pkgRest.FeedbackRequest{Signal: "loved"},
+			userStoreError: errors.New("some error"),
+			expectedCode:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := rest.NewMockUserStorer(ctrl)
+			s.EXPECT().IsUser(gomock.Any(), tt.userID).Return(tt.userFound, tt.userStoreError)
+
+			l := rest.NewMockFeedbackLearner(ctrl)
+			if tt.wantSignal != "" {
+				l.EXPECT().Feedback(gomock.Any(), tt.userID, tt.sourceID, tt.wantSignal).Return(tt.learnerError)
+			}
+
+			h := rest.NewHandler(s, nil, nil, l)
+
+			reqB, err := json.Marshal(tt.body)
+			assert.NoError(t, err)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/", bytes.NewBuffer(reqB))
+			req = mux.SetURLVars(req, map[string]string{"userID": tt.userID, "sourceID": tt.sourceID})
+
+			h.SourceFeedback(resp, req)
+
+			assert.Equal(t, tt.expectedCode, resp.Result().StatusCode)
+		})
+	}
+}
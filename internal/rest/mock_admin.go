@@ -0,0 +1,50 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/ankur22/medium-picker/internal/rest (interfaces: StoreDumper)
+
+// Package rest is a generated GoMock package.
+package rest
+
+import (
+	context "context"
+	store "github.com/ankur22/medium-picker/internal/store"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockStoreDumper is a mock of StoreDumper interface
+type MockStoreDumper struct {
+	ctrl     *gomock.Controller
+	recorder *MockStoreDumperMockRecorder
+}
+
+// MockStoreDumperMockRecorder is the mock recorder for MockStoreDumper
+type MockStoreDumperMockRecorder struct {
+	mock *MockStoreDumper
+}
+
+// NewMockStoreDumper creates a new mock instance
+func NewMockStoreDumper(ctrl *gomock.Controller) *MockStoreDumper {
+	mock := &MockStoreDumper{ctrl: ctrl}
+	mock.recorder = &MockStoreDumperMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockStoreDumper) EXPECT() *MockStoreDumperMockRecorder {
+	return m.recorder
+}
+
+// Dump mocks base method
+func (m *MockStoreDumper) Dump(arg0 context.Context) (*store.Dump, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Dump", arg0)
+	ret0, _ := ret[0].(*store.Dump)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Dump indicates an expected call of Dump
+func (mr *MockStoreDumperMockRecorder) Dump(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dump", reflect.TypeOf((*MockStoreDumper)(nil).Dump), arg0)
+}
@@ -1,11 +1,12 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/ankur22/medium-picker/internal/rest (interfaces: UserStorer,MediumSourceStorer,MediumSourcePicker)
+// Source: github.com/ankur22/medium-picker/internal/rest (interfaces: UserStorer,MediumSourceStorer,MediumSourcePicker,FeedbackLearner)
 
 // Package rest is a generated GoMock package.
 package rest
 
 import (
 	context "context"
+	service "github.com/ankur22/medium-picker/internal/service"
 	store "github.com/ankur22/medium-picker/internal/store"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
@@ -49,6 +50,21 @@ func (mr *MockUserStorerMockRecorder) CreateNewUser(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNewUser", reflect.TypeOf((*MockUserStorer)(nil).CreateNewUser), arg0, arg1)
 }
 
+// GetSettings mocks base method
+func (m *MockUserStorer) GetSettings(arg0 context.Context, arg1 string) (store.Settings, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSettings", arg0, arg1)
+	ret0, _ := ret[0].(store.Settings)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSettings indicates an expected call of GetSettings
+func (mr *MockUserStorerMockRecorder) GetSettings(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSettings", reflect.TypeOf((*MockUserStorer)(nil).GetSettings), arg0, arg1)
+}
+
 // GetUser mocks base method
 func (m *MockUserStorer) GetUser(arg0 context.Context, arg1 string) (string, error) {
 	m.ctrl.T.Helper()
@@ -79,6 +95,20 @@ func (mr *MockUserStorerMockRecorder) IsUser(arg0, arg1 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsUser", reflect.TypeOf((*MockUserStorer)(nil).IsUser), arg0, arg1)
 }
 
+// UpdateSettings mocks base method
+func (m *MockUserStorer) UpdateSettings(arg0 context.Context, arg1 string, arg2 store.Settings) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSettings", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSettings indicates an expected call of UpdateSettings
+func (mr *MockUserStorerMockRecorder) UpdateSettings(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSettings", reflect.TypeOf((*MockUserStorer)(nil).UpdateSettings), arg0, arg1, arg2)
+}
+
 // MockMediumSourceStorer is a mock of MediumSourceStorer interface
 type MockMediumSourceStorer struct {
 	ctrl     *gomock.Controller
@@ -130,13 +160,29 @@ func (mr *MockMediumSourceStorerMockRecorder) DeleteSource(arg0, arg1, arg2 inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSource", reflect.TypeOf((*MockMediumSourceStorer)(nil).DeleteSource), arg0, arg1, arg2)
 }
 
+// GetSource mocks base method
+func (m *MockMediumSourceStorer) GetSource(arg0 context.Context, arg1, arg2 string) (store.Medium, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSource", arg0, arg1, arg2)
+	ret0, _ := ret[0].(store.Medium)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSource indicates an expected call of GetSource
+func (mr *MockMediumSourceStorerMockRecorder) GetSource(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSource", reflect.TypeOf((*MockMediumSourceStorer)(nil).GetSource), arg0, arg1, arg2)
+}
+
 // GetSources mocks base method
-func (m *MockMediumSourceStorer) GetSources(arg0 context.Context, arg1 string, arg2 int) ([]store.Source, error) {
+func (m *MockMediumSourceStorer) GetSources(arg0 context.Context, arg1, arg2 string) ([]store.Source, string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetSources", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]store.Source)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // GetSources indicates an expected call of GetSources
@@ -169,16 +215,53 @@ func (m *MockMediumSourcePicker) EXPECT() *MockMediumSourcePickerMockRecorder {
 }
 
 // Pick mocks base method
-func (m *MockMediumSourcePicker) Pick(arg0 context.Context, arg1 string, arg2 int) ([]store.Source, error) {
+func (m *MockMediumSourcePicker) Pick(arg0 context.Context, arg1 string, arg2 int, arg3 service.PickOptions) ([]store.Source, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Pick", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "Pick", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].([]store.Source)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Pick indicates an expected call of Pick
-func (mr *MockMediumSourcePickerMockRecorder) Pick(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockMediumSourcePickerMockRecorder) Pick(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pick", reflect.TypeOf((*MockMediumSourcePicker)(nil).Pick), arg0, arg1, arg2, arg3)
+}
+
+// MockFeedbackLearner is a mock of FeedbackLearner interface
+type MockFeedbackLearner struct {
+	ctrl     *gomock.Controller
+	recorder *MockFeedbackLearnerMockRecorder
+}
+
+// MockFeedbackLearnerMockRecorder is the mock recorder for MockFeedbackLearner
+type MockFeedbackLearnerMockRecorder struct {
+	mock *MockFeedbackLearner
+}
+
+// NewMockFeedbackLearner creates a new mock instance
+func NewMockFeedbackLearner(ctrl *gomock.Controller) *MockFeedbackLearner {
+	mock := &MockFeedbackLearner{ctrl: ctrl}
+	mock.recorder = &MockFeedbackLearnerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockFeedbackLearner) EXPECT() *MockFeedbackLearnerMockRecorder {
+	return m.recorder
+}
+
+// Feedback mocks base method
+func (m *MockFeedbackLearner) Feedback(arg0 context.Context, arg1, arg2 string, arg3 service.Signal) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Feedback", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Feedback indicates an expected call of Feedback
+func (mr *MockFeedbackLearnerMockRecorder) Feedback(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pick", reflect.TypeOf((*MockMediumSourcePicker)(nil).Pick), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Feedback", reflect.TypeOf((*MockFeedbackLearner)(nil).Feedback), arg0, arg1, arg2, arg3)
 }
@@ -0,0 +1,133 @@
+//go:generate mockgen -destination=mock_learner.go -package=service github.com/ankur22/medium-picker/internal/service SourceFeedbackStorer
+
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/ankur22/medium-picker/internal/err"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+const ErrUnknownSignal = err.Const("unknown feedback signal")
+
+// Signal is the feedback that a reader gives on a source that was picked for them
+type Signal string
+
+const (
+	SignalRead     Signal = "read"
+	SignalSkipped  Signal = "skipped"
+	SignalLoved    Signal = "loved"
+	SignalDisliked Signal = "disliked"
+)
+
+// signalFactors are what each signal multiplies the multiplier by
+var signalFactors = map[Signal]float32{
+	SignalRead:     1.1,
+	SignalSkipped:  0.9,
+	SignalLoved:    1.5,
+	SignalDisliked: 0.5,
+}
+
+const (
+	// minMultiplier and maxMultiplier bound what can be learned, so
+	// that no source is ever picked all the time or never at all
+	minMultiplier = 0.1
+	maxMultiplier = 10
+	// forgetAfter is how long it takes for half of what was learned
+	// about a source to be forgotten, so that old feedback counts for
+	// less than new
+	forgetAfter = 30 * 24 * time.Hour
+)
+
+// SourceFeedbackStorer interface to update the source that feedback is given on
+type SourceFeedbackStorer interface {
+	ModifySource(ctx context.Context, userID string, sourceID string, fn func(source *store.Medium) error) error
+}
+
+// Learner adjusts the multiplier of a source from the feedback that the
+// reader gives on it, so that future picks reflect their taste
+type Learner struct {
+	store SourceFeedbackStorer
+	now   func() time.Time
+}
+
+// LearnerOption changes how a Learner learns
+type LearnerOption func(l *Learner)
+
+// WithLearnerClock makes the Learner get the time from now
+func WithLearnerClock(now func() time.Time) LearnerOption {
+	return func(l *Learner) {
+		l.now = now
+	}
+}
+
+// NewLearner will create a new instance of Learner
+func NewLearner(store SourceFeedbackStorer, opts ...LearnerOption) *Learner {
+	l := &Learner{
+		store: store,
+		now:   time.Now,
+	}
+	for _, o := range opts {
+		o(l)
+	}
+
+	return l
+}
+
+// Feedback records the signal on the source and adjusts its multiplier.
+// What was learned before is first partly forgotten, depending on how long
+// ago the last feedback was given, and then the signal's factor is applied.
+func (l *Learner) Feedback(ctx context.Context, userID string, sourceID string, signal Signal) error {
+	factor, ok := signalFactors[signal]
+	if !ok {
+		return ErrUnknownSignal
+	}
+
+	now := l.now().UTC()
+
+	// The source is read and written in one go, so that two signals
+	// on it, or a pick at the same time, don't overwrite each other
+	return l.store.ModifySource(ctx, userID, sourceID, func(m *store.Medium) error {
+		switch signal {
+		case SignalRead:
+			m.Reads++
+		case SignalSkipped:
+			m.Skips++
+		case SignalLoved:
+			m.Loves++
+		case SignalDisliked:
+			m.Dislikes++
+		}
+
+		m.Multiplier = bound(forget(m.Multiplier, m.LastFeedbackAt, now) * factor)
+		m.LastFeedbackAt = now
+
+		return nil
+	})
+}
+
+// forget moves the multiplier back towards 1 by half every forgetAfter
+// since the last feedback. It's done in log space, so that a loved and
+// a disliked source recover at the same rate.
+func forget(multiplier float32, last time.Time, now time.Time) float32 {
+	if last.IsZero() || multiplier <= 0 {
+		return multiplier
+	}
+
+	keep := math.Pow(0.5, fraction(now.Sub(last), forgetAfter))
+	return float32(math.Pow(float64(multiplier), keep))
+}
+
+// bound keeps the multiplier between minMultiplier and maxMultiplier
+func bound(multiplier float32) float32 {
+	if multiplier < minMultiplier {
+		return minMultiplier
+	}
+	if multiplier > maxMultiplier {
+		return maxMultiplier
+	}
+	return multiplier
+}
@@ -0,0 +1,195 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/logging"
+	"github.com/ankur22/medium-picker/internal/service"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+func TestLearner_Feedback(t *testing.T) {
+	const month = 30 * 24 * time.Hour
+
+	tests := []struct {
+		name           string
+		source         store.Medium
+		signal         service.Signal
+		wantMultiplier float32
+		wantCounts     [4]int
+	}{
+		{
+			name:           "read",
+			source:         store.Medium{ID: "1", Multiplier: 1},
+			signal:         service.SignalRead,
+			wantMultiplier: 1.1,
+			wantCounts:     [4]int{1, 0, 0, 0},
+		},
+		{
+			name:           "skipped",
+			source:         store.Medium{ID: "1", Multiplier: 1},
+			signal:         service.SignalSkipped,
+			wantMultiplier: 0.9,
+			wantCounts:     [4]int{0, 1, 0, 0},
+		},
+		{
+			name:           "loved",
+			source:         store.Medium{ID: "1", Multiplier: 1, Loves: 2},
+			signal:         service.SignalLoved,
+			wantMultiplier: 1.5,
+			wantCounts:     [4]int{0, 0, 3, 0},
+		},
+		{
+			name:           "disliked",
+			source:         store.Medium{ID: "1", Multiplier: 1},
+			signal:         service.SignalDisliked,
+			wantMultiplier: 0.5,
+			wantCounts:     [4]int{0, 0, 0, 1},
+		},
+		{
+			name:           "bounded above",
+			source:         store.Medium{ID: "1", Multiplier: 9, LastFeedbackAt: now},
+			signal:         service.SignalLoved,
+			wantMultiplier: 10,
+			wantCounts:     [4]int{0, 0, 1, 0},
+		},
+		{
+			name:           "bounded below",
+			source:         store.Medium{ID: "1", Multiplier: 0.15, LastFeedbackAt: now},
+			signal:         service.SignalDisliked,
+			wantMultiplier: 0.1,
+			wantCounts:     [4]int{0, 0, 0, 1},
+		},
+		{
+			name:           "half of what was learned is forgotten after a month",
+			source:         store.Medium{ID: "1", Multiplier: 4, LastFeedbackAt: now.Add(-month)},
+			signal:         service.SignalRead,
+			wantMultiplier: 2 * 1.1,
+			wantCounts:     [4]int{1, 0, 0, 0},
+		},
+		{
+			name:           "a dislike is forgotten as quickly",
+			source:         store.Medium{ID: "1", Multiplier: 0.25, LastFeedbackAt: now.Add(-month)},
+			signal:         service.SignalRead,
+			wantMultiplier: 0.5 * 1.1,
+			wantCounts:     [4]int{1, 0, 0, 0},
+		},
+		{
+			name:           "almost everything is forgotten after a long time",
+			source:         store.Medium{ID: "1", Multiplier: 10, LastFeedbackAt: now.Add(-20 * month)},
+			signal:         service.SignalSkipped,
+			wantMultiplier: 0.9,
+			wantCounts:     [4]int{0, 1, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := service.NewMockSourceFeedbackStorer(ctrl)
+			s.EXPECT().ModifySource(gomock.Any(), "some-id", tt.source.ID, gomock.Any()).DoAndReturn(modify([]store.Medium{tt.source}, func(source store.Medium) {
+				assert.InDelta(t, tt.wantMultiplier, source.Multiplier, 0.001)
+				assert.Equal(t, tt.wantCounts, [4]int{source.Reads, source.Skips, source.Loves, source.Dislikes})
+				assert.Equal(t, now, source.LastFeedbackAt)
+			}))
+
+			l := service.NewLearner(s, service.WithLearnerClock(clock))
+
+			err := l.Feedback(ctx, "some-id", tt.source.ID, tt.signal)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestLearner_Feedback_Failure(t *testing.T) {
+	tests := []struct {
+		name     string
+		signal   service.Signal
+		noStore  bool
+		storeErr error
+		wantErr  error
+	}{
+		{
+			name:    "unknown signal",
+			signal:  "meh",
+			noStore: true,
+			wantErr: service.ErrUnknownSignal,
+		},
+		{
+			name:     "source not found",
+			signal:   service.SignalRead,
+			storeErr: store.ErrCannotFindMedium,
+			wantErr:  store.ErrCannotFindMedium,
+		},
+		{
+			name:     "update fails",
+			signal:   service.SignalRead,
+			storeErr: errors.New("some error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := service.NewMockSourceFeedbackStorer(ctrl)
+			if !tt.noStore {
+				s.EXPECT().ModifySource(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(tt.storeErr)
+			}
+
+			l := service.NewLearner(s, service.WithLearnerClock(clock))
+
+			err := l.Feedback(ctx, "some-id", "1", tt.signal)
+			assert.Error(t, err)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), err)
+			}
+		})
+	}
+}
+
+func TestLearner_Feedback_Concurrent(t *testing.T) {
+	_, _ = logging.TestContext(context.Background())
+	ctx := context.Background()
+
+	m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "medium.json"), time.Hour, 10)
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, m.Close(ctx)) }()
+
+	require.NoError(t, m.AddSource(ctx, "some-id", "google.com"))
+	sources, _, err := m.GetAllSourceData(ctx, "some-id", "")
+	require.NoError(t, err)
+	require.Len(t, sources, 1)
+
+	l := service.NewLearner(m, service.WithLearnerClock(clock))
+
+	// Every signal counts, even when they're given at the same time
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, l.Feedback(ctx, "some-id", sources[0].ID, service.SignalLoved))
+		}()
+	}
+	wg.Wait()
+
+	got, err := m.GetSource(ctx, "some-id", sources[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, 20, got.Loves)
+	assert.Equal(t, float32(10), got.Multiplier)
+}
@@ -0,0 +1,49 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/ankur22/medium-picker/internal/service (interfaces: SourceFeedbackStorer)
+
+// Package service is a generated GoMock package.
+package service
+
+import (
+	context "context"
+	store "github.com/ankur22/medium-picker/internal/store"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockSourceFeedbackStorer is a mock of SourceFeedbackStorer interface
+type MockSourceFeedbackStorer struct {
+	ctrl     *gomock.Controller
+	recorder *MockSourceFeedbackStorerMockRecorder
+}
+
+// MockSourceFeedbackStorerMockRecorder is the mock recorder for MockSourceFeedbackStorer
+type MockSourceFeedbackStorerMockRecorder struct {
+	mock *MockSourceFeedbackStorer
+}
+
+// NewMockSourceFeedbackStorer creates a new mock instance
+func NewMockSourceFeedbackStorer(ctrl *gomock.Controller) *MockSourceFeedbackStorer {
+	mock := &MockSourceFeedbackStorer{ctrl: ctrl}
+	mock.recorder = &MockSourceFeedbackStorerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockSourceFeedbackStorer) EXPECT() *MockSourceFeedbackStorerMockRecorder {
+	return m.recorder
+}
+
+// ModifySource mocks base method
+func (m *MockSourceFeedbackStorer) ModifySource(arg0 context.Context, arg1, arg2 string, arg3 func(*store.Medium) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ModifySource", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ModifySource indicates an expected call of ModifySource
+func (mr *MockSourceFeedbackStorerMockRecorder) ModifySource(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ModifySource", reflect.TypeOf((*MockSourceFeedbackStorer)(nil).ModifySource), arg0, arg1, arg2, arg3)
+}
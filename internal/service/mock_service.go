@@ -35,12 +35,13 @@ func (m *MockMediumSourceStorer) EXPECT() *MockMediumSourceStorerMockRecorder {
 }
 
 // GetAllSourceData mocks base method
-func (m *MockMediumSourceStorer) GetAllSourceData(arg0 context.Context, arg1 string, arg2 int) ([]store.Medium, error) {
+func (m *MockMediumSourceStorer) GetAllSourceData(arg0 context.Context, arg1, arg2 string) ([]store.Medium, string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetAllSourceData", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]store.Medium)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // GetAllSourceData indicates an expected call of GetAllSourceData
@@ -49,16 +50,16 @@ func (mr *MockMediumSourceStorerMockRecorder) GetAllSourceData(arg0, arg1, arg2
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllSourceData", reflect.TypeOf((*MockMediumSourceStorer)(nil).GetAllSourceData), arg0, arg1, arg2)
 }
 
-// UpdateSource mocks base method
-func (m *MockMediumSourceStorer) UpdateSource(arg0 context.Context, arg1 string, arg2 store.Medium) error {
+// ModifySource mocks base method
+func (m *MockMediumSourceStorer) ModifySource(arg0 context.Context, arg1, arg2 string, arg3 func(*store.Medium) error) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateSource", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "ModifySource", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateSource indicates an expected call of UpdateSource
-func (mr *MockMediumSourceStorerMockRecorder) UpdateSource(arg0, arg1, arg2 interface{}) *gomock.Call {
+// ModifySource indicates an expected call of ModifySource
+func (mr *MockMediumSourceStorerMockRecorder) ModifySource(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSource", reflect.TypeOf((*MockMediumSourceStorer)(nil).UpdateSource), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ModifySource", reflect.TypeOf((*MockMediumSourceStorer)(nil).ModifySource), arg0, arg1, arg2, arg3)
 }
@@ -4,9 +4,14 @@ package service
 
 import (
 	"context"
-	"sort"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
 
 	"github.com/ankur22/medium-picker/internal/err"
+	"github.com/ankur22/medium-picker/internal/logging"
 	"github.com/ankur22/medium-picker/internal/store"
 )
 
@@ -15,69 +20,237 @@ const (
 	ErrFailedGetAllSources = err.Const("failed to retrieve all records")
 )
 
+const (
+	// freshFor is how long a site that has changed is more likely to be
+	// picked for. Its weight is doubled when it has just changed, and the
+	// boost wears off over freshFor.
+	freshFor = 7 * 24 * time.Hour
+	// recoverFor is how long a source that has been picked is less likely
+	// to be picked again for. Its weight is cut to a tenth when it has
+	// just been picked, and recovers over recoverFor.
+	recoverFor = 7 * 24 * time.Hour
+	// minWeight is the weight of a source that would otherwise have none,
+	// so that it's only picked when there's nothing else
+	minWeight = 1e-6
+)
+
 // MediumSourceStorer interface to retrieve medium sources
 type MediumSourceStorer interface {
-	GetAllSourceData(ctx context.Context, userID string, page int) ([]store.Medium, error)
-	UpdateSource(ctx context.Context, userID string, source store.Medium) error
+	GetAllSourceData(ctx context.Context, userID string, cursor string) ([]store.Medium, string, error)
+	ModifySource(ctx context.Context, userID string, sourceID string, fn func(source *store.Medium) error) error
 }
 
 // Picker is where the main business logic of
 // picking a source(s) to read for the user
 type Picker struct {
-	store MediumSourceStorer
+	store    MediumSourceStorer
+	now      func() time.Time
+	strategy Strategy
+	// lock guards rand, which can't be used by many goroutines at once
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+// PickOptions changes how a single pick is made
+type PickOptions struct {
+	// Strategy is the name of the registered strategy to pick with.
+	// The picker's default is used when it's empty.
+	Strategy string
+	// ChangedOnly only picks the sources that are new since the user
+	// last read them, and the strategy only chooses among the rest when
+	// fewer than count are
+	ChangedOnly bool
+	// Cooldown leaves out the sources that were picked within it, unless
+	// there aren't count others
+	Cooldown time.Duration
+}
+
+// Option changes how a Picker picks
+type Option func(p *Picker)
+
+// WithRand makes the Picker draw from r, e.g. so that a
+// test with a seeded r picks the same sources every time
+func WithRand(r *rand.Rand) Option {
+	return func(p *Picker) {
+		p.rand = r
+	}
+}
+
+// WithClock makes the Picker get the time from now
+func WithClock(now func() time.Time) Option {
+	return func(p *Picker) {
+		p.now = now
+	}
+}
+
+// WithStrategy makes s the strategy that the
+// Picker uses when a pick doesn't name one
+func WithStrategy(s Strategy) Option {
+	return func(p *Picker) {
+		p.strategy = s
+	}
 }
 
 // NewPicker will create a new instance of Picker
-func NewPicker(store MediumSourceStorer) *Picker {
-	return &Picker{
+func NewPicker(store MediumSourceStorer, opts ...Option) *Picker {
+	p := &Picker{
 		store: store,
+		now:   time.Now,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	p.strategy, _ = LookupStrategy(DefaultStrategy)
+	for _, o := range opts {
+		o(p)
 	}
+
+	return p
 }
 
-// Pick will pick the source(s) for the user to read
-func (p *Picker) Pick(ctx context.Context, userID string, count int) ([]store.Source, error) {
+// Pick will pick the source(s) for the user to read, with the strategy
+// that's named in opts or the picker's default
+func (p *Picker) Pick(ctx context.Context, userID string, count int, opts PickOptions) ([]store.Source, error) {
 	if count < 1 {
 		return nil, ErrCountSmallerThanOne
 	}
 
-	var page int
-	sources := make([]store.Medium, 1)
+	strategy := p.strategy
+	if opts.Strategy != "" {
+		var err error
+		if strategy, err = LookupStrategy(opts.Strategy); err != nil {
+			return nil, err
+		}
+	}
+
+	var cursor string
 	var all []store.Medium
-	for len(sources) != 0 {
-		ss, err := p.store.GetAllSourceData(ctx, userID, page)
+	for {
+		ss, next, err := p.store.GetAllSourceData(ctx, userID, cursor)
 		if err != nil {
-			return nil, ErrFailedGetAllSources
+			return nil, ErrFailedGetAllSources.Wrap(err)
 		}
 		all = append(all, ss...)
-		page++
-		sources = ss
+		if next == "" {
+			break
+		}
+		cursor = next
 	}
 
-	sort.Slice(all, func(i, j int) bool {
-		a := (float32(all[i].Hit) * all[i].Multiplier)
-		b := (float32(all[j].Hit) * all[j].Multiplier)
-		return a < b
-	})
+	now := p.now().UTC()
+	all = p.choose(strategy, all, count, opts, now)
 
-	if count > len(all) {
-		count = len(all)
+	rtnVal := make([]store.Source, 0, len(all))
+	for i := range all {
+		rtnVal = append(rtnVal, store.Source{
+			URL:              all[i].URL,
+			ID:               all[i].ID,
+			ItemTitle:        all[i].LatestItemTitle,
+			ItemLink:         all[i].LatestItemLink,
+			NewSinceLastRead: newSinceLastRead(all[i]),
+		})
+		// only the pick's fields are written, so that what the
+		// crawler has written since the sources were read is kept
+		err := p.store.ModifySource(ctx, userID, all[i].ID, func(source *store.Medium) error {
+			source.Hit++
+			source.LastPickedAt = now
+			return nil
+		})
+		if err != nil {
+			logging.Error(ctx, "cannot update picked source", zap.String("sourceID", all[i].ID), zap.Error(err))
+		}
 	}
 
-	all = all[:count]
+	return rtnVal, nil
+}
 
-	sort.Slice(all, func(i, j int) bool {
-		return all[i].ModifiedDate.Before(all[j].ModifiedDate)
-	})
+// choose has the strategy choose count of the sources. The sources are
+// split into tiers by the options, e.g. with a cooldown the ones that are
+// outside of it come first, and the strategy only chooses from a tier
+// when there aren't enough sources in the ones before it.
+func (p *Picker) choose(strategy Strategy, all []store.Medium, count int, opts PickOptions, now time.Time) []store.Medium {
+	env := Env{Now: now, Rand: p.rand}
 
-	rtnVal := make([]store.Source, 0, count)
-	for i := 0; i < count; i++ {
-		rtnVal = append(rtnVal, store.Source{
-			URL: all[i].URL,
-			ID:  all[i].ID,
+	tiers := [][]store.Medium{all}
+	if opts.Cooldown > 0 {
+		tiers = split(tiers, func(m store.Medium) bool {
+			return m.LastPickedAt.IsZero() || now.Sub(m.LastPickedAt) >= opts.Cooldown
 		})
-		all[i].Hit++
-		p.store.UpdateSource(ctx, userID, all[i])
+	}
+	if opts.ChangedOnly {
+		tiers = split(tiers, newSinceLastRead)
 	}
 
-	return rtnVal, nil
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var picked []store.Medium
+	for _, tier := range tiers {
+		if len(picked) == count {
+			break
+		}
+		if len(tier) > 0 {
+			picked = append(picked, strategy.Choose(tier, count-len(picked), env)...)
+		}
+	}
+
+	return picked
+}
+
+// split splits every tier in two, the sources that match first
+func split(tiers [][]store.Medium, match func(m store.Medium) bool) [][]store.Medium {
+	split := make([][]store.Medium, 0, 2*len(tiers))
+	for _, tier := range tiers {
+		var yes, no []store.Medium
+		for _, m := range tier {
+			if match(m) {
+				yes = append(yes, m)
+			} else {
+				no = append(no, m)
+			}
+		}
+		split = append(split, yes, no)
+	}
+	return split
+}
+
+// newSinceLastRead is whether the site has changed meaningfully, or has
+// a new item, since the source was last picked, or it never has been.
+// The hash isn't compared, as it changes with every small edit.
+func newSinceLastRead(m store.Medium) bool {
+	return m.LastPickedAt.IsZero() || changed(m).After(m.LastPickedAt)
+}
+
+// weight is how likely the source is to be picked, relative to the
+// others. It's the multiplier, boosted while the site is fresh and cut
+// while the source has been picked recently.
+func weight(m store.Medium, now time.Time) float64 {
+	w := multiplier(m, now)
+
+	if age := now.Sub(changed(m)); age < freshFor {
+		w *= 2 - fraction(age, freshFor)
+	}
+
+	if !m.LastPickedAt.IsZero() {
+		if since := now.Sub(m.LastPickedAt); since < recoverFor {
+			w *= 0.1 + 0.9*fraction(since, recoverFor)
+		}
+	}
+
+	if !(w > minWeight) {
+		return minWeight
+	}
+	return w
+}
+
+// multiplier is the source's multiplier with what was learned from the
+// feedback on it partly forgotten, as it would be by the next feedback
+func multiplier(m store.Medium, now time.Time) float64 {
+	return float64(forget(m.Multiplier, m.LastFeedbackAt, now))
+}
+
+// fraction is how far d is through period, between 0 and 1
+func fraction(d, period time.Duration) float64 {
+	if d < 0 {
+		return 0
+	}
+	return float64(d) / float64(period)
 }
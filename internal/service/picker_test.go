@@ -2,6 +2,8 @@ package service_test
 
 import (
 	"context"
+	"errors"
+	"math/rand"
 	"testing"
 	"time"
 
@@ -11,6 +13,12 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+var now = time.Date(2020, 10, 16, 12, 0, 0, 0, time.UTC)
+
+func clock() time.Time {
+	return now
+}
+
 func TestNewPicker(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -55,11 +63,13 @@ func TestPicker_Pick(t *testing.T) {
 						ModifiedDate: time.Date(0, 0, 0, 1, 0, 0, 0, time.UTC),
 					},
 					store.Medium{
-						URL:          "c.com",
-						ID:           "3",
-						Hit:          15,
-						Multiplier:   0.01,
-						ModifiedDate: time.Date(0, 0, 0, 5, 0, 0, 0, time.UTC),
+						URL:             "c.com",
+						ID:              "3",
+						Hit:             15,
+						Multiplier:      1000,
+						ModifiedDate:    time.Date(0, 0, 0, 5, 0, 0, 0, time.UTC),
+						LatestItemTitle: "Some post",
+						LatestItemLink:  "c.com/some-post",
 					},
 					store.Medium{
 						URL:          "d.com",
@@ -76,8 +86,11 @@ func TestPicker_Pick(t *testing.T) {
 			},
 			want: []store.Source{
 				store.Source{
-					URL: "c.com",
-					ID:  "3",
+					URL:              "c.com",
+					ID:               "3",
+					ItemTitle:        "Some post",
+					ItemLink:         "c.com/some-post",
+					NewSinceLastRead: true,
 				},
 			},
 			wantHit: []int{16},
@@ -97,7 +110,7 @@ func TestPicker_Pick(t *testing.T) {
 						URL:          "b.com",
 						ID:           "2",
 						Hit:          5,
-						Multiplier:   1,
+						Multiplier:   1000,
 						ModifiedDate: time.Date(0, 0, 0, 1, 0, 0, 0, time.UTC),
 					},
 					store.Medium{
@@ -111,7 +124,7 @@ func TestPicker_Pick(t *testing.T) {
 						URL:          "d.com",
 						ID:           "4",
 						Hit:          0,
-						Multiplier:   1,
+						Multiplier:   1000,
 						ModifiedDate: time.Date(0, 0, 0, 16, 0, 0, 0, time.UTC),
 					},
 				},
@@ -122,16 +135,56 @@ func TestPicker_Pick(t *testing.T) {
 			},
 			want: []store.Source{
 				store.Source{
-					URL: "b.com",
-					ID:  "2",
+					URL:              "b.com",
+					ID:               "2",
+					NewSinceLastRead: true,
 				},
 				store.Source{
-					URL: "d.com",
-					ID:  "4",
+					URL:              "d.com",
+					ID:               "4",
+					NewSinceLastRead: true,
 				},
 			},
 			wantHit: []int{6, 1},
 		},
+		{
+			name: "count is more than the sources",
+			fields: fields{
+				sources: []store.Medium{
+					store.Medium{
+						URL:          "a.com",
+						ID:           "1",
+						Hit:          10,
+						Multiplier:   1,
+						ModifiedDate: time.Date(0, 0, 0, 10, 0, 0, 0, time.UTC),
+					},
+					store.Medium{
+						URL:          "b.com",
+						ID:           "2",
+						Hit:          5,
+						Multiplier:   0,
+						ModifiedDate: time.Date(0, 0, 0, 1, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+			args: args{
+				userID: "some-id",
+				count:  3,
+			},
+			want: []store.Source{
+				store.Source{
+					URL:              "b.com",
+					ID:               "2",
+					NewSinceLastRead: true,
+				},
+				store.Source{
+					URL:              "a.com",
+					ID:               "1",
+					NewSinceLastRead: true,
+				},
+			},
+			wantHit: []int{6, 11},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -142,28 +195,436 @@ func TestPicker_Pick(t *testing.T) {
 
 			var count int
 			s := service.NewMockMediumSourceStorer(ctrl)
-			s.EXPECT().GetAllSourceData(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, userID string, page int) ([]store.Medium, error) {
+			s.EXPECT().GetAllSourceData(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, userID string, cursor string) ([]store.Medium, string, error) {
 				count++
 				if count == 1 {
-					return tt.fields.sources, nil
+					assert.Empty(t, cursor)
+					return tt.fields.sources, "some-cursor", nil
 				}
-				return nil, nil
+				assert.Equal(t, "some-cursor", cursor)
+				return nil, "", nil
 			}).Times(2)
 
 			var index int
-			s.EXPECT().UpdateSource(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, userID string, source store.Medium) error {
+			s.EXPECT().ModifySource(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(modify(tt.fields.sources, func(source store.Medium) {
 				assert.Equal(t, tt.want[index].ID, source.ID)
 				assert.Equal(t, tt.want[index].URL, source.URL)
 				assert.Equal(t, tt.wantHit[index], source.Hit)
+				assert.Equal(t, now, source.LastPickedAt)
 				index++
-				return nil
-			}).Times(len(tt.wantHit))
+			})).Times(len(tt.wantHit))
 
-			p := service.NewPicker(s)
+			p := service.NewPicker(s, service.WithRand(rand.New(rand.NewSource(1))), service.WithClock(clock))
 
-			ss, err := p.Pick(ctx, tt.args.userID, tt.args.count)
+			ss, err := p.Pick(ctx, tt.args.userID, tt.args.count, service.PickOptions{})
 			assert.NoError(t, err)
 			assert.ElementsMatch(t, tt.want, ss)
 		})
 	}
 }
+
+func TestPicker_Pick_Weights(t *testing.T) {
+	const picks = 10000
+
+	old := now.Add(-30 * 24 * time.Hour)
+	tests := []struct {
+		name    string
+		sources []store.Medium
+		// want is the share of the picks that each source should get
+		want map[string]float64
+	}{
+		{
+			name: "in proportion to the multiplier",
+			sources: []store.Medium{
+				{ID: "1", Multiplier: 1, ModifiedDate: old},
+				{ID: "2", Multiplier: 3, ModifiedDate: old},
+			},
+			want: map[string]float64{"1": 0.25, "2": 0.75},
+		},
+		{
+			name: "site that has just changed is twice as likely",
+			sources: []store.Medium{
+				{ID: "1", Multiplier: 1, ModifiedDate: old},
+				{ID: "2", Multiplier: 1, ModifiedDate: old, LatestItemDate: now},
+			},
+			want: map[string]float64{"1": 1.0 / 3, "2": 2.0 / 3},
+		},
+		{
+			name: "source that has just been picked is a tenth as likely",
+			sources: []store.Medium{
+				{ID: "1", Multiplier: 1, ModifiedDate: old},
+				{ID: "2", Multiplier: 1, ModifiedDate: old, LastPickedAt: now},
+			},
+			want: map[string]float64{"1": 1 / 1.1, "2": 0.1 / 1.1},
+		},
+		{
+			name: "source that was picked a while ago has recovered",
+			sources: []store.Medium{
+				{ID: "1", Multiplier: 1, ModifiedDate: old},
+				{ID: "2", Multiplier: 1, ModifiedDate: old, LastPickedAt: old},
+			},
+			want: map[string]float64{"1": 0.5, "2": 0.5},
+		},
+		{
+			name: "half of what was learned from feedback is forgotten after a month",
+			sources: []store.Medium{
+				{ID: "1", Multiplier: 1, ModifiedDate: old},
+				{ID: "2", Multiplier: 9, ModifiedDate: old, LastFeedbackAt: old},
+			},
+			want: map[string]float64{"1": 0.25, "2": 0.75},
+		},
+		{
+			name: "source without a multiplier is only picked when there's nothing else",
+			sources: []store.Medium{
+				{ID: "1", Multiplier: 1, ModifiedDate: old},
+				{ID: "2", Multiplier: 0, ModifiedDate: old},
+			},
+			want: map[string]float64{"1": 1, "2": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := service.NewMockMediumSourceStorer(ctrl)
+			s.EXPECT().GetAllSourceData(gomock.Any(), gomock.Any(), gomock.Any()).Return(tt.sources, "", nil).Times(picks)
+
+			got := map[string]int{}
+			s.EXPECT().ModifySource(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(modify(tt.sources, func(source store.Medium) {
+				got[source.ID]++
+			})).Times(picks)
+
+			p := service.NewPicker(s, service.WithRand(rand.New(rand.NewSource(1))), service.WithClock(clock))
+			for i := 0; i < picks; i++ {
+				_, err := p.Pick(ctx, "some-id", 1, service.PickOptions{})
+				assert.NoError(t, err)
+			}
+
+			for id, share := range tt.want {
+				assert.InDelta(t, share, float64(got[id])/picks, 0.02, "source %s", id)
+			}
+		})
+	}
+}
+
+func TestPicker_Pick_Seeded(t *testing.T) {
+	ctx := context.Background()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sources := []store.Medium{
+		{ID: "1", Multiplier: 1},
+		{ID: "2", Multiplier: 2},
+		{ID: "3", Multiplier: 3},
+		{ID: "4", Multiplier: 4},
+	}
+
+	s := service.NewMockMediumSourceStorer(ctrl)
+	s.EXPECT().GetAllSourceData(gomock.Any(), gomock.Any(), gomock.Any()).Return(sources, "", nil).AnyTimes()
+	s.EXPECT().ModifySource(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	// The same seed picks the same sources
+	pick := func(seed int64) [][]store.Source {
+		p := service.NewPicker(s, service.WithRand(rand.New(rand.NewSource(seed))), service.WithClock(clock))
+
+		var all [][]store.Source
+		for i := 0; i < 20; i++ {
+			ss, err := p.Pick(ctx, "some-id", 2, service.PickOptions{})
+			assert.NoError(t, err)
+			all = append(all, ss)
+		}
+		return all
+	}
+
+	assert.Equal(t, pick(1), pick(1))
+	assert.NotEqual(t, pick(1), pick(2))
+}
+
+func TestPicker_Pick_Strategy(t *testing.T) {
+	sources := []store.Medium{
+		{ID: "1", Hit: 1, Multiplier: 1, CreatedDate: now.Add(-2 * time.Hour), LastPickedAt: now.Add(-time.Hour)},
+		{ID: "2", Hit: 2, Multiplier: 1, CreatedDate: now.Add(-time.Hour)},
+	}
+	last := service.StrategyFunc(func(sources []store.Medium, count int, env service.Env) []store.Medium {
+		return sources[len(sources)-1:]
+	})
+
+	tests := []struct {
+		name    string
+		opts    []service.Option
+		pick    service.PickOptions
+		want    string
+		wantErr error
+	}{
+		{
+			name: "named in the pick",
+			pick: service.PickOptions{Strategy: service.StrategyHits},
+			want: "1",
+		},
+		{
+			name: "named in the pick over the picker's default",
+			opts: []service.Option{service.WithStrategy(last)},
+			pick: service.PickOptions{Strategy: service.StrategyLeastRecentlyRead},
+			want: "2",
+		},
+		{
+			name: "picker's default",
+			opts: []service.Option{service.WithStrategy(last)},
+			want: "2",
+		},
+		{
+			name:    "unknown strategy",
+			pick:    service.PickOptions{Strategy: "unknown"},
+			wantErr: service.ErrUnknownStrategy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := service.NewMockMediumSourceStorer(ctrl)
+			if tt.wantErr == nil {
+				s.EXPECT().GetAllSourceData(gomock.Any(), gomock.Any(), gomock.Any()).Return(sources, "", nil)
+				s.EXPECT().ModifySource(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			}
+
+			p := service.NewPicker(s, append(tt.opts, service.WithClock(clock))...)
+
+			ss, err := p.Pick(ctx, "some-id", 1, tt.pick)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), err)
+				return
+			}
+			assert.NoError(t, err)
+			if assert.Len(t, ss, 1) {
+				assert.Equal(t, tt.want, ss[0].ID)
+			}
+		})
+	}
+}
+
+func TestPicker_Pick_ChangedOnly(t *testing.T) {
+	picked := now.Add(-24 * time.Hour)
+	unread := store.Medium{ID: "1", Hash: "a", CreatedDate: now.Add(-4 * time.Hour)}
+	changed := store.Medium{ID: "2", Hash: "b", ModifiedDate: picked.Add(time.Hour), LastPickedAt: picked, CreatedDate: now.Add(-3 * time.Hour)}
+	same := store.Medium{ID: "3", Hash: "c", ModifiedDate: picked.Add(-time.Hour), LastPickedAt: picked, CreatedDate: now.Add(-2 * time.Hour)}
+	sameToo := store.Medium{ID: "4", Hash: "d", ModifiedDate: picked.Add(-2 * time.Hour), LastPickedAt: picked.Add(-time.Hour), CreatedDate: now.Add(-time.Hour)}
+	// The hash of edited has changed since it was picked, but the edit
+	// was too small to bump the ModifiedDate
+	edited := store.Medium{ID: "5", Hash: "e2", ModifiedDate: picked.Add(-48 * time.Hour), LastPickedAt: picked.Add(-2 * time.Hour), CreatedDate: now.Add(-30 * time.Minute)}
+	newItem := store.Medium{ID: "6", Hash: "f", ModifiedDate: picked.Add(-48 * time.Hour), LatestItemDate: picked.Add(time.Hour), LastPickedAt: picked, CreatedDate: now.Add(-20 * time.Minute)}
+
+	tests := []struct {
+		name        string
+		sources     []store.Medium
+		count       int
+		changedOnly bool
+		cooldown    time.Duration
+		want        []string
+		wantNew     []bool
+	}{
+		{
+			name:        "only the changed",
+			sources:     []store.Medium{same, changed, unread, sameToo},
+			count:       2,
+			changedOnly: true,
+			want:        []string{"1", "2"},
+			wantNew:     []bool{true, true},
+		},
+		{
+			name:        "falls back on the strategy when too few changed",
+			sources:     []store.Medium{same, changed, sameToo},
+			count:       2,
+			changedOnly: true,
+			want:        []string{"2", "4"},
+			wantNew:     []bool{true, false},
+		},
+		{
+			name:        "none changed",
+			sources:     []store.Medium{same, sameToo},
+			count:       1,
+			changedOnly: true,
+			want:        []string{"4"},
+			wantNew:     []bool{false},
+		},
+		{
+			name:        "changed inside the cooldown come after the others",
+			sources:     []store.Medium{changed, sameToo},
+			count:       1,
+			changedOnly: true,
+			cooldown:    25 * time.Hour,
+			want:        []string{"4"},
+			wantNew:     []bool{false},
+		},
+		{
+			name:    "marks the changed without the mode",
+			sources: []store.Medium{same, changed, sameToo},
+			count:   2,
+			want:    []string{"4", "2"},
+			wantNew: []bool{false, true},
+		},
+		{
+			name:        "a small edit isn't a change",
+			sources:     []store.Medium{edited, newItem},
+			count:       1,
+			changedOnly: true,
+			want:        []string{"6"},
+			wantNew:     []bool{true},
+		},
+		{
+			name:    "marks a new item but not a small edit",
+			sources: []store.Medium{edited, newItem},
+			count:   2,
+			want:    []string{"5", "6"},
+			wantNew: []bool{false, true},
+		},
+		{
+			name:    "marks the changed that the strategy chose",
+			sources: []store.Medium{same, changed, unread},
+			count:   3,
+			want:    []string{"1", "2", "3"},
+			wantNew: []bool{true, true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := service.NewMockMediumSourceStorer(ctrl)
+			s.EXPECT().GetAllSourceData(gomock.Any(), gomock.Any(), gomock.Any()).Return(tt.sources, "", nil)
+			s.EXPECT().ModifySource(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(modify(tt.sources, func(source store.Medium) {
+				assert.Equal(t, now, source.LastPickedAt)
+			})).Times(len(tt.want))
+
+			p := service.NewPicker(s, service.WithClock(clock))
+
+			ss, err := p.Pick(ctx, "some-id", tt.count, service.PickOptions{
+				Strategy:    service.StrategyLeastRecentlyRead,
+				ChangedOnly: tt.changedOnly,
+				Cooldown:    tt.cooldown,
+			})
+			assert.NoError(t, err)
+
+			var ids []string
+			var isNew []bool
+			for _, s := range ss {
+				ids = append(ids, s.ID)
+				isNew = append(isNew, s.NewSinceLastRead)
+			}
+			assert.Equal(t, tt.want, ids)
+			assert.Equal(t, tt.wantNew, isNew)
+		})
+	}
+}
+
+// sourceStorer keeps the sources in memory, so that a
+// test can pick from them over and over
+type sourceStorer struct {
+	sources []store.Medium
+}
+
+func (s *sourceStorer) GetAllSourceData(ctx context.Context, userID string, cursor string) ([]store.Medium, string, error) {
+	return append([]store.Medium(nil), s.sources...), "", nil
+}
+
+func (s *sourceStorer) ModifySource(ctx context.Context, userID string, sourceID string, fn func(source *store.Medium) error) error {
+	for i := range s.sources {
+		if s.sources[i].ID == sourceID {
+			return fn(&s.sources[i])
+		}
+	}
+	return store.ErrCannotFindMedium
+}
+
+// modify returns a ModifySource that applies the change to a copy of
+// the source in sources, and then has check look at the result
+func modify(sources []store.Medium, check func(source store.Medium)) func(ctx context.Context, userID string, sourceID string, fn func(source *store.Medium) error) error {
+	return func(ctx context.Context, userID string, sourceID string, fn func(source *store.Medium) error) error {
+		for _, source := range sources {
+			if source.ID == sourceID {
+				if err := fn(&source); err != nil {
+					return err
+				}
+				check(source)
+				return nil
+			}
+		}
+		return store.ErrCannotFindMedium
+	}
+}
+
+func TestPicker_Pick_Cooldown(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		cooldown time.Duration
+		count    int
+		// want is the source that's picked on each day
+		want [][]string
+	}{
+		{
+			name:  "without a cooldown the fewest hits are repeated",
+			count: 1,
+			want:  [][]string{{"1"}, {"1"}, {"1"}, {"1"}},
+		},
+		{
+			name:     "no repeats within the cooldown",
+			cooldown: 3 * day,
+			count:    1,
+			want:     [][]string{{"1"}, {"2"}, {"3"}, {"1"}, {"2"}},
+		},
+		{
+			name:     "sources inside the cooldown when there aren't enough others",
+			cooldown: 5 * day,
+			count:    1,
+			want:     [][]string{{"1"}, {"2"}, {"3"}, {"1"}},
+		},
+		{
+			name:     "sources inside the cooldown make up the count",
+			cooldown: 3 * day,
+			count:    2,
+			want:     [][]string{{"1", "2"}, {"3", "1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			s := &sourceStorer{sources: []store.Medium{
+				{ID: "1", Hit: 0, Multiplier: 1},
+				{ID: "2", Hit: 10, Multiplier: 1},
+				{ID: "3", Hit: 20, Multiplier: 1},
+			}}
+
+			today := now
+			p := service.NewPicker(s, service.WithClock(func() time.Time { return today }))
+
+			for i, want := range tt.want {
+				ss, err := p.Pick(ctx, "some-id", tt.count, service.PickOptions{
+					Strategy: service.StrategyHits,
+					Cooldown: tt.cooldown,
+				})
+				assert.NoError(t, err)
+
+				var got []string
+				for _, s := range ss {
+					got = append(got, s.ID)
+				}
+				assert.Equal(t, want, got, "day %d", i)
+
+				today = today.Add(day)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ankur22/medium-picker/internal/err"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+const ErrUnknownStrategy = err.Const("unknown pick strategy")
+
+// The names of the strategies that are registered by this package
+const (
+	// StrategyWeighted draws the sources at random in proportion to
+	// their weight. It's the default.
+	StrategyWeighted = "weighted"
+	// StrategyHits picks the sources that have been picked the fewest
+	// times, scaled by their multiplier
+	StrategyHits = "hits"
+	// StrategyLeastRecentlyRead picks the sources that were picked the
+	// longest time ago, and the ones that never have been first
+	StrategyLeastRecentlyRead = "least-recently-read"
+	// StrategyRoundRobin picks the sources in the order that they were
+	// added, carrying on after the one that was picked last
+	StrategyRoundRobin = "round-robin"
+	// StrategyFreshest picks the sites that changed most recently
+	StrategyFreshest = "freshest"
+
+	DefaultStrategy = StrategyWeighted
+)
+
+// Strategy chooses which of a user's sources are picked
+type Strategy interface {
+	// Choose returns count of the sources, or all of them if there
+	// aren't that many, in the order that they should be read. It
+	// mustn't change sources.
+	Choose(sources []store.Medium, count int, env Env) []store.Medium
+}
+
+// StrategyFunc is a func that is a Strategy
+type StrategyFunc func(sources []store.Medium, count int, env Env) []store.Medium
+
+// Choose calls f
+func (f StrategyFunc) Choose(sources []store.Medium, count int, env Env) []store.Medium {
+	return f(sources, count, env)
+}
+
+// Env is what a Strategy has to choose with
+type Env struct {
+	// Now is the time of the pick
+	Now time.Time
+	// Rand is the picker's random source, which the Strategy
+	// has to itself until Choose returns
+	Rand *rand.Rand
+}
+
+var strategies = struct {
+	sync.Mutex
+	m map[string]Strategy
+}{m: map[string]Strategy{}}
+
+func init() {
+	RegisterStrategy(StrategyWeighted, StrategyFunc(weighted))
+	RegisterStrategy(StrategyHits, StrategyFunc(byHits))
+	RegisterStrategy(StrategyLeastRecentlyRead, StrategyFunc(leastRecentlyRead))
+	RegisterStrategy(StrategyRoundRobin, StrategyFunc(roundRobin))
+	RegisterStrategy(StrategyFreshest, StrategyFunc(freshest))
+}
+
+// RegisterStrategy makes a strategy available under the name. It panics
+// if the name is already registered, as two strategies can't share it.
+func RegisterStrategy(name string, s Strategy) {
+	strategies.Lock()
+	defer strategies.Unlock()
+
+	if _, ok := strategies.m[name]; ok {
+		panic("service: RegisterStrategy called twice for " + name)
+	}
+	strategies.m[name] = s
+}
+
+// Strategies returns the names of the strategies that have been registered, sorted
+func Strategies() []string {
+	strategies.Lock()
+	defer strategies.Unlock()
+
+	names := make([]string, 0, len(strategies.m))
+	for name := range strategies.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// LookupStrategy returns the strategy that is registered under the name
+func LookupStrategy(name string) (Strategy, error) {
+	strategies.Lock()
+	s, ok := strategies.m[name]
+	strategies.Unlock()
+	if !ok {
+		return nil, ErrUnknownStrategy.Wrap(fmt.Errorf("%q, use one of %s", name, strings.Join(Strategies(), ", ")))
+	}
+
+	return s, nil
+}
+
+// weighted draws the sources at random, without replacement, in
+// proportion to their weight. Each source is given the key
+// u^(1/weight), for a uniform random u, and the sources with the
+// largest keys are chosen, which is the same as drawing them one at a
+// time in proportion to their weight (Efraimidis and Spirakis, 2006).
+// They're read in the order that they changed.
+func weighted(sources []store.Medium, count int, env Env) []store.Medium {
+	keys := make(map[string]float64, len(sources))
+	for _, m := range sources {
+		// log(u)/weight orders the sources the same as u^(1/weight),
+		// without rounding the keys of small weights to 0
+		keys[m.ID] = math.Log(env.Rand.Float64()) / weight(m, env.Now)
+	}
+
+	all := sorted(sources, func(a, b store.Medium) bool {
+		return keys[a.ID] > keys[b.ID]
+	})
+
+	return byModifiedDate(first(all, count))
+}
+
+// byHits chooses the sources with the fewest hits, scaled by their
+// multiplier with old feedback partly forgotten. They're read in the
+// order that they changed.
+func byHits(sources []store.Medium, count int, env Env) []store.Medium {
+	hits := make(map[string]float64, len(sources))
+	for _, m := range sources {
+		hits[m.ID] = float64(m.Hit) * multiplier(m, env.Now)
+	}
+
+	all := sorted(sources, func(a, b store.Medium) bool {
+		return hits[a.ID] < hits[b.ID]
+	})
+
+	return byModifiedDate(first(all, count))
+}
+
+// leastRecentlyRead chooses the sources that were picked the longest
+// time ago, or never, and then the ones that were added first
+func leastRecentlyRead(sources []store.Medium, count int, env Env) []store.Medium {
+	all := sorted(sources, func(a, b store.Medium) bool {
+		if !a.LastPickedAt.Equal(b.LastPickedAt) {
+			return a.LastPickedAt.Before(b.LastPickedAt)
+		}
+		return added(a, b)
+	})
+
+	return first(all, count)
+}
+
+// roundRobin chooses the sources in the order that they were added,
+// starting after the last one of the latest pick
+func roundRobin(sources []store.Medium, count int, env Env) []store.Medium {
+	all := sorted(sources, added)
+	n := len(all)
+
+	var latest time.Time
+	for _, m := range all {
+		if m.LastPickedAt.After(latest) {
+			latest = m.LastPickedAt
+		}
+	}
+
+	// A pick can wrap around the end, so the last source of the latest
+	// pick is the one that isn't followed by another from the same pick
+	start := 0
+	if !latest.IsZero() {
+		for i := range all {
+			if all[i].LastPickedAt.Equal(latest) && !all[(i+1)%n].LastPickedAt.Equal(latest) {
+				start = (i + 1) % n
+				break
+			}
+		}
+	}
+
+	return first(append(all[start:], all[:start]...), count)
+}
+
+// freshest chooses the sites that changed most recently
+func freshest(sources []store.Medium, count int, env Env) []store.Medium {
+	all := sorted(sources, func(a, b store.Medium) bool {
+		ca, cb := changed(a), changed(b)
+		if !ca.Equal(cb) {
+			return ca.After(cb)
+		}
+		return added(a, b)
+	})
+
+	return first(all, count)
+}
+
+// sorted returns a sorted copy of the sources
+func sorted(sources []store.Medium, less func(a, b store.Medium) bool) []store.Medium {
+	all := append([]store.Medium(nil), sources...)
+	sort.SliceStable(all, func(i, j int) bool {
+		return less(all[i], all[j])
+	})
+	return all
+}
+
+// first returns the first count sources, or all of them
+func first(sources []store.Medium, count int) []store.Medium {
+	if count > len(sources) {
+		count = len(sources)
+	}
+	return sources[:count]
+}
+
+// byModifiedDate sorts the sources by when they last changed
+func byModifiedDate(sources []store.Medium) []store.Medium {
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].ModifiedDate.Before(sources[j].ModifiedDate)
+	})
+	return sources
+}
+
+// added is whether a was added before b
+func added(a, b store.Medium) bool {
+	if !a.CreatedDate.Equal(b.CreatedDate) {
+		return a.CreatedDate.Before(b.CreatedDate)
+	}
+	return a.ID < b.ID
+}
+
+// changed is when the site last changed
+func changed(m store.Medium) time.Time {
+	if m.LatestItemDate.After(m.ModifiedDate) {
+		return m.LatestItemDate
+	}
+	return m.ModifiedDate
+}
@@ -0,0 +1,200 @@
+package service_test
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ankur22/medium-picker/internal/service"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+func TestStrategies(t *testing.T) {
+	assert.Equal(t, []string{
+		service.StrategyFreshest,
+		service.StrategyHits,
+		service.StrategyLeastRecentlyRead,
+		service.StrategyRoundRobin,
+		service.StrategyWeighted,
+	}, service.Strategies())
+}
+
+func TestLookupStrategy(t *testing.T) {
+	for _, name := range service.Strategies() {
+		s, err := service.LookupStrategy(name)
+		assert.NoError(t, err, name)
+		assert.NotNil(t, s, name)
+	}
+
+	_, err := service.LookupStrategy("unknown")
+	assert.True(t, errors.Is(err, service.ErrUnknownStrategy), err)
+}
+
+func TestRegisterStrategy(t *testing.T) {
+	noop := service.StrategyFunc(func(sources []store.Medium, count int, env service.Env) []store.Medium {
+		return nil
+	})
+
+	service.RegisterStrategy("test-noop", noop)
+
+	s, err := service.LookupStrategy("test-noop")
+	assert.NoError(t, err)
+	assert.Empty(t, s.Choose([]store.Medium{{ID: "1"}}, 1, service.Env{}))
+
+	assert.Panics(t, func() {
+		service.RegisterStrategy("test-noop", noop)
+	})
+	assert.Panics(t, func() {
+		service.RegisterStrategy(service.StrategyWeighted, noop)
+	})
+}
+
+func TestStrategy_Choose(t *testing.T) {
+	day := func(d int) time.Time {
+		return now.Add(time.Duration(d) * 24 * time.Hour)
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		sources  []store.Medium
+		count    int
+		want     []string
+	}{
+		{
+			name:     "hits picks the fewest hits scaled by the multiplier",
+			strategy: service.StrategyHits,
+			sources: []store.Medium{
+				{ID: "1", Hit: 10, Multiplier: 1, ModifiedDate: day(-1)},
+				{ID: "2", Hit: 5, Multiplier: 1, ModifiedDate: day(-3)},
+				{ID: "3", Hit: 1, Multiplier: 10, ModifiedDate: day(-2)},
+				{ID: "4", Hit: 1, Multiplier: 1, ModifiedDate: day(-4)},
+			},
+			count: 2,
+			want:  []string{"4", "2"},
+		},
+		{
+			name:     "hits ranks a source with a higher multiplier later",
+			strategy: service.StrategyHits,
+			sources: []store.Medium{
+				{ID: "1", Hit: 3, Multiplier: 1, ModifiedDate: day(-1)},
+				{ID: "2", Hit: 2, Multiplier: 2, ModifiedDate: day(-2)},
+			},
+			count: 1,
+			want:  []string{"1"},
+		},
+		{
+			name:     "hits forgets old feedback",
+			strategy: service.StrategyHits,
+			sources: []store.Medium{
+				{ID: "1", Hit: 3, Multiplier: 1, ModifiedDate: day(-1)},
+				{ID: "2", Hit: 2, Multiplier: 4, LastFeedbackAt: day(-60), ModifiedDate: day(-2)},
+			},
+			count: 1,
+			want:  []string{"2"},
+		},
+		{
+			name:     "least recently read picks the unread first",
+			strategy: service.StrategyLeastRecentlyRead,
+			sources: []store.Medium{
+				{ID: "1", CreatedDate: day(-9), LastPickedAt: day(-1)},
+				{ID: "2", CreatedDate: day(-8), LastPickedAt: day(-5)},
+				{ID: "3", CreatedDate: day(-7)},
+				{ID: "4", CreatedDate: day(-6), LastPickedAt: day(-3)},
+			},
+			count: 3,
+			want:  []string{"3", "2", "4"},
+		},
+		{
+			name:     "round robin starts with the first added",
+			strategy: service.StrategyRoundRobin,
+			sources: []store.Medium{
+				{ID: "2", CreatedDate: day(-8)},
+				{ID: "1", CreatedDate: day(-9)},
+				{ID: "3", CreatedDate: day(-7)},
+			},
+			count: 2,
+			want:  []string{"1", "2"},
+		},
+		{
+			name:     "round robin carries on after the last pick",
+			strategy: service.StrategyRoundRobin,
+			sources: []store.Medium{
+				{ID: "1", CreatedDate: day(-9), LastPickedAt: day(-2)},
+				{ID: "2", CreatedDate: day(-8), LastPickedAt: day(-1)},
+				{ID: "3", CreatedDate: day(-7), LastPickedAt: day(-1)},
+				{ID: "4", CreatedDate: day(-6)},
+				{ID: "5", CreatedDate: day(-5)},
+			},
+			count: 3,
+			want:  []string{"4", "5", "1"},
+		},
+		{
+			name:     "round robin carries on after a pick that wrapped around",
+			strategy: service.StrategyRoundRobin,
+			sources: []store.Medium{
+				{ID: "1", CreatedDate: day(-9), LastPickedAt: day(-1)},
+				{ID: "2", CreatedDate: day(-8), LastPickedAt: day(-2)},
+				{ID: "3", CreatedDate: day(-7), LastPickedAt: day(-1)},
+			},
+			count: 2,
+			want:  []string{"2", "3"},
+		},
+		{
+			name:     "freshest picks the latest change",
+			strategy: service.StrategyFreshest,
+			sources: []store.Medium{
+				{ID: "1", ModifiedDate: day(-3)},
+				{ID: "2", ModifiedDate: day(-5), LatestItemDate: day(-1)},
+				{ID: "3", ModifiedDate: day(-2)},
+			},
+			count: 2,
+			want:  []string{"2", "3"},
+		},
+		{
+			name:     "weighted picks by weight",
+			strategy: service.StrategyWeighted,
+			sources: []store.Medium{
+				{ID: "1", Multiplier: 1000, ModifiedDate: day(-2)},
+				{ID: "2", Multiplier: 0},
+				{ID: "3", Multiplier: 1000, ModifiedDate: day(-3)},
+			},
+			count: 2,
+			want:  []string{"3", "1"},
+		},
+		{
+			name:     "count is more than the sources",
+			strategy: service.StrategyLeastRecentlyRead,
+			sources: []store.Medium{
+				{ID: "1", CreatedDate: day(-9)},
+			},
+			count: 3,
+			want:  []string{"1"},
+		},
+		{
+			name:     "no sources",
+			strategy: service.StrategyRoundRobin,
+			count:    3,
+			want:     []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := service.LookupStrategy(tt.strategy)
+			assert.NoError(t, err)
+
+			sources := append([]store.Medium(nil), tt.sources...)
+			got := s.Choose(sources, tt.count, service.Env{Now: now, Rand: rand.New(rand.NewSource(1))})
+
+			ids := make([]string, 0, len(got))
+			for _, m := range got {
+				ids = append(ids, m.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+			assert.Equal(t, tt.sources, sources, "the sources were changed")
+		})
+	}
+}
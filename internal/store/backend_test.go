@@ -0,0 +1,159 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+func TestOpen_Success(t *testing.T) {
+	tests := []struct {
+		name  string
+		dsn   func(dir string) string
+		files []string
+	}{
+		{
+			name: "file",
+			dsn: func(dir string) string {
+				return "file://" + filepath.ToSlash(filepath.Join(dir, "picker"))
+			},
+			files: []string{filepath.Join("picker", store.UserFilename), filepath.Join("picker", store.MediumFilename)},
+		},
+		{
+			name: "sqlite",
+			dsn: func(dir string) string {
+				return "sqlite://" + filepath.ToSlash(filepath.Join(dir, "picker.db"))
+			},
+			files: []string{"picker.db"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			b, err := store.Open(ctx, tt.dsn(dir), store.Options{ElemsInPage: 10, FlushInterval: time.Hour})
+			require.NoError(t, err)
+
+			done := make(chan error)
+			go func() {
+				done <- b.Start(ctx)
+			}()
+
+			uid, err := b.Users.CreateNewUser(ctx, "test@example.com")
+			require.NoError(t, err)
+			err = b.Medium.AddSource(ctx, uid, "google.com")
+			require.NoError(t, err)
+
+			err = b.Close(ctx)
+			require.NoError(t, err)
+			assert.NoError(t, <-done)
+
+			for _, f := range tt.files {
+				assert.FileExists(t, filepath.Join(dir, f))
+			}
+
+			b, err = store.Open(ctx, tt.dsn(dir), store.Options{ElemsInPage: 10, FlushInterval: time.Hour})
+			require.NoError(t, err)
+			defer b.Close(ctx)
+
+			got, err := b.Users.GetUser(ctx, "test@example.com")
+			assert.NoError(t, err)
+			assert.Equal(t, uid, got)
+
+			sources, _, err := b.Medium.GetSources(ctx, uid, "")
+			assert.NoError(t, err)
+			assert.Len(t, sources, 1)
+		})
+	}
+}
+
+func TestOpen_Failure(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+		want error
+	}{
+		{
+			name: "no scheme",
+			dsn:  "/var/lib/picker",
+			want: store.ErrInvalidDSN,
+		},
+		{
+			name: "not a url",
+			dsn:  "file://%zz",
+			want: store.ErrInvalidDSN,
+		},
+		{
+			name: "no path",
+			dsn:  "sqlite://",
+			want: store.ErrInvalidDSN,
+		},
+		{
+			name: "unknown scheme",
+			dsn:  "mysql://localhost/picker",
+			want: store.ErrUnknownStoreScheme,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := store.Open(context.Background(), tt.dsn, store.Options{ElemsInPage: 10, FlushInterval: time.Hour})
+			assert.Nil(t, b)
+			assert.True(t, errors.Is(err, tt.want), err)
+		})
+	}
+}
+
+func TestBackend_Dump(t *testing.T) {
+	for _, scheme := range []string{"file", "sqlite"} {
+		t.Run(scheme, func(t *testing.T) {
+			ctx := context.Background()
+
+			b, err := store.Open(ctx, scheme+"://"+filepath.ToSlash(filepath.Join(t.TempDir(), "picker")), store.Options{ElemsInPage: 1, FlushInterval: time.Hour})
+			require.NoError(t, err)
+			defer b.Close(ctx)
+
+			dump, err := b.Dump(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, dump.Users)
+			assert.Empty(t, dump.Sources)
+
+			a, err := b.Users.CreateNewUser(ctx, "a@example.com")
+			require.NoError(t, err)
+			c, err := b.Users.CreateNewUser(ctx, "c@example.com")
+			require.NoError(t, err)
+			require.NoError(t, b.Medium.AddSource(ctx, a, "google.com"))
+			require.NoError(t, b.Medium.AddSource(ctx, a, "bing.com"))
+			require.NoError(t, b.Medium.AddSource(ctx, c, "yahoo.com"))
+
+			dump, err = b.Dump(ctx)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []store.User{{ID: a, Email: "a@example.com"}, {ID: c, Email: "c@example.com"}}, dump.Users)
+
+			// Every source is in the dump, not just the first page
+			var urls []string
+			for _, m := range dump.Sources {
+				urls = append(urls, m.URL)
+			}
+			assert.ElementsMatch(t, []string{"google.com", "bing.com", "yahoo.com"}, urls)
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	assert.Subset(t, store.Schemes(), []string{"file", "postgres", "postgresql", "sqlite"})
+
+	assert.Panics(t, func() {
+		store.Register("file", func(context.Context, *url.URL, store.Options) (*store.Backend, error) {
+			return nil, nil
+		})
+	})
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/ankur22/medium-picker/internal/err"
+)
+
+const ErrInvalidCursor = err.Const("invalid cursor")
+
+// cursor is the position of the last source on a page. Sources are
+// ordered by when they were created and then by their id, so the next
+// page starts with the first source that sorts after the cursor. Sources
+// that are added or deleted between pages don't shift the pages.
+type cursor struct {
+	CreatedDate time.Time `json:"c"`
+	ID          string    `json:"i"`
+}
+
+// newCursor returns the opaque token that is handed to clients
+func newCursor(m Medium) string {
+	bb, _ := json.Marshal(cursor{CreatedDate: m.CreatedDate.UTC(), ID: m.ID})
+	return base64.RawURLEncoding.EncodeToString(bb)
+}
+
+// parseCursor returns false when s is empty, i.e. the first page is wanted
+func parseCursor(s string) (cursor, bool, error) {
+	if s == "" {
+		return cursor{}, false, nil
+	}
+
+	bb, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, false, ErrInvalidCursor.Wrap(err)
+	}
+
+	var c cursor
+	if err := json.Unmarshal(bb, &c); err != nil {
+		return cursor{}, false, ErrInvalidCursor.Wrap(err)
+	}
+	if c.ID == "" {
+		return cursor{}, false, ErrInvalidCursor
+	}
+	c.CreatedDate = c.CreatedDate.UTC()
+
+	return c, true, nil
+}
+
+// after reports whether m sorts after the cursor
+func (c cursor) after(m Medium) bool {
+	if !m.CreatedDate.Equal(c.CreatedDate) {
+		return m.CreatedDate.After(c.CreatedDate)
+	}
+	return m.ID > c.ID
+}
+
+// lessMedium is the order that sources are paginated in
+func lessMedium(a, b Medium) bool {
+	if !a.CreatedDate.Equal(b.CreatedDate) {
+		return a.CreatedDate.Before(b.CreatedDate)
+	}
+	return a.ID < b.ID
+}
@@ -0,0 +1,29 @@
+package store
+
+// Crash lets go of the file as if the process that had it open had
+// died, without saving anything, so that it can be opened again
+func (u *UserFile) Crash() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if u.flock == nil {
+		return
+	}
+	_ = u.journal.close()
+	_ = u.flock.release()
+	u.flock = nil
+}
+
+// Crash lets go of the file as if the process that had it open had
+// died, without saving anything, so that it can be opened again
+func (m *MediumFile) Crash() {
+	m.journalLock.Lock()
+	defer m.journalLock.Unlock()
+
+	if m.flock == nil {
+		return
+	}
+	_ = m.journal.close()
+	_ = m.flock.release()
+	m.flock = nil
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/ankur22/medium-picker/internal/err"
+)
+
+const ErrCannotCreateStoreDir = err.Const("cannot create store dir")
+
+// The names of the files that the file backend keeps in its dir
+const (
+	UserFilename   = "users.json"
+	MediumFilename = "mediums.json"
+)
+
+func init() {
+	Register("file", openFileBackend)
+}
+
+// openFileBackend keeps the users and sources in a dir,
+// e.g. file:///var/lib/picker
+func openFileBackend(ctx context.Context, dsn *url.URL, opts Options) (*Backend, error) {
+	dir, err := dsnPath(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.ReadOnly {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, ErrCannotCreateStoreDir.Wrap(err)
+		}
+	}
+
+	var u *UserFile
+	var m *MediumFile
+	if opts.ReadOnly {
+		u, err = OpenUserFileReadOnly(ctx, filepath.Join(dir, UserFilename))
+	} else {
+		u, err = NewUserFile(ctx, filepath.Join(dir, UserFilename), opts.FlushInterval)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ReadOnly {
+		m, err = OpenMediumFileReadOnly(ctx, filepath.Join(dir, MediumFilename), opts.ElemsInPage)
+	} else {
+		m, err = NewMediumFile(ctx, filepath.Join(dir, MediumFilename), opts.FlushInterval, opts.ElemsInPage)
+	}
+	if err != nil {
+		_ = u.Close(ctx)
+		return nil, err
+	}
+
+	return newBackend(u, m, backendFuncs{
+		start: func(ctx context.Context) error {
+			g, gCtx := errgroup.WithContext(ctx)
+			g.Go(func() error {
+				return u.Start(gCtx)
+			})
+			g.Go(func() error {
+				return m.Start(gCtx)
+			})
+			return g.Wait()
+		},
+		close: func(ctx context.Context) error {
+			uErr := u.Close(ctx)
+			if err := m.Close(ctx); err != nil {
+				return err
+			}
+			return uErr
+		},
+		// Every lock is held so that nothing changes between
+		// reading the users and reading the sources
+		dump: func(context.Context) (*Dump, error) {
+			u.lock.RLock()
+			defer u.lock.RUnlock()
+			m.rlockAll()
+			defer m.runlockAll()
+
+			return &Dump{Users: u.listUsers(), Sources: m.allSources()}, nil
+		},
+		check: func(ctx context.Context, repair bool) ([]Problem, error) {
+			return checkFiles(u, m, repair)
+		},
+	}), nil
+}
+
+// writeFileAtomic replaces filename with bb so that a crash part way
+// through leaves either the old or the new contents on disk, never a
+// mix of the two. The data is written to a temp file in the same dir,
+// fsynced and then renamed over filename.
+func writeFileAtomic(filename string, bb []byte) error {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.CreateTemp(dir, base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	if _, err := f.Write(bb); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, filename); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	syncDir(dir)
+
+	return nil
+}
+
+// syncDir makes the rename durable. Not every platform can fsync
+// a dir, so failures are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
@@ -0,0 +1,156 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/service"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+// benchFileStores opens file stores with users that have sources
+// each, and returns the ids of the users
+func benchFileStores(b *testing.B, users, sources int) (*store.UserFile, *store.MediumFile, []string) {
+	b.Helper()
+	ctx := context.Background()
+	dir := b.TempDir()
+
+	type userData struct {
+		Emails map[string]string `json:"emails"`
+		Users  map[string]string `json:"users"`
+	}
+	ud := userData{Emails: map[string]string{}, Users: map[string]string{}}
+	md := map[string]map[string]store.Medium{}
+	ids := make([]string, 0, users)
+
+	created := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < users; i++ {
+		id := fmt.Sprintf("user-%d", i)
+		email := fmt.Sprintf("user-%d@example.com", i)
+		ud.Emails[email] = id
+		ud.Users[id] = email
+		ids = append(ids, id)
+
+		md[id] = map[string]store.Medium{}
+		for j := 0; j < sources; j++ {
+			url := fmt.Sprintf("site-%d.com", j)
+			md[id][url] = store.Medium{
+				URL:          url,
+				ID:           fmt.Sprintf("%s-source-%d", id, j),
+				UserID:       id,
+				Multiplier:   1,
+				CreatedDate:  created.Add(time.Duration(j) * time.Minute),
+				ModifiedDate: created.Add(time.Duration(j) * time.Minute),
+			}
+		}
+	}
+
+	write := func(filename string, data interface{}) {
+		bb, err := json.Marshal(map[string]interface{}{"version": 1, "data": data})
+		require.NoError(b, err)
+		require.NoError(b, os.WriteFile(filename, bb, 0600))
+	}
+	write(filepath.Join(dir, store.UserFilename), ud)
+	write(filepath.Join(dir, store.MediumFilename), md)
+
+	u, err := store.NewUserFile(ctx, filepath.Join(dir, store.UserFilename), time.Hour)
+	require.NoError(b, err)
+	m, err := store.NewMediumFile(ctx, filepath.Join(dir, store.MediumFilename), time.Hour, sources)
+	require.NoError(b, err)
+	b.Cleanup(func() {
+		_ = u.Close(ctx)
+		_ = m.Close(ctx)
+	})
+
+	return u, m, ids
+}
+
+// BenchmarkFileStores measures the throughput of the requests that
+// every user makes, from many goroutines at once. Run it with -cpu
+// to see how it scales, e.g. go test -bench FileStores -cpu 1,4,16
+func BenchmarkFileStores(b *testing.B) {
+	ctx := context.Background()
+	u, m, ids := benchFileStores(b, 1000, 20)
+	p := service.NewPicker(m)
+
+	var seed int64
+	parallel := func(b *testing.B, f func(userID string)) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			r := rand.New(rand.NewSource(atomic.AddInt64(&seed, 1)))
+			for pb.Next() {
+				f(ids[r.Intn(len(ids))])
+			}
+		})
+	}
+
+	b.Run("IsUser", func(b *testing.B) {
+		parallel(b, func(userID string) {
+			if ok, err := u.IsUser(ctx, userID); err != nil || !ok {
+				b.Fatal(ok, err)
+			}
+		})
+	})
+
+	b.Run("GetSources", func(b *testing.B) {
+		parallel(b, func(userID string) {
+			if _, _, err := m.GetSources(ctx, userID, ""); err != nil {
+				b.Fatal(err)
+			}
+		})
+	})
+
+	b.Run("Pick", func(b *testing.B) {
+		parallel(b, func(userID string) {
+			if _, err := p.Pick(ctx, userID, 1, service.PickOptions{}); err != nil {
+				b.Fatal(err)
+			}
+		})
+	})
+
+	// What a user that signs in, lists their sources and picks
+	// one does, while the stores are being flushed
+	b.Run("Mixed", func(b *testing.B) {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					_ = u.Flush(ctx)
+					_ = m.Flush(ctx)
+				}
+			}
+		}()
+
+		var i int64
+		parallel(b, func(userID string) {
+			switch atomic.AddInt64(&i, 1) % 10 {
+			case 0:
+				if _, err := p.Pick(ctx, userID, 1, service.PickOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			case 1, 2, 3:
+				if _, _, err := m.GetSources(ctx, userID, ""); err != nil {
+					b.Fatal(err)
+				}
+			default:
+				if _, err := u.IsUser(ctx, userID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
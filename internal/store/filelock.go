@@ -0,0 +1,61 @@
+package store
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ankur22/medium-picker/internal/err"
+)
+
+const (
+	ErrFileLocked     = err.Const("file is locked by another process")
+	ErrCannotLockFile = err.Const("cannot lock file")
+	ErrReadOnly       = err.Const("store is read only")
+)
+
+// fileLock is an advisory lock on a file store, which stops another
+// process from opening the store while this one has it open. It's
+// taken on a file next to the store's file, as the store's file is
+// replaced every time it's saved.
+type fileLock struct {
+	f *os.File
+}
+
+// lockFilename is where the lock for the store in filename is kept
+func lockFilename(filename string) string {
+	return filename + ".lock"
+}
+
+// lockFile locks the store in filename without waiting. Shared locks
+// are for read only stores, which can be open in many processes at
+// once but not at the same time as a store that can be changed. It
+// returns ErrFileLocked when another process holds a lock that
+// conflicts.
+func lockFile(filename string, shared bool) (*fileLock, error) {
+	name := lockFilename(filename)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDONLY, 0o644)
+	if err != nil {
+		return nil, ErrCannotLockFile.Wrap(err)
+	}
+
+	locked, err := tryLock(f, shared)
+	if err != nil {
+		_ = f.Close()
+		return nil, ErrCannotLockFile.Wrap(err)
+	}
+	if !locked {
+		_ = f.Close()
+		return nil, ErrFileLocked.Wrap(fmt.Errorf("%s", name))
+	}
+
+	return &fileLock{f: f}, nil
+}
+
+// release unlocks the store
+func (l *fileLock) release() error {
+	if err := unlock(l.f); err != nil {
+		_ = l.f.Close()
+		return ErrCannotLockFile.Wrap(err)
+	}
+	return l.f.Close()
+}
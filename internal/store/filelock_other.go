@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package store
+
+import "os"
+
+// tryLock always succeeds, as there's no advisory locking on this
+// platform. Only one process should open a store at a time.
+func tryLock(f *os.File, shared bool) (bool, error) {
+	return true, nil
+}
+
+func unlock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,331 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ankur22/medium-picker/internal/err"
+)
+
+const ErrCannotRepair = err.Const("cannot repair store")
+
+// ProblemKind is a kind of inconsistency that Check looks for
+type ProblemKind string
+
+const (
+	// MismatchedEmail is a user whose id and email don't point at each
+	// other. The users are trusted over the index of their emails.
+	MismatchedEmail ProblemKind = "mismatched email"
+	// OrphanedSource is a source of a user that doesn't exist. It's deleted.
+	OrphanedSource ProblemKind = "orphaned source"
+	// MismatchedUserID is a source whose UserID isn't the user that it's
+	// stored under. It's set to that user.
+	MismatchedUserID ProblemKind = "mismatched user id"
+	// DuplicateSourceID is a source with the same id as an older source,
+	// or without an id. It's given a new id.
+	DuplicateSourceID ProblemKind = "duplicate source id"
+	// ZeroDate is a source without a created or a modified date. It's
+	// set to the other date, or to now if it has neither.
+	ZeroDate ProblemKind = "zero date"
+)
+
+// Problem is an inconsistency that Check found
+type Problem struct {
+	Kind     ProblemKind
+	UserID   string
+	SourceID string
+	Detail   string
+	// Repaired is set once the problem has been repaired
+	Repaired bool
+}
+
+// checkData is what check looks at. It's in the shape that the
+// file stores keep it in, which the other stores can be put into.
+type checkData struct {
+	// emails is the user id of every email
+	emails map[string]string
+	// users is the email of every user id
+	users map[string]string
+	// sources are the sources of every user id by url
+	sources map[string]map[string]Medium
+}
+
+// finding is one or more problems and the journal
+// entries that repair them, if they can be
+type finding struct {
+	problems []Problem
+	users    []userEntry
+	sources  []mediumEntry
+}
+
+// check looks for the problems in d. It doesn't change d.
+func check(d checkData, now time.Time) []finding {
+	var fs []finding
+
+	byEmail := make(map[string][]string, len(d.users))
+	for id, email := range d.users {
+		byEmail[email] = append(byEmail[email], id)
+	}
+
+	for _, id := range sortedKeys(d.users) {
+		email := d.users[id]
+		other, ok := d.emails[email]
+		if ok && other == id {
+			continue
+		}
+
+		f := finding{}
+		p := Problem{Kind: MismatchedEmail, UserID: id}
+		switch {
+		case !ok:
+			p.Detail = fmt.Sprintf("%s isn't in the emails", email)
+			f.users = []userEntry{{Op: opCreate, UserID: id, Email: email}}
+		case d.users[other] == email:
+			// Two users with the same email, which one
+			// of them should keep it can't be guessed
+			p.Detail = fmt.Sprintf("%s is also the email of %s", email, other)
+		default:
+			p.Detail = fmt.Sprintf("%s points at %s", email, other)
+			f.users = []userEntry{{Op: opCreate, UserID: id, Email: email}}
+		}
+		f.problems = []Problem{p}
+		fs = append(fs, f)
+	}
+
+	exists := make(map[string]bool, len(d.users))
+	for id := range d.users {
+		exists[id] = true
+	}
+
+	for _, email := range sortedKeys(d.emails) {
+		id := d.emails[email]
+		got, ok := d.users[id]
+		if ok && got == email {
+			continue
+		}
+		// The email belongs to another user, which is repaired above
+		if len(byEmail[email]) > 0 {
+			continue
+		}
+
+		f := finding{}
+		p := Problem{Kind: MismatchedEmail, UserID: id}
+		if !ok {
+			p.Detail = fmt.Sprintf("%s isn't in the users", email)
+			f.users = []userEntry{{Op: opCreate, UserID: id, Email: email}}
+			exists[id] = true
+		} else {
+			p.Detail = fmt.Sprintf("%s points at a user whose email is %s", email, got)
+			f.users = []userEntry{{Op: opDeleteEmail, UserID: id, Email: email}}
+		}
+		f.problems = []Problem{p}
+		fs = append(fs, f)
+	}
+
+	type ref struct {
+		userID string
+		key    string
+	}
+	var refs []ref
+	byID := map[string][]ref{}
+
+	for _, userID := range sortedUserIDs(d.sources) {
+		val := d.sources[userID]
+		for _, key := range sortedURLs(val) {
+			v := val[key]
+			if !exists[userID] {
+				fs = append(fs, finding{
+					problems: []Problem{{
+						Kind:     OrphanedSource,
+						UserID:   userID,
+						SourceID: v.ID,
+						Detail:   fmt.Sprintf("%s belongs to a user that doesn't exist", key),
+					}},
+					sources: []mediumEntry{{Op: opDelete, UserID: userID, Key: key, Medium: v}},
+				})
+				continue
+			}
+
+			r := ref{userID: userID, key: key}
+			refs = append(refs, r)
+			byID[v.ID] = append(byID[v.ID], r)
+		}
+	}
+
+	// The oldest source keeps the id
+	newIDs := map[ref]string{}
+	for id, rs := range byID {
+		if id != "" && len(rs) == 1 {
+			continue
+		}
+		sort.Slice(rs, func(i, j int) bool {
+			a, b := d.sources[rs[i].userID][rs[i].key], d.sources[rs[j].userID][rs[j].key]
+			if !a.CreatedDate.Equal(b.CreatedDate) {
+				return a.CreatedDate.Before(b.CreatedDate)
+			}
+			if rs[i].userID != rs[j].userID {
+				return rs[i].userID < rs[j].userID
+			}
+			return rs[i].key < rs[j].key
+		})
+		if id != "" {
+			rs = rs[1:]
+		}
+		for _, r := range rs {
+			newIDs[r] = uuid.New().String()
+		}
+	}
+
+	for _, r := range refs {
+		v := d.sources[r.userID][r.key]
+		fixed := v
+
+		var ps []Problem
+		problem := func(kind ProblemKind, detail string, args ...interface{}) {
+			ps = append(ps, Problem{Kind: kind, UserID: r.userID, SourceID: v.ID, Detail: fmt.Sprintf(detail, args...)})
+		}
+
+		if v.UserID != r.userID {
+			problem(MismatchedUserID, "%s has the user id %q", r.key, v.UserID)
+			fixed.UserID = r.userID
+		}
+		if id, ok := newIDs[r]; ok {
+			problem(DuplicateSourceID, "%s is given the id %s", r.key, id)
+			fixed.ID = id
+		}
+		if v.CreatedDate.IsZero() {
+			problem(ZeroDate, "%s has no created date", r.key)
+			fixed.CreatedDate = v.ModifiedDate
+			if fixed.CreatedDate.IsZero() {
+				fixed.CreatedDate = now
+			}
+		}
+		if v.ModifiedDate.IsZero() {
+			problem(ZeroDate, "%s has no modified date", r.key)
+			fixed.ModifiedDate = fixed.CreatedDate
+		}
+
+		if len(ps) > 0 {
+			fs = append(fs, finding{
+				problems: ps,
+				sources:  []mediumEntry{{Op: opUpdate, UserID: r.userID, Key: r.key, Medium: fixed}},
+			})
+		}
+	}
+
+	return fs
+}
+
+// repair applies the entries of the findings that can be repaired
+// with applyUser and applySource. It returns every problem, with the
+// ones that have been repaired marked as such.
+func repair(fs []finding, doRepair bool, applyUser func(userEntry) error, applySource func(mediumEntry) error) ([]Problem, error) {
+	problems := []Problem{}
+	for _, f := range fs {
+		repaired := false
+		if doRepair && (len(f.users) > 0 || len(f.sources) > 0) {
+			for _, e := range f.users {
+				if err := applyUser(e); err != nil {
+					return nil, ErrCannotRepair.Wrap(err)
+				}
+			}
+			for _, e := range f.sources {
+				if err := applySource(e); err != nil {
+					return nil, ErrCannotRepair.Wrap(err)
+				}
+			}
+			repaired = true
+		}
+
+		for _, p := range f.problems {
+			p.Repaired = repaired
+			problems = append(problems, p)
+		}
+	}
+
+	return problems, nil
+}
+
+// checkFiles checks the file stores. Both are locked while they're
+// checked and repaired, and the repairs are journaled like any other
+// change.
+func checkFiles(u *UserFile, m *MediumFile, doRepair bool) ([]Problem, error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	m.lockAll()
+	defer m.unlockAll()
+
+	fs := check(checkData{emails: u.emails, users: u.users, sources: m.sources()}, time.Now().UTC())
+
+	return repair(fs, doRepair,
+		u.write,
+		func(e mediumEntry) error {
+			return m.write(m.shard(e.UserID), e)
+		})
+}
+
+// checkSQL checks the SQL stores. The constraints on the tables rule
+// out most problems, but not sources of users that don't exist or
+// sources without dates.
+func checkSQL(ctx context.Context, u *userSQL, m *mediumSQL, doRepair bool) ([]Problem, error) {
+	dump, err := dumpSQL(ctx, u.db, u.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	d := checkData{
+		emails:  make(map[string]string, len(dump.Users)),
+		users:   make(map[string]string, len(dump.Users)),
+		sources: map[string]map[string]Medium{},
+	}
+	for _, v := range dump.Users {
+		d.emails[v.Email] = v.ID
+		d.users[v.ID] = v.Email
+	}
+	for _, v := range dump.Sources {
+		if _, ok := d.sources[v.UserID]; !ok {
+			d.sources[v.UserID] = map[string]Medium{}
+		}
+		d.sources[v.UserID][v.URL] = v
+	}
+
+	fs := check(d, time.Now().UTC())
+
+	return repair(fs, doRepair,
+		func(e userEntry) error {
+			if e.Op != opCreate {
+				return fmt.Errorf("can't %s in a database", e.Op)
+			}
+			return u.ImportUser(ctx, User{ID: e.UserID, Email: e.Email})
+		},
+		func(e mediumEntry) error {
+			if e.Op == opDelete {
+				return m.DeleteSource(ctx, e.UserID, e.Medium.ID)
+			}
+			return m.ImportSource(ctx, e.Medium)
+		})
+}
+
+// sortedKeys returns the keys of the map, sorted
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedURLs returns the urls of the sources of a user, sorted
+func sortedURLs(m map[string]Medium) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,191 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+func TestBackend_Check_File(t *testing.T) {
+	const (
+		users = `{"version":1,"data":{
+			"emails":{"a@example.com":"user-a","b@example.com":"user-b","stale@example.com":"user-a","lost@example.com":"user-c"},
+			"users":{"user-a":"a@example.com","user-b":"b@example.com","user-d":"d@example.com"}}}`
+		sources = `{"version":1,"data":{
+			"user-a":{
+				"google.com":{"url":"google.com","id":"id-1","user_id":"user-a","created_date":"2020-10-01T12:00:00Z","modified_date":"2020-10-01T12:00:00Z"},
+				"bing.com":{"url":"bing.com","id":"id-2","user_id":"user-b","created_date":"2020-10-01T12:00:00Z","modified_date":"2020-10-01T12:00:00Z"}},
+			"user-b":{
+				"yahoo.com":{"url":"yahoo.com","id":"id-1","user_id":"user-b","created_date":"2020-10-02T12:00:00Z","modified_date":"2020-10-02T12:00:00Z"},
+				"ask.com":{"url":"ask.com","id":"id-3","user_id":"user-b","modified_date":"2020-10-02T12:00:00Z"}},
+			"user-x":{
+				"duckduckgo.com":{"url":"duckduckgo.com","id":"id-4","user_id":"user-x","created_date":"2020-10-01T12:00:00Z","modified_date":"2020-10-01T12:00:00Z"}}}}`
+	)
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, store.UserFilename), []byte(users), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, store.MediumFilename), []byte(sources), 0600))
+
+	dsn := "file://" + filepath.ToSlash(dir)
+	b, err := store.Open(ctx, dsn, store.Options{ElemsInPage: 10, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	type problem struct {
+		kind     store.ProblemKind
+		userID   string
+		sourceID string
+	}
+	want := []problem{
+		{kind: store.MismatchedEmail, userID: "user-c"},
+		{kind: store.MismatchedEmail, userID: "user-a"},
+		{kind: store.MismatchedEmail, userID: "user-d"},
+		{kind: store.MismatchedUserID, userID: "user-a", sourceID: "id-2"},
+		{kind: store.DuplicateSourceID, userID: "user-b", sourceID: "id-1"},
+		{kind: store.ZeroDate, userID: "user-b", sourceID: "id-3"},
+		{kind: store.OrphanedSource, userID: "user-x", sourceID: "id-4"},
+	}
+	toProblems := func(ps []store.Problem) []problem {
+		var got []problem
+		for _, p := range ps {
+			got = append(got, problem{kind: p.Kind, userID: p.UserID, sourceID: p.SourceID})
+		}
+		return got
+	}
+
+	// Checking doesn't change anything
+	ps, err := b.Check(ctx, false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, want, toProblems(ps))
+	for _, p := range ps {
+		assert.False(t, p.Repaired, p)
+	}
+
+	ps, err = b.Check(ctx, false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, want, toProblems(ps))
+
+	ps, err = b.Check(ctx, true)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, want, toProblems(ps))
+	for _, p := range ps {
+		assert.True(t, p.Repaired, p)
+	}
+
+	// The repairs survive the store being opened again
+	require.NoError(t, b.Close(ctx))
+	b, err = store.Open(ctx, dsn, store.Options{ElemsInPage: 10, FlushInterval: time.Hour})
+	require.NoError(t, err)
+	defer b.Close(ctx)
+
+	ps, err = b.Check(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, ps)
+
+	id, err := b.Users.GetUser(ctx, "lost@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "user-c", id)
+	_, err = b.Users.GetUser(ctx, "stale@example.com")
+	assert.True(t, errors.Is(err, store.ErrUserNotFound), err)
+	id, err = b.Users.GetUser(ctx, "d@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "user-d", id)
+
+	dump, err := b.Dump(ctx)
+	require.NoError(t, err)
+	ids := map[string]bool{}
+	for _, m := range dump.Sources {
+		assert.False(t, ids[m.ID], "duplicate id %s", m.ID)
+		ids[m.ID] = true
+		assert.False(t, m.CreatedDate.IsZero(), m.URL)
+		assert.False(t, m.ModifiedDate.IsZero(), m.URL)
+		assert.NotEqual(t, "duckduckgo.com", m.URL)
+	}
+	assert.Len(t, dump.Sources, 4)
+}
+
+func TestBackend_Check_File_DuplicateIDs(t *testing.T) {
+	const (
+		users   = `{"version":1,"data":{"emails":{"a@example.com":"user-a"},"users":{"user-a":"a@example.com"}}}`
+		sources = `{"version":1,"data":{
+			"user-a":{
+				"google.com":{"url":"google.com","id":"id-1","user_id":"user-a","created_date":"2020-10-01T12:00:00Z","modified_date":"2020-10-01T12:00:00Z"},
+				"bing.com":{"url":"bing.com","id":"id-1","user_id":"user-a","created_date":"2020-10-02T12:00:00Z","modified_date":"2020-10-02T12:00:00Z"},
+				"yahoo.com":{"url":"yahoo.com","id":"id-1","user_id":"user-a","created_date":"2020-10-03T12:00:00Z","modified_date":"2020-10-03T12:00:00Z"}}}}`
+	)
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, store.UserFilename), []byte(users), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, store.MediumFilename), []byte(sources), 0600))
+
+	b, err := store.Open(ctx, "file://"+filepath.ToSlash(dir), store.Options{ElemsInPage: 10, FlushInterval: time.Hour})
+	require.NoError(t, err)
+	defer b.Close(ctx)
+
+	ps, err := b.Check(ctx, true)
+	require.NoError(t, err)
+	require.Len(t, ps, 2)
+
+	// Every source can be found by its id straight after the
+	// repair, without the store being opened again
+	all, _, err := b.Medium.GetAllSourceData(ctx, "user-a", "")
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	for _, m := range all {
+		got, err := b.Medium.GetSource(ctx, "user-a", m.ID)
+		require.NoError(t, err, m.URL)
+		assert.Equal(t, m.URL, got.URL)
+		if m.URL == "google.com" {
+			assert.Equal(t, "id-1", m.ID)
+		}
+	}
+}
+
+func TestBackend_Check_SQLite(t *testing.T) {
+	ctx := context.Background()
+
+	b, err := store.Open(ctx, "sqlite://"+filepath.ToSlash(filepath.Join(t.TempDir(), "picker.db")), store.Options{ElemsInPage: 10, FlushInterval: time.Hour})
+	require.NoError(t, err)
+	defer b.Close(ctx)
+
+	uid, err := b.Users.CreateNewUser(ctx, "test@example.com")
+	require.NoError(t, err)
+	require.NoError(t, b.Medium.AddSource(ctx, uid, "google.com"))
+	require.NoError(t, b.Medium.ImportSource(ctx, store.Medium{ID: "some-id", URL: "bing.com", UserID: uid}))
+	require.NoError(t, b.Medium.ImportSource(ctx, store.Medium{
+		ID:           "another-id",
+		URL:          "yahoo.com",
+		UserID:       "some-user-id",
+		CreatedDate:  time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC),
+		ModifiedDate: time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC),
+	}))
+
+	ps, err := b.Check(ctx, true)
+	require.NoError(t, err)
+
+	var kinds []string
+	for _, p := range ps {
+		assert.True(t, p.Repaired, p)
+		kinds = append(kinds, string(p.Kind))
+	}
+	sort.Strings(kinds)
+	assert.Equal(t, []string{string(store.OrphanedSource), string(store.ZeroDate), string(store.ZeroDate)}, kinds)
+
+	ps, err = b.Check(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, ps)
+
+	sources, _, err := b.Medium.GetAllSourceData(ctx, uid, "")
+	require.NoError(t, err)
+	assert.Len(t, sources, 2)
+}
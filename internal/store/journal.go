@@ -0,0 +1,159 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/ankur22/medium-picker/internal/err"
+)
+
+const (
+	ErrCannotOpenJournal   = err.Const("cannot open journal")
+	ErrCannotReplayJournal = err.Const("cannot replay journal")
+	ErrCannotWriteJournal  = err.Const("cannot write journal")
+)
+
+// journal is an append only log of the mutations that have been made
+// since the last snapshot. Each entry is a line of JSON which is fsynced
+// before the mutation is applied in memory, so that an acknowledged
+// request survives a crash.
+type journal struct {
+	filename string
+	f        *os.File
+	size     int64
+}
+
+// journalFilename is where the journal for the snapshot in filename is kept
+func journalFilename(filename string) string {
+	return filename + ".journal"
+}
+
+// openJournal replays every entry in the journal through apply and then
+// opens it for appending. A final entry without a newline was torn by a
+// crash part way through the append, so it's dropped.
+func openJournal(filename string, apply func(entry []byte) error) (*journal, error) {
+	size, err := replayJournal(filename, apply)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, ErrCannotOpenJournal.Wrap(err)
+	}
+
+	if err := f.Truncate(size); err != nil {
+		_ = f.Close()
+		return nil, ErrCannotOpenJournal.Wrap(err)
+	}
+
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, ErrCannotOpenJournal.Wrap(err)
+	}
+
+	return &journal{filename: filename, f: f, size: size}, nil
+}
+
+// replayJournal returns the length of the journal up to
+// the end of the last complete entry
+func replayJournal(filename string, apply func(entry []byte) error) (int64, error) {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, ErrCannotOpenJournal.Wrap(err)
+	}
+	defer f.Close()
+
+	var size int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil {
+			return 0, ErrCannotReplayJournal.Wrap(err)
+		}
+
+		if entry := bytes.TrimSpace(line); len(entry) > 0 {
+			if err := apply(entry); err != nil {
+				return 0, ErrCannotReplayJournal.Wrap(err)
+			}
+		}
+
+		size += int64(len(line))
+	}
+}
+
+// append writes the entry to the end of the journal and fsyncs it. If
+// the write fails the journal is truncated back to the last entry, so
+// that a partial entry can't hide the ones that follow it.
+func (j *journal) append(entry interface{}) error {
+	bb, err := json.Marshal(entry)
+	if err != nil {
+		return ErrCannotWriteJournal.Wrap(err)
+	}
+	bb = append(bb, '\n')
+
+	if _, err := j.f.Write(bb); err != nil {
+		j.rollback()
+		return ErrCannotWriteJournal.Wrap(err)
+	}
+
+	if err := j.f.Sync(); err != nil {
+		j.rollback()
+		return ErrCannotWriteJournal.Wrap(err)
+	}
+
+	j.size += int64(len(bb))
+
+	return nil
+}
+
+func (j *journal) rollback() {
+	_ = j.f.Truncate(j.size)
+	_, _ = j.f.Seek(j.size, io.SeekStart)
+}
+
+// compact drops the entries before offset, as they are in the snapshot.
+// Entries that were appended after offset are kept.
+func (j *journal) compact(offset int64) error {
+	if offset == 0 {
+		return nil
+	}
+
+	tail := make([]byte, j.size-offset)
+	if _, err := j.f.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return ErrCannotWriteJournal.Wrap(err)
+	}
+
+	if err := writeFileAtomic(j.filename, tail); err != nil {
+		return ErrCannotWriteJournal.Wrap(err)
+	}
+
+	f, err := os.OpenFile(j.filename, os.O_RDWR, 0o644)
+	if err != nil {
+		return ErrCannotOpenJournal.Wrap(err)
+	}
+
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		_ = f.Close()
+		return ErrCannotOpenJournal.Wrap(err)
+	}
+
+	_ = j.f.Close()
+	j.f = f
+	j.size = int64(len(tail))
+
+	return nil
+}
+
+func (j *journal) close() error {
+	return j.f.Close()
+}
@@ -3,8 +3,10 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"hash/fnv"
 	"io/ioutil"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -19,47 +21,117 @@ const (
 	ErrCannotOpenMediumFile       = err.Const("cannot open medium file")
 	ErrCannotReadMediumFile       = err.Const("cannot read medium file")
 	ErrCannotUnmarshallMediumFile = err.Const("cannot unmarshall medium file")
+	ErrCannotMarshallMediumFile   = err.Const("cannot marshall medium file")
+	ErrCannotWriteMediumFile      = err.Const("cannot write medium file")
 	ErrCannotFindMedium           = err.Const("cannot find medium")
 )
 
 // Source is the response type
 type Source struct {
-	URL string
-	ID  string
+	URL       string
+	ID        string
+	ItemTitle string
+	ItemLink  string
+	// NewSinceLastRead is whether the site changed since it was last
+	// picked, or has never been. Only picks set it.
+	NewSinceLastRead bool
 }
 
 type Medium struct {
-	URL          string    `json:"url"`
-	ID           string    `json:"id"`
-	Hash         string    `json:"hash"`
-	Multiplier   float32   `json:"multiplier"`
-	CreatedDate  time.Time `json:"created_date"`
-	ModifiedDate time.Time `json:"modified_date"`
-	Hit          int       `json:"hit"`
-	UserID       string    `json:"user_id"`
+	URL             string    `json:"url"`
+	ID              string    `json:"id"`
+	Hash            string    `json:"hash"`
+	Simhash         uint64    `json:"simhash"`
+	Multiplier      float32   `json:"multiplier"`
+	CreatedDate     time.Time `json:"created_date"`
+	ModifiedDate    time.Time `json:"modified_date"`
+	Hit             int       `json:"hit"`
+	UserID          string    `json:"user_id"`
+	ETag            string    `json:"etag"`
+	LastModified    string    `json:"last_modified"`
+	FeedURL         string    `json:"feed_url"`
+	LatestItemID    string    `json:"latest_item_id"`
+	LatestItemTitle string    `json:"latest_item_title"`
+	LatestItemLink  string    `json:"latest_item_link"`
+	LatestItemDate  time.Time `json:"latest_item_date"`
+	LastPickedAt    time.Time `json:"last_picked_at"`
+	Reads           int       `json:"reads"`
+	Skips           int       `json:"skips"`
+	Loves           int       `json:"loves"`
+	Dislikes        int       `json:"dislikes"`
+	LastFeedbackAt  time.Time `json:"last_feedback_at"`
 }
 
+// mediumShards is how many shards the users are spread over
+const mediumShards = 32
+
 // MediumFile is the type that will store the medium information in a file on disk
 type MediumFile struct {
-	filename    string
-	ticker      time.Duration
-	sources     map[string]map[string]Medium
-	lock        sync.Mutex
+	filename string
+	ticker   time.Duration
+	// shards hold the sources of the users, so that users in different
+	// shards don't wait for each other
+	shards [mediumShards]*mediumShard
+	// journalLock guards the journal and dirty. It's taken after the
+	// lock of a shard, never before.
+	journalLock sync.Mutex
+	journal     *journal
 	dirty       bool
+	readOnly    bool
 	elemsInPage int
+	// flock stops other processes from opening the file
+	flock *fileLock
+	// saveLock stops two saves from racing to rename their temp files
+	saveLock  sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// mediumShard is the sources of some of the users
+type mediumShard struct {
+	lock    sync.RWMutex
+	sources map[string]map[string]Medium
+	// ids is where every source is in sources, by its id
+	ids map[string]sourceRef
 }
 
 // NewMediumFile will create a new instance of MediumFile
-// This is not thread safe
+// This is not thread safe. It returns ErrFileLocked if another
+// process has the file open.
 func NewMediumFile(ctx context.Context, filename string, ticker time.Duration, elemsInPage int) (*MediumFile, error) {
+	return openMediumFile(ctx, filename, ticker, elemsInPage, false)
+}
+
+// OpenMediumFileReadOnly opens the sources in filename for reading, e.g.
+// by a tool, without being able to change them. Other processes can
+// open the file read only at the same time, but not for writing.
+func OpenMediumFileReadOnly(ctx context.Context, filename string, elemsInPage int) (*MediumFile, error) {
+	return openMediumFile(ctx, filename, 0, elemsInPage, true)
+}
+
+func openMediumFile(ctx context.Context, filename string, ticker time.Duration, elemsInPage int, readOnly bool) (*MediumFile, error) {
 	m := MediumFile{
 		filename:    filename,
 		ticker:      ticker,
-		sources:     make(map[string]map[string]Medium),
+		readOnly:    readOnly,
 		elemsInPage: elemsInPage,
+		done:        make(chan struct{}),
+	}
+	for i := range m.shards {
+		m.shards[i] = &mediumShard{
+			sources: make(map[string]map[string]Medium),
+			ids:     make(map[string]sourceRef),
+		}
+	}
+
+	l, err := lockFile(filename, readOnly)
+	if err != nil {
+		return nil, err
 	}
+	m.flock = l
 
 	if err := m.load(ctx); err != nil {
+		_ = l.release()
 		return nil, err
 	}
 
@@ -68,228 +140,525 @@ func NewMediumFile(ctx context.Context, filename string, ticker time.Duration, e
 
 // AddSource will add a new url medium source for a userID
 func (m *MediumFile) AddSource(ctx context.Context, userID string, source string) error {
-	m.lock.Lock()
-	defer m.lock.Unlock()
-
-	val, ok := m.sources[userID]
-	if !ok {
-		m.sources[userID] = make(map[string]Medium)
-		val = m.sources[userID]
-	}
+	s := m.shard(userID)
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	if _, ok := val[source]; ok {
+	if _, ok := s.sources[userID][source]; ok {
 		return ErrMediumSourceAlreadyExists
 	}
 
-	val[source] = Medium{
-		URL:          source,
-		ID:           uuid.New().String(),
-		Hash:         "",
-		Multiplier:   0,
-		CreatedDate:  time.Now().UTC(),
-		ModifiedDate: time.Now().UTC(),
-		Hit:          0,
-		UserID:       userID,
+	e := mediumEntry{
+		Op:     opAdd,
+		UserID: userID,
+		Key:    source,
+		Medium: Medium{
+			URL:          source,
+			ID:           uuid.New().String(),
+			Hash:         "",
+			Multiplier:   1,
+			CreatedDate:  time.Now().UTC(),
+			ModifiedDate: time.Now().UTC(),
+			Hit:          0,
+			UserID:       userID,
+		},
 	}
 
-	return nil
+	return m.write(s, e)
 }
 
-// GetSources returns the sources on the selected page
-func (m *MediumFile) GetSources(ctx context.Context, userID string, page int) ([]Source, error) {
-	m.lock.Lock()
-	defer m.lock.Unlock()
-
-	val, ok := m.sources[userID]
-	if !ok {
-		return nil, ErrUserNotFound
+// GetUserIDs returns the ids of all the users that have
+// at least one source
+func (m *MediumFile) GetUserIDs(ctx context.Context) ([]string, error) {
+	ids := []string{}
+	for _, s := range m.shards {
+		s.lock.RLock()
+		for k := range s.sources {
+			ids = append(ids, k)
+		}
+		s.lock.RUnlock()
 	}
+	sort.Strings(ids)
 
-	start := m.elemsInPage * page
-	if start > len(val) {
-		return nil, nil
-	}
+	return ids, nil
+}
 
-	end := start + m.elemsInPage
-	if end > len(val) {
-		end = len(val)
+// sortedUserIDs returns the ids of the users that have sources, sorted
+func sortedUserIDs(sources map[string]map[string]Medium) []string {
+	ids := make([]string, 0, len(sources))
+	for k := range sources {
+		ids = append(ids, k)
 	}
+	sort.Strings(ids)
 
-	count := start
-	var resp []Source
-	for _, v := range val {
-		count++
+	return ids
+}
 
-		if count < start {
-			continue
+// allSources returns the sources of every user, ordered by user and
+// then in the order they were created. Every shard has to be locked.
+func (m *MediumFile) allSources() []Medium {
+	sources := m.sources()
+	all := []Medium{}
+	for _, userID := range sortedUserIDs(sources) {
+		start := len(all)
+		for _, v := range sources[userID] {
+			all = append(all, v)
 		}
+		page := all[start:]
+		sort.Slice(page, func(i, j int) bool {
+			return lessMedium(page[i], page[j])
+		})
+	}
 
-		if count > end {
-			break
-		}
+	return all
+}
 
+// GetSources returns the page of sources after the cursor, along
+// with the cursor of the next page. The first page is returned when
+// the cursor is empty and the next cursor is empty on the last page.
+func (m *MediumFile) GetSources(ctx context.Context, userID string, cursor string) ([]Source, string, error) {
+	all, next, err := m.GetAllSourceData(ctx, userID, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var resp []Source
+	for _, v := range all {
 		resp = append(resp, Source{
 			URL: v.URL,
 			ID:  v.ID,
 		})
 	}
 
-	return resp, nil
+	return resp, next, nil
 }
 
-// GetAllSourceData returns the sources on the selected page
-func (m *MediumFile) GetAllSourceData(ctx context.Context, userID string, page int) ([]Medium, error) {
-	m.lock.Lock()
-	defer m.lock.Unlock()
-
-	val, ok := m.sources[userID]
-	if !ok {
-		return nil, ErrUserNotFound
-	}
-
-	start := m.elemsInPage * page
-	if start > len(val) {
-		return nil, nil
+// GetAllSourceData returns the page of sources after the cursor, along
+// with the cursor of the next page. The sources are ordered by when
+// they were created.
+func (m *MediumFile) GetAllSourceData(ctx context.Context, userID string, cursor string) ([]Medium, string, error) {
+	c, ok, err := parseCursor(cursor)
+	if err != nil {
+		return nil, "", err
 	}
 
-	end := start + m.elemsInPage
-	if end > len(val) {
-		end = len(val)
-	}
+	s := m.shard(userID)
+	s.lock.RLock()
+	// A user without sources just has none
+	val := s.sources[userID]
 
-	count := start
-	var resp []Medium
+	all := make([]Medium, 0, len(val))
 	for _, v := range val {
-		count++
-
-		if count < start {
-			continue
+		if !ok || c.after(v) {
+			all = append(all, v)
 		}
+	}
+	s.lock.RUnlock()
 
-		if count > end {
-			break
-		}
+	sort.Slice(all, func(i, j int) bool {
+		return lessMedium(all[i], all[j])
+	})
 
-		resp = append(resp, v)
+	if len(all) <= m.elemsInPage {
+		if len(all) == 0 {
+			return nil, "", nil
+		}
+		return all, "", nil
 	}
 
-	return resp, nil
+	page := all[:m.elemsInPage]
+	return page, newCursor(page[len(page)-1]), nil
 }
 
 // UpdateSource will update the given source for the user
 func (m *MediumFile) UpdateSource(ctx context.Context, userID string, source Medium) error {
-	m.lock.Lock()
-	defer m.lock.Unlock()
+	s := m.shard(userID)
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	val, ok := m.sources[userID]
+	val, ok := s.sources[userID]
 	if !ok {
 		return ErrUserNotFound
 	}
 
-	var key string
-	for k, v := range val {
-		if v.ID == source.ID {
-			v.CreatedDate = source.CreatedDate
-			v.Hash = source.Hash
-			v.Hit = source.Hit
-			v.ID = source.ID
-			v.ModifiedDate = source.ModifiedDate
-			v.Multiplier = source.Multiplier
-			v.URL = source.URL
-			v.UserID = source.UserID
-			key = k
-			val[k] = v
-			break
-		}
+	ref, ok := s.ids[source.ID]
+	if !ok || ref.userID != userID {
+		return ErrCannotFindMedium
 	}
 
-	if key == "" {
+	v := val[ref.url]
+	v.CreatedDate = source.CreatedDate
+	v.Hash = source.Hash
+	v.Simhash = source.Simhash
+	v.Hit = source.Hit
+	v.ID = source.ID
+	v.ModifiedDate = source.ModifiedDate
+	v.Multiplier = source.Multiplier
+	v.URL = source.URL
+	v.UserID = source.UserID
+	v.ETag = source.ETag
+	v.LastModified = source.LastModified
+	v.FeedURL = source.FeedURL
+	v.LatestItemID = source.LatestItemID
+	v.LatestItemTitle = source.LatestItemTitle
+	v.LatestItemLink = source.LatestItemLink
+	v.LatestItemDate = source.LatestItemDate
+	v.LastPickedAt = source.LastPickedAt
+	v.Reads = source.Reads
+	v.Skips = source.Skips
+	v.Loves = source.Loves
+	v.Dislikes = source.Dislikes
+	v.LastFeedbackAt = source.LastFeedbackAt
+
+	return m.write(s, mediumEntry{Op: opUpdate, UserID: userID, Key: ref.url, Medium: v})
+}
+
+// ModifySource changes the source of the user with the id through fn,
+// which is given the source as it is now. Nothing else can change the
+// source until fn returns, so unlike UpdateSource it can't overwrite what
+// was changed since the caller read the source. fn can't change the ID,
+// UserID or URL, and nothing is written when it returns an error.
+func (m *MediumFile) ModifySource(ctx context.Context, userID string, sourceID string, fn func(source *Medium) error) error {
+	s := m.shard(userID)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	val, ok := s.sources[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	ref, ok := s.ids[sourceID]
+	if !ok || ref.userID != userID {
 		return ErrCannotFindMedium
 	}
 
-	return nil
+	v := val[ref.url]
+	if err := fn(&v); err != nil {
+		return err
+	}
+	v.ID, v.UserID, v.URL = sourceID, userID, ref.url
+
+	return m.write(s, mediumEntry{Op: opUpdate, UserID: userID, Key: ref.url, Medium: v})
+}
+
+// GetSource returns the source of the user with the id
+func (m *MediumFile) GetSource(ctx context.Context, userID string, sourceID string) (Medium, error) {
+	s := m.shard(userID)
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if _, ok := s.sources[userID]; !ok {
+		return Medium{}, ErrUserNotFound
+	}
+
+	ref, ok := s.ids[sourceID]
+	if !ok || ref.userID != userID {
+		return Medium{}, ErrCannotFindMedium
+	}
+
+	return s.sources[ref.userID][ref.url], nil
 }
 
 // DeleteSource will delete a source given the userID and sourceID
 func (m *MediumFile) DeleteSource(ctx context.Context, userID string, sourceID string) error {
-	m.lock.Lock()
-	defer m.lock.Unlock()
+	s := m.shard(userID)
+	s.lock.Lock()
+	defer s.lock.Unlock()
 
-	val, ok := m.sources[userID]
-	if !ok {
+	if _, ok := s.sources[userID]; !ok {
 		return ErrUserNotFound
 	}
 
-	var key string
-	for k, v := range val {
-		if v.ID == sourceID {
-			key = k
-			break
-		}
+	ref, ok := s.ids[sourceID]
+	if !ok || ref.userID != userID {
+		return ErrCannotFindMedium
 	}
 
-	if key == "" {
-		return ErrCannotFindMedium
+	return m.write(s, mediumEntry{Op: opDelete, UserID: userID, Key: ref.url})
+}
+
+// ImportSource adds the source with its id and every other field as
+// they are. A source with the same id is overwritten, so importing the
+// same source twice is harmless.
+func (m *MediumFile) ImportSource(ctx context.Context, source Medium) error {
+	s := m.shard(source.UserID)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if v, ok := s.sources[source.UserID][source.URL]; ok {
+		if v == source {
+			return nil
+		}
+		if v.ID != source.ID {
+			return ErrMediumSourceAlreadyExists
+		}
 	}
 
-	delete(val, key)
+	// The source may have moved to another url
+	if ref, ok := s.ids[source.ID]; ok && ref.userID == source.UserID && ref.url != source.URL {
+		if err := m.write(s, mediumEntry{Op: opDelete, UserID: source.UserID, Key: ref.url}); err != nil {
+			return err
+		}
+	}
 
-	return nil
+	return m.write(s, mediumEntry{Op: opUpdate, UserID: source.UserID, Key: source.URL, Medium: source})
 }
 
 // Start will start the background job that will periodically save
-// what's in memory
+// what's in memory. When ctx is cancelled a final save is performed
+// before returning. It returns nil once Close has been called.
 func (m *MediumFile) Start(ctx context.Context) error {
+	if m.readOnly {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.done:
+			return nil
+		}
+	}
+
 	t := time.NewTicker(m.ticker)
 	defer t.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
+			if err := m.save(ctx); err != nil {
+				return err
+			}
 			return ctx.Err()
+		case <-m.done:
+			return nil
 		case <-t.C:
 		}
 
-		m.lock.Lock()
-		defer m.lock.Unlock()
+		if err := m.save(ctx); err != nil {
+			logging.Error(ctx, "cannot save medium file", zap.Error(err))
+		}
+	}
+}
 
-		if !m.dirty {
-			continue
+// Flush saves any changes that haven't been saved yet
+func (m *MediumFile) Flush(ctx context.Context) error {
+	return m.save(ctx)
+}
+
+// Close stops the background job started by Start, saves any
+// changes that haven't been saved yet, closes the journal and
+// lets other processes open the file
+func (m *MediumFile) Close(ctx context.Context) error {
+	m.closeOnce.Do(func() { close(m.done) })
+	if err := m.save(ctx); err != nil {
+		return err
+	}
+
+	m.journalLock.Lock()
+	defer m.journalLock.Unlock()
+
+	if m.flock == nil {
+		return nil
+	}
+
+	var err error
+	if m.journal != nil {
+		err = m.journal.close()
+	}
+	if lErr := m.flock.release(); err == nil {
+		err = lErr
+	}
+	m.flock = nil
+
+	return err
+}
+
+// save compacts the journal into a snapshot of the sources. The snapshot
+// is written to a temp file that replaces the file on disk, so that a
+// crash mid save doesn't lose the sources, and then the entries that are
+// in the snapshot are dropped from the journal. The shards are copied
+// one at a time and no lock is held while the snapshot is written.
+func (m *MediumFile) save(ctx context.Context) error {
+	if m.readOnly {
+		return nil
+	}
+
+	m.saveLock.Lock()
+	defer m.saveLock.Unlock()
+
+	// Every entry before the offset has been applied to its shard by
+	// the time the lock is released, so it's in the copy. Entries after
+	// it may be too, which is harmless as they're kept in the journal.
+	m.journalLock.Lock()
+	if !m.dirty {
+		m.journalLock.Unlock()
+		return nil
+	}
+	offset := m.journal.size
+	m.dirty = false
+	m.journalLock.Unlock()
+
+	data := make(map[string]map[string]Medium)
+	for _, s := range m.shards {
+		s.lock.RLock()
+		for userID, val := range s.sources {
+			c := make(map[string]Medium, len(val))
+			for k, v := range val {
+				c[k] = v
+			}
+			data[userID] = c
 		}
+		s.lock.RUnlock()
+	}
+
+	bb, err := mediumFormat.marshal(&data)
+	if err != nil {
+		m.setDirty()
+		return ErrCannotMarshallMediumFile.Wrap(err)
+	}
+
+	if err := writeFileAtomic(m.filename, bb); err != nil {
+		m.setDirty()
+		return ErrCannotWriteMediumFile.Wrap(err)
+	}
+
+	// Replaying the entries that are also in the snapshot is harmless,
+	// so a crash before the journal is compacted doesn't lose anything
+	m.journalLock.Lock()
+	err = m.journal.compact(offset)
+	m.journalLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	logging.Info(ctx, "Saved medium file", zap.String("filename", m.filename))
+
+	return nil
+}
 
-		f, err := os.Create(m.filename)
-		if err != nil {
-			return ErrCannotOpenMediumFile.Wrap(err)
+// setDirty makes the next save write the sources, after one failed
+func (m *MediumFile) setDirty() {
+	m.journalLock.Lock()
+	m.dirty = true
+	m.journalLock.Unlock()
+}
+
+// shard returns the shard that the user's sources are in
+func (m *MediumFile) shard(userID string) *mediumShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(userID))
+	return m.shards[h.Sum32()%mediumShards]
+}
+
+// write journals the entries and applies them to s, whose
+// lock has to be held
+func (m *MediumFile) write(s *mediumShard, es ...mediumEntry) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
+	m.journalLock.Lock()
+	defer m.journalLock.Unlock()
+
+	for _, e := range es {
+		if err := m.journal.append(e); err != nil {
+			return err
 		}
-		defer func() {
-			if err := f.Close(); err != nil {
-				logging.Error(ctx, "cannot close medium file", zap.Error(err))
-			}
-		}()
+		s.apply(e)
+		m.dirty = true
+	}
 
-		bb, err := json.Marshal(&m.sources)
-		if err != nil {
-			logging.Error(ctx, "cannot marshal medium source data", zap.Error(err))
-			continue
+	return nil
+}
+
+// lockAll locks every shard, in order
+func (m *MediumFile) lockAll() {
+	for _, s := range m.shards {
+		s.lock.Lock()
+	}
+}
+
+// unlockAll unlocks every shard locked by lockAll
+func (m *MediumFile) unlockAll() {
+	for _, s := range m.shards {
+		s.lock.Unlock()
+	}
+}
+
+// rlockAll read locks every shard, in order
+func (m *MediumFile) rlockAll() {
+	for _, s := range m.shards {
+		s.lock.RLock()
+	}
+}
+
+// runlockAll unlocks every shard locked by rlockAll
+func (m *MediumFile) runlockAll() {
+	for _, s := range m.shards {
+		s.lock.RUnlock()
+	}
+}
+
+// sources returns the sources of every user. The maps of the users
+// are the ones in the shards, so every shard has to be locked.
+func (m *MediumFile) sources() map[string]map[string]Medium {
+	all := make(map[string]map[string]Medium)
+	for _, s := range m.shards {
+		for userID, val := range s.sources {
+			all[userID] = val
 		}
+	}
+	return all
+}
 
-		if _, err := f.Write(bb); err != nil {
-			logging.Error(ctx, "cannot write medium source data", zap.Error(err))
-			continue
+// load reads the snapshot and then replays the journal over it,
+// before opening the journal for new entries unless it's read only
+func (m *MediumFile) load(ctx context.Context) error {
+	version, err := m.loadSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	apply := func(entry []byte) error {
+		var e mediumEntry
+		if err := json.Unmarshal(entry, &e); err != nil {
+			return err
 		}
+		// The journal is written by the same build as the snapshot, so
+		// when the snapshot is from before sources were picked in
+		// proportion to their multiplier, an entry's 0 means it's unset
+		if version < multiplierVersion && e.Op != opDelete && e.Medium.Multiplier == 0 {
+			e.Medium.Multiplier = 1
+		}
+		m.shard(e.UserID).apply(e)
+		m.dirty = true
+		return nil
+	}
 
-		m.dirty = false
+	if m.readOnly {
+		_, err := replayJournal(journalFilename(m.filename), apply)
+		return err
 	}
+
+	j, err := openJournal(journalFilename(m.filename), apply)
+	if err != nil {
+		return err
+	}
+	m.journal = j
+
+	return nil
 }
 
-func (m *MediumFile) load(ctx context.Context) error {
+// loadSnapshot reads the snapshot and returns the version that it was
+// written in. A store without one has only ever been journaled, which
+// is taken to be version 0, like a file that's from before versions.
+func (m *MediumFile) loadSnapshot(ctx context.Context) (int, error) {
 	if _, err := os.Stat(m.filename); os.IsNotExist(err) {
-		return nil
+		return 0, nil
 	}
 
 	f, err := os.Open(m.filename)
 	if err != nil {
-		return ErrCannotOpenMediumFile.Wrap(err)
+		return 0, ErrCannotOpenMediumFile.Wrap(err)
 	}
 	defer func() {
 		if err := f.Close(); err != nil {
@@ -299,16 +668,101 @@ func (m *MediumFile) load(ctx context.Context) error {
 
 	bb, err := ioutil.ReadAll(f)
 	if err != nil {
-		return ErrCannotReadMediumFile.Wrap(err)
+		return 0, ErrCannotReadMediumFile.Wrap(err)
 	}
 
 	var data map[string]map[string]Medium
-	err = json.Unmarshal(bb, &data)
+	version, err := mediumFormat.unmarshal(bb, &data)
 	if err != nil {
-		return ErrCannotUnmarshallMediumFile.Wrap(err)
+		return 0, ErrCannotUnmarshallMediumFile.Wrap(err)
+	}
+	if version < mediumFormat.version() {
+		logging.Info(ctx, "Upgraded medium file", zap.String("filename", m.filename),
+			zap.Int("from", version), zap.Int("to", mediumFormat.version()))
+		// Saved in the current version on the next flush
+		m.dirty = true
+	}
+
+	for userID, val := range data {
+		// Older files kept the users whose sources were all deleted
+		if len(val) == 0 {
+			continue
+		}
+		s := m.shard(userID)
+		s.sources[userID] = val
+		for url, v := range val {
+			s.ids[v.ID] = sourceRef{userID: userID, url: url}
+		}
 	}
 
-	m.sources = data
+	return version, nil
+}
 
-	return nil
+// apply makes the change in the entry to the sources in memory, and
+// keeps the index of their ids up to date. Entries only ever set or
+// remove a source, so replaying an entry that is already in the
+// snapshot leaves the snapshot as it was.
+func (s *mediumShard) apply(e mediumEntry) {
+	val, ok := s.sources[e.UserID]
+	if !ok {
+		val = make(map[string]Medium)
+		s.sources[e.UserID] = val
+	}
+
+	ref := sourceRef{userID: e.UserID, url: e.Key}
+	old, had := val[e.Key]
+
+	switch e.Op {
+	case opAdd, opUpdate:
+		val[e.Key] = e.Medium
+		s.ids[e.Medium.ID] = ref
+	case opDelete:
+		delete(val, e.Key)
+		// Once the last source is gone the user is forgotten, like in
+		// the SQL stores, where the sources are all there is of a user
+		if len(val) == 0 {
+			delete(s.sources, e.UserID)
+		}
+	}
+
+	// The old id only stops pointing at the source when it's gone or
+	// has a new id, e.g. when a repair gives one of two sources with the
+	// same id a new one. The other source then gets the id back.
+	if had && (e.Op == opDelete || old.ID != e.Medium.ID) && s.ids[old.ID] == ref {
+		delete(s.ids, old.ID)
+		s.reindex(old.ID)
+	}
+}
+
+// reindex points the id at a source in the shard that has it, if any
+func (s *mediumShard) reindex(id string) {
+	for userID, val := range s.sources {
+		for url, v := range val {
+			if v.ID == id {
+				s.ids[id] = sourceRef{userID: userID, url: url}
+				return
+			}
+		}
+	}
+}
+
+// sourceRef is where a source is in the sources
+type sourceRef struct {
+	userID string
+	url    string
+}
+
+const (
+	opAdd    = "add"
+	opUpdate = "update"
+	opDelete = "delete"
+)
+
+// mediumEntry is a line in the medium journal. Key is the
+// key of the source in the user's map.
+type mediumEntry struct {
+	Op     string `json:"op"`
+	UserID string `json:"user_id"`
+	Key    string `json:"key"`
+	Medium Medium `json:"medium"`
 }
@@ -2,17 +2,22 @@ package store_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/ankur22/medium-picker/internal/store"
+	"github.com/ankur22/medium-picker/internal/store/storetest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestNewMediumFile_Success(t *testing.T) {
-	m, err := store.NewMediumFile(context.Background(), "filename.json", time.Second, 10)
+	m, err := store.NewMediumFile(context.Background(), filepath.Join(t.TempDir(), "filename.json"), time.Second, 10)
 	assert.NoError(t, err)
 	assert.NotNil(t, m)
 }
@@ -38,7 +43,7 @@ func TestMediumFile_AddSource_Success(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			m, err := store.NewMediumFile(ctx, "filename.json", time.Second, 10)
+			m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "filename.json"), time.Second, 10)
 			require.NoError(t, err)
 			require.NotNil(t, m)
 
@@ -71,7 +76,7 @@ func TestMediumFile_AddSource_Failure(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			m, err := store.NewMediumFile(ctx, "filename.json", time.Second, 10)
+			m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "filename.json"), time.Second, 10)
 			require.NoError(t, err)
 			require.NotNil(t, m)
 
@@ -110,14 +115,14 @@ func TestMediumFile_DeleteSource_Success(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			m, err := store.NewMediumFile(ctx, "filename.json", time.Second, 1)
+			m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "filename.json"), time.Second, 1)
 			require.NoError(t, err)
 			require.NotNil(t, m)
 
 			err = m.AddSource(ctx, tt.args.userID, tt.fields.source)
 			require.NoError(t, err)
 
-			sources, err := m.GetSources(ctx, tt.args.userID, 0)
+			sources, _, err := m.GetSources(ctx, tt.args.userID, "")
 			require.NoError(t, err)
 
 			err = m.DeleteSource(ctx, tt.args.userID, sources[0].ID)
@@ -164,7 +169,7 @@ func TestMediumFile_DeleteSource_Failure(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			m, err := store.NewMediumFile(ctx, "filename.json", time.Second, 1)
+			m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "filename.json"), time.Second, 1)
 			require.NoError(t, err)
 			require.NotNil(t, m)
 
@@ -205,7 +210,7 @@ func TestMediumFile_GetSources_Success(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			m, err := store.NewMediumFile(ctx, "filename.json", time.Second, 5)
+			m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "filename.json"), time.Second, 5)
 			require.NoError(t, err)
 			require.NotNil(t, m)
 
@@ -214,17 +219,23 @@ func TestMediumFile_GetSources_Success(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			var page int
-			sources := make([]store.Source, 0)
+			var cursor string
 			var got []store.Source
-			for sources != nil {
-				sources, err = m.GetSources(ctx, tt.args.userID, page)
-				assert.NoError(t, err)
+			for {
+				sources, next, err := m.GetSources(ctx, tt.args.userID, cursor)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(sources), 5)
 				got = append(got, sources...)
-				page++
+				if next == "" {
+					break
+				}
+				cursor = next
 			}
 
-			assert.Equal(t, tt.fields.count, len(got))
+			require.Equal(t, tt.fields.count, len(got))
+			for i, s := range got {
+				assert.Equal(t, fmt.Sprintf("%d%s", i, tt.fields.source), s.URL)
+			}
 		})
 	}
 }
@@ -236,6 +247,7 @@ func TestMediumFile_GetSources_Failure(t *testing.T) {
 	}
 	type args struct {
 		userID string
+		cursor string
 	}
 	tests := []struct {
 		name   string
@@ -244,27 +256,28 @@ func TestMediumFile_GetSources_Failure(t *testing.T) {
 		want   error
 	}{
 		{
-			name: "user not found",
+			name: "invalid cursor",
 			fields: fields{
 				source: "google.com",
 				count:  20,
 			},
 			args: args{
 				userID: "some-user-id",
+				cursor: "not a cursor",
 			},
-			want: store.ErrUserNotFound,
+			want: store.ErrInvalidCursor,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			m, err := store.NewMediumFile(ctx, "filename.json", time.Second, 5)
+			m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "filename.json"), time.Second, 5)
 			require.NoError(t, err)
 			require.NotNil(t, m)
 
-			source, err := m.GetSources(ctx, tt.args.userID, 0)
-			assert.Equal(t, tt.want, err)
+			source, _, err := m.GetSources(ctx, tt.args.userID, tt.args.cursor)
+			assert.True(t, errors.Is(err, tt.want), err)
 			assert.Nil(t, source)
 		})
 	}
@@ -298,7 +311,7 @@ func TestMediumFile_GetAllSourceData_Success(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			m, err := store.NewMediumFile(ctx, "filename.json", time.Second, 5)
+			m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "filename.json"), time.Second, 5)
 			require.NoError(t, err)
 			require.NotNil(t, m)
 
@@ -307,17 +320,23 @@ func TestMediumFile_GetAllSourceData_Success(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			var page int
-			sources := make([]store.Medium, 0)
+			var cursor string
 			var got []store.Medium
-			for sources != nil {
-				sources, err = m.GetAllSourceData(ctx, tt.args.userID, page)
-				assert.NoError(t, err)
+			for {
+				sources, next, err := m.GetAllSourceData(ctx, tt.args.userID, cursor)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(sources), 5)
 				got = append(got, sources...)
-				page++
+				if next == "" {
+					break
+				}
+				cursor = next
 			}
 
-			assert.Equal(t, tt.fields.count, len(got))
+			require.Equal(t, tt.fields.count, len(got))
+			for i, s := range got {
+				assert.Equal(t, fmt.Sprintf("%d%s", i, tt.fields.source), s.URL)
+			}
 		})
 	}
 }
@@ -328,6 +347,7 @@ func TestMediumFile_GetAllSourceData_Failure(t *testing.T) {
 	}
 	type args struct {
 		userID string
+		cursor string
 	}
 	tests := []struct {
 		name   string
@@ -336,26 +356,27 @@ func TestMediumFile_GetAllSourceData_Failure(t *testing.T) {
 		want   error
 	}{
 		{
-			name: "user not found",
+			name: "invalid cursor",
 			fields: fields{
 				source: "google.com",
 			},
 			args: args{
 				userID: "some-user-id",
+				cursor: "not a cursor",
 			},
-			want: store.ErrUserNotFound,
+			want: store.ErrInvalidCursor,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			m, err := store.NewMediumFile(ctx, "filename.json", time.Second, 5)
+			m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "filename.json"), time.Second, 5)
 			require.NoError(t, err)
 			require.NotNil(t, m)
 
-			source, err := m.GetAllSourceData(ctx, tt.args.userID, 0)
-			assert.Equal(t, tt.want, err)
+			source, _, err := m.GetAllSourceData(ctx, tt.args.userID, tt.args.cursor)
+			assert.True(t, errors.Is(err, tt.want), err)
 			assert.Nil(t, source)
 		})
 	}
@@ -387,14 +408,14 @@ func TestMediumFile_UpdateSource_Success(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			m, err := store.NewMediumFile(ctx, "filename.json", time.Second, 1)
+			m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "filename.json"), time.Second, 1)
 			require.NoError(t, err)
 			require.NotNil(t, m)
 
 			err = m.AddSource(ctx, tt.args.userID, tt.fields.source)
 			require.NoError(t, err)
 
-			sources, err := m.GetAllSourceData(ctx, tt.args.userID, 0)
+			sources, _, err := m.GetAllSourceData(ctx, tt.args.userID, "")
 			require.NoError(t, err)
 
 			sources[0].Hit = 2
@@ -403,7 +424,7 @@ func TestMediumFile_UpdateSource_Success(t *testing.T) {
 			err = m.UpdateSource(ctx, tt.args.userID, sources[0])
 			assert.NoError(t, err)
 
-			sources, err = m.GetAllSourceData(ctx, tt.args.userID, 0)
+			sources, _, err = m.GetAllSourceData(ctx, tt.args.userID, "")
 			require.NoError(t, err)
 
 			assert.Equal(t, "a09sdj", sources[0].Hash)
@@ -450,7 +471,7 @@ func TestMediumFile_UpdateSource_Failure(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			m, err := store.NewMediumFile(ctx, "filename.json", time.Second, 1)
+			m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "filename.json"), time.Second, 1)
 			require.NoError(t, err)
 			require.NotNil(t, m)
 
@@ -462,3 +483,416 @@ func TestMediumFile_UpdateSource_Failure(t *testing.T) {
 		})
 	}
 }
+
+func TestMediumFile_GetUserIDs_Success(t *testing.T) {
+	tests := []struct {
+		name    string
+		userIDs []string
+	}{
+		{
+			name:    "no users",
+			userIDs: []string{},
+		},
+		{
+			name:    "multiple users",
+			userIDs: []string{"some-user-id", "another-user-id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "filename.json"), time.Second, 1)
+			require.NoError(t, err)
+			require.NotNil(t, m)
+
+			for _, id := range tt.userIDs {
+				err = m.AddSource(ctx, id, "google.com")
+				require.NoError(t, err)
+			}
+
+			got, err := m.GetUserIDs(ctx)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, tt.userIDs, got)
+		})
+	}
+}
+
+func TestMediumFile_Flush(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(ctx context.Context, m *store.MediumFile) error
+		want   []string
+	}{
+		{
+			name: "add",
+			mutate: func(ctx context.Context, m *store.MediumFile) error {
+				return m.AddSource(ctx, "some-user-id", "bing.com")
+			},
+			want: []string{"bing.com", "google.com"},
+		},
+		{
+			name: "update",
+			mutate: func(ctx context.Context, m *store.MediumFile) error {
+				sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+				if err != nil {
+					return err
+				}
+				sources[0].Hash = "a09sdj"
+				return m.UpdateSource(ctx, "some-user-id", sources[0])
+			},
+			want: []string{"google.com"},
+		},
+		{
+			name: "delete",
+			mutate: func(ctx context.Context, m *store.MediumFile) error {
+				sources, _, err := m.GetSources(ctx, "some-user-id", "")
+				if err != nil {
+					return err
+				}
+				return m.DeleteSource(ctx, "some-user-id", sources[0].ID)
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			filename := filepath.Join(t.TempDir(), "mediums.json")
+
+			m, err := store.NewMediumFile(ctx, filename, time.Hour, 10)
+			require.NoError(t, err)
+
+			err = m.AddSource(ctx, "some-user-id", "google.com")
+			require.NoError(t, err)
+			err = m.Flush(ctx)
+			require.NoError(t, err)
+
+			err = tt.mutate(ctx, m)
+			require.NoError(t, err)
+			err = m.Flush(ctx)
+			require.NoError(t, err)
+
+			m.Crash()
+			reopened, err := store.NewMediumFile(ctx, filename, time.Hour, 10)
+			require.NoError(t, err)
+
+			sources, _, err := reopened.GetAllSourceData(ctx, "some-user-id", "")
+			require.NoError(t, err)
+
+			var got []string
+			for _, s := range sources {
+				got = append(got, s.URL)
+			}
+			assert.ElementsMatch(t, tt.want, got)
+
+			files, err := filepath.Glob(filename + ".tmp-*")
+			require.NoError(t, err)
+			assert.Empty(t, files)
+		})
+	}
+}
+
+func TestMediumFile_Close(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "mediums.json")
+
+	m, err := store.NewMediumFile(ctx, filename, time.Hour, 10)
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+
+	err = m.AddSource(ctx, "some-user-id", "google.com")
+	require.NoError(t, err)
+
+	err = m.Close(ctx)
+	require.NoError(t, err)
+	assert.NoError(t, <-done)
+
+	reopened, err := store.NewMediumFile(ctx, filename, time.Hour, 10)
+	require.NoError(t, err)
+
+	ids, err := reopened.GetUserIDs(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"some-user-id"}, ids)
+}
+
+func TestMediumFile_Journal(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "mediums.json")
+
+	m, err := store.NewMediumFile(ctx, filename, time.Hour, 10)
+	require.NoError(t, err)
+
+	for _, s := range []string{"google.com", "bing.com", "yahoo.com"} {
+		err = m.AddSource(ctx, "some-user-id", s)
+		require.NoError(t, err)
+	}
+
+	// Compact the adds into the snapshot, so that the update
+	// and delete are replayed over the snapshot
+	err = m.Flush(ctx)
+	require.NoError(t, err)
+
+	sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+	require.NoError(t, err)
+
+	byURL := map[string]store.Medium{}
+	for _, s := range sources {
+		byURL[s.URL] = s
+	}
+
+	updated := byURL["google.com"]
+	updated.Hash = "a09sdj"
+	err = m.UpdateSource(ctx, "some-user-id", updated)
+	require.NoError(t, err)
+
+	err = m.DeleteSource(ctx, "some-user-id", byURL["bing.com"].ID)
+	require.NoError(t, err)
+
+	m.Crash()
+	reopened, err := store.NewMediumFile(ctx, filename, time.Hour, 10)
+	require.NoError(t, err)
+
+	sources, _, err = reopened.GetAllSourceData(ctx, "some-user-id", "")
+	require.NoError(t, err)
+	require.Len(t, sources, 2)
+
+	got := map[string]store.Medium{}
+	for _, s := range sources {
+		got[s.URL] = s
+	}
+	assert.Equal(t, "a09sdj", got["google.com"].Hash)
+	assert.Contains(t, got, "yahoo.com")
+	assert.NotContains(t, got, "bing.com")
+}
+
+func TestMediumFile_Journal_Multiplier(t *testing.T) {
+	const journal = `{"op":"add","user_id":"some-user-id","key":"google.com","medium":{"url":"google.com","id":"some-id","user_id":"some-user-id","multiplier":0}}
+{"op":"update","user_id":"some-user-id","key":"bing.com","medium":{"url":"bing.com","id":"other-id","user_id":"some-user-id","hit":2,"multiplier":0}}
+{"op":"add","user_id":"some-user-id","key":"yahoo.com","medium":{"url":"yahoo.com","id":"third-id","user_id":"some-user-id","multiplier":2.5}}
+`
+
+	tests := []struct {
+		name     string
+		snapshot string
+		want     map[string]float32
+	}{
+		{
+			// Entries from before sources were picked in proportion
+			// to their multiplier were written with one of 0
+			name: "Without a snapshot",
+			want: map[string]float32{"google.com": 1, "bing.com": 1, "yahoo.com": 2.5},
+		},
+		{
+			name:     "After a snapshot from before the multiplier",
+			snapshot: `{"version":1,"data":{}}`,
+			want:     map[string]float32{"google.com": 1, "bing.com": 1, "yahoo.com": 2.5},
+		},
+		{
+			name:     "After a snapshot from since the multiplier",
+			snapshot: `{"version":2,"data":{}}`,
+			want:     map[string]float32{"google.com": 0, "bing.com": 0, "yahoo.com": 2.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			filename := filepath.Join(t.TempDir(), "mediums.json")
+
+			if tt.snapshot != "" {
+				require.NoError(t, os.WriteFile(filename, []byte(tt.snapshot), 0600))
+			}
+			require.NoError(t, os.WriteFile(filename+".journal", []byte(journal), 0600))
+
+			m, err := store.NewMediumFile(ctx, filename, time.Hour, 10)
+			require.NoError(t, err)
+			defer func() { assert.NoError(t, m.Close(ctx)) }()
+
+			sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+			require.NoError(t, err)
+
+			got := map[string]float32{}
+			for _, s := range sources {
+				got[s.URL] = s.Multiplier
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMediumFile_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "mediums.json")
+
+	m, err := store.NewMediumFile(ctx, filename, time.Hour, 10)
+	require.NoError(t, err)
+
+	err = m.AddSource(ctx, "some-user-id", "google.com")
+	require.NoError(t, err)
+	err = m.Flush(ctx)
+	require.NoError(t, err)
+
+	_, err = store.OpenMediumFileReadOnly(ctx, filename, 10)
+	assert.True(t, errors.Is(err, store.ErrFileLocked), err)
+
+	err = m.AddSource(ctx, "some-user-id", "bing.com")
+	require.NoError(t, err)
+	m.Crash()
+
+	ro, err := store.OpenMediumFileReadOnly(ctx, filename, 10)
+	require.NoError(t, err)
+
+	// Both the snapshot and the journal are read
+	sources, _, err := ro.GetAllSourceData(ctx, "some-user-id", "")
+	require.NoError(t, err)
+	require.Len(t, sources, 2)
+
+	err = ro.AddSource(ctx, "some-user-id", "yahoo.com")
+	assert.True(t, errors.Is(err, store.ErrReadOnly), err)
+	err = ro.UpdateSource(ctx, "some-user-id", sources[0])
+	assert.True(t, errors.Is(err, store.ErrReadOnly), err)
+	err = ro.DeleteSource(ctx, "some-user-id", sources[0].ID)
+	assert.True(t, errors.Is(err, store.ErrReadOnly), err)
+
+	_, err = store.NewMediumFile(ctx, filename, time.Hour, 10)
+	assert.True(t, errors.Is(err, store.ErrFileLocked), err)
+
+	err = ro.Close(ctx)
+	require.NoError(t, err)
+
+	reopened, err := store.NewMediumFile(ctx, filename, time.Hour, 10)
+	require.NoError(t, err)
+	sources, _, err = reopened.GetAllSourceData(ctx, "some-user-id", "")
+	require.NoError(t, err)
+	assert.Len(t, sources, 2)
+	assert.NoError(t, reopened.Close(ctx))
+}
+
+func TestMediumFile_GetSources_Cursor(t *testing.T) {
+	ctx := context.Background()
+
+	m, err := store.NewMediumFile(ctx, filepath.Join(t.TempDir(), "filename.json"), time.Second, 2)
+	require.NoError(t, err)
+
+	for _, s := range []string{"a.com", "b.com", "c.com", "d.com"} {
+		err = m.AddSource(ctx, "some-user-id", s)
+		require.NoError(t, err)
+	}
+
+	first, next, err := m.GetSources(ctx, "some-user-id", "")
+	require.NoError(t, err)
+	require.NotEmpty(t, next)
+	assert.Equal(t, "a.com", first[0].URL)
+	assert.Equal(t, "b.com", first[1].URL)
+
+	// Deleting a source on the first page doesn't shift the second page
+	err = m.DeleteSource(ctx, "some-user-id", first[0].ID)
+	require.NoError(t, err)
+
+	second, next, err := m.GetSources(ctx, "some-user-id", next)
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, second, 2)
+	assert.Equal(t, "c.com", second[0].URL)
+	assert.Equal(t, "d.com", second[1].URL)
+
+	_, _, err = m.GetSources(ctx, "some-user-id", "not a cursor")
+	assert.True(t, errors.Is(err, store.ErrInvalidCursor))
+}
+
+func TestMediumFile_Version(t *testing.T) {
+	const sources = `{"some-user-id":{"google.com":{"url":"google.com","id":"some-id","user_id":"some-user-id","hit":3,"multiplier":1.5,"simhash":18446744073709551615}}}`
+	const picked = `{"some-user-id":{"google.com":{"url":"google.com","id":"some-id","user_id":"some-user-id","hit":3,"multiplier":1.5,"simhash":18446744073709551615,"last_picked_hash":"a09sdj"}}}`
+	const loved = `{"some-user-id":{"google.com":{"url":"google.com","id":"some-id","user_id":"some-user-id","hit":3,"multiplier":1.5,"simhash":18446744073709551615,"loves":2}}}`
+	const unset = `{"some-user-id":{"google.com":{"url":"google.com","id":"some-id","user_id":"some-user-id","hit":3,"multiplier":0,"simhash":18446744073709551615}}}`
+
+	tests := []struct {
+		name           string
+		file           string
+		wantMultiplier float32
+		wantLoves      int
+		wantErr        error
+	}{
+		{
+			name:           "Unversioned file is upgraded",
+			file:           sources,
+			wantMultiplier: 1.5,
+		},
+		{
+			name:           "Unset multiplier is upgraded to 1",
+			file:           `{"version":1,"data":` + unset + `}`,
+			wantMultiplier: 1,
+		},
+		{
+			name:           "Hash from the last pick is dropped",
+			file:           `{"version":2,"data":` + picked + `}`,
+			wantMultiplier: 1.5,
+		},
+		{
+			name:           "Version 3 is upgraded",
+			file:           `{"version":3,"data":` + sources + `}`,
+			wantMultiplier: 1.5,
+		},
+		{
+			name:           "Current version",
+			file:           `{"version":4,"data":` + loved + `}`,
+			wantMultiplier: 1.5,
+			wantLoves:      2,
+		},
+		{
+			name:    "Newer version is refused",
+			file:    `{"version":5,"data":{}}`,
+			wantErr: store.ErrUnsupportedFileVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			filename := filepath.Join(t.TempDir(), "some-file.txt")
+			require.NoError(t, os.WriteFile(filename, []byte(tt.file), 0600))
+
+			m, err := store.NewMediumFile(ctx, filename, time.Second, 10)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), err)
+				return
+			}
+			require.NoError(t, err)
+
+			got, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+			require.NoError(t, err)
+			require.Len(t, got, 1)
+			assert.Equal(t, 3, got[0].Hit)
+			assert.Equal(t, tt.wantMultiplier, got[0].Multiplier)
+			assert.Equal(t, tt.wantLoves, got[0].Loves)
+			assert.Equal(t, uint64(18446744073709551615), got[0].Simhash)
+
+			// The file is always saved in the current version
+			require.NoError(t, m.Close(ctx))
+			bb, err := os.ReadFile(filename)
+			require.NoError(t, err)
+			var e struct {
+				Version int `json:"version"`
+			}
+			require.NoError(t, json.Unmarshal(bb, &e))
+			assert.Equal(t, 4, e.Version)
+			assert.NotContains(t, string(bb), "last_picked_hash")
+		})
+	}
+}
+
+func TestMediumFile_Conformance(t *testing.T) {
+	storetest.RunMediumSourceStorerSuite(t, func(t *testing.T, elemsInPage int) func() storetest.MediumSourceStorer {
+		filename := filepath.Join(t.TempDir(), "mediums.json")
+		return func() storetest.MediumSourceStorer {
+			m, err := store.NewMediumFile(context.Background(), filename, time.Hour, elemsInPage)
+			require.NoError(t, err)
+			return m
+		}
+	})
+}
@@ -0,0 +1,323 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// mediumColumns are the columns of medium_sources in the order
+// that scanMedium expects them
+const mediumColumns = `id, user_id, url, hash, simhash, multiplier, created_date, modified_date, hit,
+	etag, last_modified, feed_url, latest_item_id, latest_item_title, latest_item_link, latest_item_date, last_picked_at,
+	reads, skips, loves, dislikes, last_feedback_at`
+
+// mediumSQL stores the medium information in a database through database/sql.
+// The statements are written so that they work with every dialect.
+type mediumSQL struct {
+	db          *sql.DB
+	dialect     dialect
+	elemsInPage int
+}
+
+// AddSource will add a new url medium source for a userID
+func (m *mediumSQL) AddSource(ctx context.Context, userID string, source string) error {
+	now := time.Now().UTC()
+
+	_, err := m.db.ExecContext(ctx,
+		`INSERT INTO medium_sources (id, user_id, url, multiplier, created_date, modified_date, latest_item_date, last_picked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		uuid.New().String(), userID, source, 1, now, now, time.Time{}, time.Time{})
+	if m.dialect.isUniqueViolation(err) {
+		return ErrMediumSourceAlreadyExists
+	}
+	if err != nil {
+		return m.dialect.errQuery.Wrap(err)
+	}
+
+	return nil
+}
+
+// GetUserIDs returns the ids of all the users that have
+// at least one source
+func (m *mediumSQL) GetUserIDs(ctx context.Context) ([]string, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT DISTINCT user_id FROM medium_sources ORDER BY user_id`)
+	if err != nil {
+		return nil, m.dialect.errQuery.Wrap(err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, m.dialect.errQuery.Wrap(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, m.dialect.errQuery.Wrap(err)
+	}
+
+	return ids, nil
+}
+
+// GetSources returns the page of sources after the cursor, along
+// with the cursor of the next page. The first page is returned when
+// the cursor is empty and the next cursor is empty on the last page.
+func (m *mediumSQL) GetSources(ctx context.Context, userID string, cursor string) ([]Source, string, error) {
+	all, next, err := m.GetAllSourceData(ctx, userID, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var resp []Source
+	for _, v := range all {
+		resp = append(resp, Source{
+			URL: v.URL,
+			ID:  v.ID,
+		})
+	}
+
+	return resp, next, nil
+}
+
+// GetAllSourceData returns the page of sources after the cursor, along
+// with the cursor of the next page. The sources are ordered by when
+// they were created.
+func (m *mediumSQL) GetAllSourceData(ctx context.Context, userID string, cursor string) ([]Medium, string, error) {
+	c, ok, err := parseCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// One more than a page is selected to find out whether there's a next page
+	var rows *sql.Rows
+	if ok {
+		rows, err = m.db.QueryContext(ctx,
+			`SELECT `+mediumColumns+` FROM medium_sources
+			WHERE user_id = $1 AND (created_date > $2 OR (created_date = $2 AND id > $3))
+			ORDER BY created_date, id LIMIT $4`,
+			userID, c.CreatedDate, c.ID, m.elemsInPage+1)
+	} else {
+		rows, err = m.db.QueryContext(ctx,
+			`SELECT `+mediumColumns+` FROM medium_sources WHERE user_id = $1
+			ORDER BY created_date, id LIMIT $2`,
+			userID, m.elemsInPage+1)
+	}
+	if err != nil {
+		return nil, "", m.dialect.errQuery.Wrap(err)
+	}
+	defer rows.Close()
+
+	var resp []Medium
+	for rows.Next() {
+		v, err := scanMedium(rows)
+		if err != nil {
+			return nil, "", m.dialect.errQuery.Wrap(err)
+		}
+		resp = append(resp, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", m.dialect.errQuery.Wrap(err)
+	}
+
+	// A user without sources just has none
+	if len(resp) == 0 {
+		return nil, "", nil
+	}
+
+	if len(resp) <= m.elemsInPage {
+		return resp, "", nil
+	}
+
+	resp = resp[:m.elemsInPage]
+	return resp, newCursor(resp[len(resp)-1]), nil
+}
+
+// UpdateSource will update the given source for the user
+func (m *mediumSQL) UpdateSource(ctx context.Context, userID string, source Medium) error {
+	res, err := m.update(ctx, m.db, userID, source)
+	if m.dialect.isUniqueViolation(err) {
+		return ErrMediumSourceAlreadyExists
+	}
+	if err != nil {
+		return m.dialect.errQuery.Wrap(err)
+	}
+
+	return m.checkAffected(ctx, res, userID)
+}
+
+// ModifySource changes the source of the user with the id through fn,
+// which is given the source as it is now. The row is locked until fn
+// returns, so unlike UpdateSource it can't overwrite what was changed
+// since the caller read the source. fn can't change the ID, UserID or
+// URL, and nothing is written when it returns an error.
+func (m *mediumSQL) ModifySource(ctx context.Context, userID string, sourceID string, fn func(source *Medium) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return m.dialect.errQuery.Wrap(err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	v, err := scanMedium(tx.QueryRowContext(ctx,
+		`SELECT `+mediumColumns+` FROM medium_sources WHERE user_id = $1 AND id = $2`+m.dialect.forUpdate, userID, sourceID))
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = tx.Rollback()
+		if err := m.userExists(ctx, userID); err != nil {
+			return err
+		}
+		return ErrCannotFindMedium
+	}
+	if err != nil {
+		return m.dialect.errQuery.Wrap(err)
+	}
+
+	sourceURL := v.URL
+	if err := fn(&v); err != nil {
+		return err
+	}
+	v.ID, v.UserID, v.URL = sourceID, userID, sourceURL
+
+	if _, err := m.update(ctx, tx, userID, v); err != nil {
+		return m.dialect.errQuery.Wrap(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return m.dialect.errQuery.Wrap(err)
+	}
+
+	return nil
+}
+
+// update writes every field of the source
+func (m *mediumSQL) update(ctx context.Context, db execer, userID string, source Medium) (sql.Result, error) {
+	return db.ExecContext(ctx,
+		`UPDATE medium_sources SET url = $3, hash = $4, simhash = $5, multiplier = $6, created_date = $7,
+			modified_date = $8, hit = $9, etag = $10, last_modified = $11, feed_url = $12, latest_item_id = $13,
+			latest_item_title = $14, latest_item_link = $15, latest_item_date = $16, last_picked_at = $17,
+			reads = $18, skips = $19, loves = $20, dislikes = $21, last_feedback_at = $22
+		WHERE user_id = $1 AND id = $2`,
+		userID, source.ID, source.URL, source.Hash, int64(source.Simhash), source.Multiplier, source.CreatedDate.UTC(),
+		source.ModifiedDate.UTC(), source.Hit, source.ETag, source.LastModified, source.FeedURL, source.LatestItemID,
+		source.LatestItemTitle, source.LatestItemLink, source.LatestItemDate.UTC(), source.LastPickedAt.UTC(),
+		source.Reads, source.Skips, source.Loves, source.Dislikes, source.LastFeedbackAt.UTC())
+}
+
+// DeleteSource will delete a source given the userID and sourceID
+func (m *mediumSQL) DeleteSource(ctx context.Context, userID string, sourceID string) error {
+	res, err := m.db.ExecContext(ctx, `DELETE FROM medium_sources WHERE user_id = $1 AND id = $2`, userID, sourceID)
+	if err != nil {
+		return m.dialect.errQuery.Wrap(err)
+	}
+
+	return m.checkAffected(ctx, res, userID)
+}
+
+// GetSource returns the source of the user with the id
+func (m *mediumSQL) GetSource(ctx context.Context, userID string, sourceID string) (Medium, error) {
+	v, err := scanMedium(m.db.QueryRowContext(ctx,
+		`SELECT `+mediumColumns+` FROM medium_sources WHERE user_id = $1 AND id = $2`, userID, sourceID))
+	if errors.Is(err, sql.ErrNoRows) {
+		if err := m.userExists(ctx, userID); err != nil {
+			return Medium{}, err
+		}
+		return Medium{}, ErrCannotFindMedium
+	}
+	if err != nil {
+		return Medium{}, m.dialect.errQuery.Wrap(err)
+	}
+
+	return v, nil
+}
+
+// checkAffected returns ErrUserNotFound or ErrCannotFindMedium
+// when the statement didn't match a row
+func (m *mediumSQL) checkAffected(ctx context.Context, res sql.Result, userID string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return m.dialect.errQuery.Wrap(err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	if err := m.userExists(ctx, userID); err != nil {
+		return err
+	}
+	return ErrCannotFindMedium
+}
+
+// userExists returns ErrUserNotFound when the user has no sources
+func (m *mediumSQL) userExists(ctx context.Context, userID string) error {
+	var ok bool
+	err := m.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM medium_sources WHERE user_id = $1)`, userID).Scan(&ok)
+	if err != nil {
+		return m.dialect.errQuery.Wrap(err)
+	}
+	if !ok {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMedium(s scanner) (Medium, error) {
+	var v Medium
+	var simhash int64
+	err := s.Scan(&v.ID, &v.UserID, &v.URL, &v.Hash, &simhash, &v.Multiplier, &v.CreatedDate, &v.ModifiedDate,
+		&v.Hit, &v.ETag, &v.LastModified, &v.FeedURL, &v.LatestItemID, &v.LatestItemTitle, &v.LatestItemLink,
+		&v.LatestItemDate, &v.LastPickedAt, &v.Reads, &v.Skips, &v.Loves, &v.Dislikes,
+		&v.LastFeedbackAt)
+	if err != nil {
+		return Medium{}, err
+	}
+
+	v.Simhash = uint64(simhash)
+	v.CreatedDate = v.CreatedDate.UTC()
+	v.ModifiedDate = v.ModifiedDate.UTC()
+	v.LatestItemDate = v.LatestItemDate.UTC()
+	v.LastPickedAt = v.LastPickedAt.UTC()
+	v.LastFeedbackAt = v.LastFeedbackAt.UTC()
+
+	return v, nil
+}
+
+// ImportSource adds the source with its id and every other field as
+// they are. A source with the same id is overwritten, so importing the
+// same source twice is harmless.
+func (m *mediumSQL) ImportSource(ctx context.Context, source Medium) error {
+	_, err := m.db.ExecContext(ctx,
+		`INSERT INTO medium_sources (`+mediumColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
+		ON CONFLICT (id) DO UPDATE SET user_id = excluded.user_id, url = excluded.url, hash = excluded.hash,
+			simhash = excluded.simhash, multiplier = excluded.multiplier, created_date = excluded.created_date,
+			modified_date = excluded.modified_date, hit = excluded.hit, etag = excluded.etag,
+			last_modified = excluded.last_modified, feed_url = excluded.feed_url,
+			latest_item_id = excluded.latest_item_id, latest_item_title = excluded.latest_item_title,
+			latest_item_link = excluded.latest_item_link, latest_item_date = excluded.latest_item_date,
+			last_picked_at = excluded.last_picked_at,
+			reads = excluded.reads, skips = excluded.skips, loves = excluded.loves, dislikes = excluded.dislikes,
+			last_feedback_at = excluded.last_feedback_at`,
+		source.ID, source.UserID, source.URL, source.Hash, int64(source.Simhash), source.Multiplier,
+		source.CreatedDate.UTC(), source.ModifiedDate.UTC(), source.Hit, source.ETag, source.LastModified,
+		source.FeedURL, source.LatestItemID, source.LatestItemTitle, source.LatestItemLink,
+		source.LatestItemDate.UTC(), source.LastPickedAt.UTC(), source.Reads, source.Skips,
+		source.Loves, source.Dislikes, source.LastFeedbackAt.UTC())
+	if m.dialect.isUniqueViolation(err) {
+		return ErrMediumSourceAlreadyExists
+	}
+	if err != nil {
+		return m.dialect.errQuery.Wrap(err)
+	}
+
+	return nil
+}
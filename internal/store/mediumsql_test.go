@@ -0,0 +1,248 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+func TestMediumSQL_AddSource_Failure(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			m := b.newMedium(t, 10)
+
+			err := m.AddSource(ctx, "some-user-id", "google.com")
+			require.NoError(t, err)
+
+			got := m.AddSource(ctx, "some-user-id", "google.com")
+			assert.Equal(t, store.ErrMediumSourceAlreadyExists, got)
+
+			err = m.AddSource(ctx, "another-user-id", "google.com")
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMediumSQL_GetSources_Success(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			m := b.newMedium(t, 5)
+
+			for i := 0; i < 12; i++ {
+				err := m.AddSource(ctx, "some-user-id", fmt.Sprintf("%dgoogle.com", i))
+				require.NoError(t, err)
+			}
+
+			var got []store.Source
+			var cursor string
+			for {
+				sources, next, err := m.GetSources(ctx, "some-user-id", cursor)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(sources), 5)
+				got = append(got, sources...)
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+
+			require.Len(t, got, 12)
+			for i, s := range got {
+				assert.Equal(t, fmt.Sprintf("%dgoogle.com", i), s.URL)
+			}
+		})
+	}
+}
+
+func TestMediumSQL_GetSources_Failure(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			m := b.newMedium(t, 5)
+
+			sources, _, err := m.GetSources(ctx, "some-user-id", "not a cursor")
+			assert.True(t, errors.Is(err, store.ErrInvalidCursor), err)
+			assert.Nil(t, sources)
+		})
+	}
+}
+
+func TestMediumSQL_UpdateSource_Success(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			m := b.newMedium(t, 1)
+
+			err := m.AddSource(ctx, "some-user-id", "google.com")
+			require.NoError(t, err)
+
+			sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+			require.NoError(t, err)
+
+			want := sources[0]
+			want.Hit = 2
+			want.Hash = "a09sdj"
+			want.Simhash = 1 << 63
+			want.Multiplier = 1.5
+			want.ModifiedDate = time.Date(2020, 10, 16, 7, 30, 0, 0, time.UTC)
+			want.ETag = `"v1"`
+			want.LastModified = "Wed, 21 Oct 2015 07:28:00 GMT"
+			want.FeedURL = "google.com/feed"
+			want.LatestItemID = "42"
+			want.LatestItemTitle = "Some post"
+			want.LatestItemLink = "google.com/some-post"
+			want.LatestItemDate = time.Date(2020, 10, 15, 9, 0, 0, 0, time.UTC)
+
+			err = m.UpdateSource(ctx, "some-user-id", want)
+			assert.NoError(t, err)
+
+			sources, _, err = m.GetAllSourceData(ctx, "some-user-id", "")
+			require.NoError(t, err)
+
+			assert.Equal(t, want.Hit, sources[0].Hit)
+			assert.Equal(t, want.Hash, sources[0].Hash)
+			assert.Equal(t, want.Simhash, sources[0].Simhash)
+			assert.Equal(t, want.Multiplier, sources[0].Multiplier)
+			assert.True(t, want.ModifiedDate.Equal(sources[0].ModifiedDate))
+			assert.Equal(t, want.ETag, sources[0].ETag)
+			assert.Equal(t, want.LastModified, sources[0].LastModified)
+			assert.Equal(t, want.FeedURL, sources[0].FeedURL)
+			assert.Equal(t, want.LatestItemID, sources[0].LatestItemID)
+			assert.Equal(t, want.LatestItemTitle, sources[0].LatestItemTitle)
+			assert.Equal(t, want.LatestItemLink, sources[0].LatestItemLink)
+			assert.True(t, want.LatestItemDate.Equal(sources[0].LatestItemDate))
+		})
+	}
+}
+
+func TestMediumSQL_UpdateSource_Failure(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			tests := []struct {
+				name   string
+				userID string
+				want   error
+			}{
+				{
+					name:   "user not found",
+					userID: "some-user-id",
+					want:   store.ErrUserNotFound,
+				},
+				{
+					name:   "source not found",
+					userID: "another-user-id",
+					want:   store.ErrCannotFindMedium,
+				},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					ctx := context.Background()
+
+					m := b.newMedium(t, 1)
+
+					err := m.AddSource(ctx, "another-user-id", "google.com")
+					require.NoError(t, err)
+
+					got := m.UpdateSource(ctx, tt.userID, store.Medium{})
+					assert.Equal(t, tt.want, got)
+				})
+			}
+		})
+	}
+}
+
+func TestMediumSQL_DeleteSource(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			m := b.newMedium(t, 1)
+
+			err := m.AddSource(ctx, "some-user-id", "google.com")
+			require.NoError(t, err)
+
+			err = m.DeleteSource(ctx, "some-user-id", "")
+			assert.Equal(t, store.ErrCannotFindMedium, err)
+
+			err = m.DeleteSource(ctx, "another-user-id", "")
+			assert.Equal(t, store.ErrUserNotFound, err)
+
+			sources, _, err := m.GetSources(ctx, "some-user-id", "")
+			require.NoError(t, err)
+
+			err = m.DeleteSource(ctx, "some-user-id", sources[0].ID)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMediumSQL_GetUserIDs(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			m := b.newMedium(t, 1)
+
+			got, err := m.GetUserIDs(ctx)
+			assert.NoError(t, err)
+			assert.Empty(t, got)
+
+			for _, id := range []string{"some-user-id", "another-user-id"} {
+				err = m.AddSource(ctx, id, "google.com")
+				require.NoError(t, err)
+			}
+
+			got, err = m.GetUserIDs(ctx)
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, []string{"some-user-id", "another-user-id"}, got)
+		})
+	}
+}
+
+func TestMediumSQL_GetSources_Cursor(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			m := b.newMedium(t, 2)
+
+			for _, s := range []string{"a.com", "b.com", "c.com", "d.com"} {
+				err := m.AddSource(ctx, "some-user-id", s)
+				require.NoError(t, err)
+			}
+
+			first, next, err := m.GetSources(ctx, "some-user-id", "")
+			require.NoError(t, err)
+			require.NotEmpty(t, next)
+			assert.Equal(t, "a.com", first[0].URL)
+			assert.Equal(t, "b.com", first[1].URL)
+
+			// Deleting a source on the first page doesn't shift the second page
+			err = m.DeleteSource(ctx, "some-user-id", first[0].ID)
+			require.NoError(t, err)
+
+			second, next, err := m.GetSources(ctx, "some-user-id", next)
+			require.NoError(t, err)
+			assert.Empty(t, next)
+			require.Len(t, second, 2)
+			assert.Equal(t, "c.com", second[0].URL)
+			assert.Equal(t, "d.com", second[1].URL)
+
+			_, _, err = m.GetSources(ctx, "some-user-id", "not a cursor")
+			assert.True(t, errors.Is(err, store.ErrInvalidCursor))
+		})
+	}
+}
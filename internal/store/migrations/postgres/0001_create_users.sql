@@ -0,0 +1,9 @@
+-- user_id is text rather than uuid so that looking up a malformed
+-- id is a miss rather than an error
+CREATE TABLE users (
+    user_id       TEXT        PRIMARY KEY,
+    email         TEXT        NOT NULL,
+    created_date  TIMESTAMPTZ NOT NULL,
+    modified_date TIMESTAMPTZ NOT NULL,
+    CONSTRAINT users_email_key UNIQUE (email)
+);
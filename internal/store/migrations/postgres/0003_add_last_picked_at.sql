@@ -0,0 +1,6 @@
+-- Sources are picked in proportion to their multiplier, which every
+-- source was added with as 0
+UPDATE medium_sources SET multiplier = 1 WHERE multiplier = 0;
+ALTER TABLE medium_sources ALTER COLUMN multiplier SET DEFAULT 1;
+
+ALTER TABLE medium_sources ADD COLUMN last_picked_at TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00';
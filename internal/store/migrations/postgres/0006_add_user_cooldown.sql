@@ -0,0 +1,2 @@
+-- How long a source isn't picked again for, in nanoseconds
+ALTER TABLE users ADD COLUMN pick_cooldown BIGINT NOT NULL DEFAULT 0;
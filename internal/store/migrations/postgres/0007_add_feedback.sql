@@ -0,0 +1,6 @@
+-- The feedback that the user has given on each source
+ALTER TABLE medium_sources ADD COLUMN reads INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE medium_sources ADD COLUMN skips INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE medium_sources ADD COLUMN loves INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE medium_sources ADD COLUMN dislikes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE medium_sources ADD COLUMN last_feedback_at TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00';
@@ -0,0 +1,6 @@
+-- Sources are picked in proportion to their multiplier, which every
+-- source was added with as 0. 0003 already did this, but a database
+-- could have been migrated by a build whose 0003 didn't, so it's done
+-- again, which changes nothing where it had been.
+UPDATE medium_sources SET multiplier = 1 WHERE multiplier = 0;
+ALTER TABLE medium_sources ALTER COLUMN multiplier SET DEFAULT 1;
@@ -0,0 +1,6 @@
+CREATE TABLE users (
+    user_id       TEXT      PRIMARY KEY,
+    email         TEXT      NOT NULL UNIQUE,
+    created_date  TIMESTAMP NOT NULL,
+    modified_date TIMESTAMP NOT NULL
+);
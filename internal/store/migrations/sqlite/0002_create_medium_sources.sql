@@ -0,0 +1,22 @@
+-- simhash holds the bits of the uint64 in a signed INTEGER
+CREATE TABLE medium_sources (
+    id                TEXT      PRIMARY KEY,
+    user_id           TEXT      NOT NULL,
+    url               TEXT      NOT NULL,
+    hash              TEXT      NOT NULL DEFAULT '',
+    simhash           INTEGER   NOT NULL DEFAULT 0,
+    multiplier        REAL      NOT NULL DEFAULT 0,
+    created_date      TIMESTAMP NOT NULL,
+    modified_date     TIMESTAMP NOT NULL,
+    hit               INTEGER   NOT NULL DEFAULT 0,
+    etag              TEXT      NOT NULL DEFAULT '',
+    last_modified     TEXT      NOT NULL DEFAULT '',
+    feed_url          TEXT      NOT NULL DEFAULT '',
+    latest_item_id    TEXT      NOT NULL DEFAULT '',
+    latest_item_title TEXT      NOT NULL DEFAULT '',
+    latest_item_link  TEXT      NOT NULL DEFAULT '',
+    latest_item_date  TIMESTAMP NOT NULL,
+    UNIQUE (user_id, url)
+);
+
+CREATE INDEX medium_sources_user_id_created_date_idx ON medium_sources (user_id, created_date, id);
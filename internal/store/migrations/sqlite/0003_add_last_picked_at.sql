@@ -0,0 +1,6 @@
+-- Sources are picked in proportion to their multiplier, which every
+-- source was added with as 0. SQLite can't change the default of a
+-- column, so AddSource sets it.
+UPDATE medium_sources SET multiplier = 1 WHERE multiplier = 0;
+
+ALTER TABLE medium_sources ADD COLUMN last_picked_at TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00+00:00';
@@ -0,0 +1,2 @@
+-- The settings of a user, empty is the server's default
+ALTER TABLE users ADD COLUMN pick_strategy TEXT NOT NULL DEFAULT '';
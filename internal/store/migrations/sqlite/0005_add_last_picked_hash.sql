@@ -0,0 +1,3 @@
+-- The hash of the site when it was last picked, so that a pick can tell
+-- whether it has changed since the user last read it
+ALTER TABLE medium_sources ADD COLUMN last_picked_hash TEXT NOT NULL DEFAULT '';
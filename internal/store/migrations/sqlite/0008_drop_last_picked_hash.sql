@@ -0,0 +1,3 @@
+-- Whether a site has changed since it was last picked is told from when
+-- it last changed meaningfully, as the hash changes with every small edit
+ALTER TABLE medium_sources DROP COLUMN last_picked_hash;
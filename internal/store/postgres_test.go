@@ -0,0 +1,153 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/logging"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+// postgresDSNEnv can be set to run the Postgres tests against an
+// existing server, e.g. postgres://postgres@localhost:5432/postgres?sslmode=disable
+const postgresDSNEnv = "PICKER_TEST_POSTGRES_DSN"
+
+var pg struct {
+	once sync.Once
+	dsn  string
+	stop func()
+	err  error
+}
+
+func TestMain(m *testing.M) {
+	_, _ = logging.TestContext(context.Background())
+
+	code := m.Run()
+	if pg.stop != nil {
+		pg.stop()
+	}
+	os.Exit(code)
+}
+
+// postgresDB returns a connection to a new, empty and migrated database.
+// The server is the one in PICKER_TEST_POSTGRES_DSN, or a throwaway
+// cluster started with initdb and pg_ctl. The test is skipped when
+// neither is available.
+func postgresDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	pg.once.Do(startPostgres)
+	if pg.err != nil {
+		t.Skipf("postgres isn't available: %v", pg.err)
+	}
+
+	ctx := context.Background()
+
+	admin, err := sql.Open("postgres", pg.dsn)
+	require.NoError(t, err)
+	defer admin.Close()
+
+	name := "picker_test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	_, err = admin.ExecContext(ctx, "CREATE DATABASE "+name)
+	require.NoError(t, err)
+
+	u, err := url.Parse(pg.dsn)
+	require.NoError(t, err)
+	u.Path = "/" + name
+
+	db, err := store.OpenPostgres(ctx, u.String())
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = db.Close()
+
+		admin, err := sql.Open("postgres", pg.dsn)
+		if err != nil {
+			return
+		}
+		defer admin.Close()
+		_, _ = admin.ExecContext(context.Background(), "DROP DATABASE IF EXISTS "+name)
+	})
+
+	return db
+}
+
+func startPostgres() {
+	if dsn := os.Getenv(postgresDSNEnv); dsn != "" {
+		pg.dsn = dsn
+		return
+	}
+
+	initdb, err := exec.LookPath("initdb")
+	if err != nil {
+		pg.err = fmt.Errorf("set %s or add initdb to the PATH: %w", postgresDSNEnv, err)
+		return
+	}
+	pgCtl, err := exec.LookPath("pg_ctl")
+	if err != nil {
+		pg.err = fmt.Errorf("set %s or add pg_ctl to the PATH: %w", postgresDSNEnv, err)
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "picker-postgres")
+	if err != nil {
+		pg.err = err
+		return
+	}
+	data := filepath.Join(dir, "data")
+
+	if out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "--auth=trust", "-E", "UTF8").CombinedOutput(); err != nil {
+		_ = os.RemoveAll(dir)
+		pg.err = fmt.Errorf("initdb failed: %w: %s", err, out)
+		return
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		pg.err = err
+		return
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	opts := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1", port, dir)
+	if out, err := exec.Command(pgCtl, "-D", data, "-o", opts, "-w", "start").CombinedOutput(); err != nil {
+		_ = os.RemoveAll(dir)
+		pg.err = fmt.Errorf("pg_ctl start failed: %w: %s", err, out)
+		return
+	}
+
+	pg.dsn = fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+	pg.stop = func() {
+		_ = exec.Command(pgCtl, "-D", data, "-m", "immediate", "stop").Run()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestOpenPostgres_Migrated(t *testing.T) {
+	db := postgresDB(t)
+
+	var versions int
+	err := db.QueryRow(`SELECT count(*) FROM schema_migrations`).Scan(&versions)
+	require.NoError(t, err)
+	require.NotZero(t, versions)
+}
+
+func TestOpenPostgres_Failure(t *testing.T) {
+	_, err := store.OpenPostgres(context.Background(), "postgres://postgres@127.0.0.1:1/postgres?sslmode=disable&connect_timeout=1")
+	require.True(t, errors.Is(err, store.ErrCannotOpenPostgres))
+}
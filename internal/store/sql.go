@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/ankur22/medium-picker/internal/err"
+)
+
+const ErrCannotMigrate = err.Const("cannot migrate database")
+
+// migrate applies the .sql files in dir, in name order, that haven't
+// been applied to db yet. Each file is applied in its own transaction
+// along with the record that it has been applied.
+func migrate(ctx context.Context, db *sql.DB, migrations fs.FS, dir string) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT      PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return ErrCannotMigrate.Wrap(err)
+	}
+
+	names, err := fs.Glob(migrations, path.Join(dir, "*.sql"))
+	if err != nil {
+		return ErrCannotMigrate.Wrap(err)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version := path.Base(name)
+
+		var applied bool
+		err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&applied)
+		if err != nil {
+			return ErrCannotMigrate.Wrap(err)
+		}
+		if applied {
+			continue
+		}
+
+		stmt, err := fs.ReadFile(migrations, name)
+		if err != nil {
+			return ErrCannotMigrate.Wrap(err)
+		}
+
+		if err := applyMigration(ctx, db, version, string(stmt)); err != nil {
+			return ErrCannotMigrate.Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, version, stmt string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)`, version, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// dialect is what differs between the databases that
+// userSQL and mediumSQL can be used with
+type dialect struct {
+	// errQuery wraps any error from the database
+	errQuery err.Const
+	// isUniqueViolation checks whether the statement failed
+	// because of a unique or primary key constraint
+	isUniqueViolation func(error) bool
+	// forUpdate is appended to a SELECT in a transaction to lock the
+	// rows until it ends. It's empty when the transaction already
+	// locks the database, like SQLite's immediate transactions.
+	forUpdate string
+}
+
+// execer is what *sql.DB and *sql.Tx share to run statements
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// dumpSQL reads every user and source in a single read only
+// transaction, so that both are from the same point in time
+func dumpSQL(ctx context.Context, db *sql.DB, d dialect) (*Dump, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, d.errQuery.Wrap(err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	dump := &Dump{Users: []User{}, Sources: []Medium{}}
+
+	rows, err := tx.QueryContext(ctx, `SELECT user_id, email, pick_strategy, pick_cooldown FROM users ORDER BY user_id`)
+	if err != nil {
+		return nil, d.errQuery.Wrap(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u User
+		var cooldown int64
+		if err := rows.Scan(&u.ID, &u.Email, &u.Settings.Strategy, &cooldown); err != nil {
+			return nil, d.errQuery.Wrap(err)
+		}
+		u.Settings.Cooldown = time.Duration(cooldown)
+		dump.Users = append(dump.Users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, d.errQuery.Wrap(err)
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT `+mediumColumns+` FROM medium_sources ORDER BY user_id, created_date, id`)
+	if err != nil {
+		return nil, d.errQuery.Wrap(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		m, err := scanMedium(rows)
+		if err != nil {
+			return nil, d.errQuery.Wrap(err)
+		}
+		dump.Sources = append(dump.Sources, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, d.errQuery.Wrap(err)
+	}
+
+	return dump, nil
+}
@@ -0,0 +1,186 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/store"
+	"github.com/ankur22/medium-picker/internal/store/storetest"
+)
+
+type sqlUserStore interface {
+	CreateNewUser(ctx context.Context, email string) (string, error)
+	GetUser(ctx context.Context, email string) (string, error)
+	IsUser(ctx context.Context, userID string) (bool, error)
+}
+
+type sqlMediumStore interface {
+	AddSource(ctx context.Context, userID string, source string) error
+	GetUserIDs(ctx context.Context) ([]string, error)
+	GetSources(ctx context.Context, userID string, cursor string) ([]store.Source, string, error)
+	GetAllSourceData(ctx context.Context, userID string, cursor string) ([]store.Medium, string, error)
+	UpdateSource(ctx context.Context, userID string, source store.Medium) error
+	DeleteSource(ctx context.Context, userID string, sourceID string) error
+}
+
+// sqlBackends are the SQL backed stores that share the same tests
+var sqlBackends = []struct {
+	name      string
+	newUser   func(t *testing.T) sqlUserStore
+	newMedium func(t *testing.T, elemsInPage int) sqlMediumStore
+}{
+	{
+		name: "postgres",
+		newUser: func(t *testing.T) sqlUserStore {
+			return store.NewUserPostgres(postgresDB(t))
+		},
+		newMedium: func(t *testing.T, elemsInPage int) sqlMediumStore {
+			return store.NewMediumPostgres(postgresDB(t), elemsInPage)
+		},
+	},
+	{
+		name: "sqlite",
+		newUser: func(t *testing.T) sqlUserStore {
+			return store.NewUserSQLite(sqliteDB(t))
+		},
+		newMedium: func(t *testing.T, elemsInPage int) sqlMediumStore {
+			return store.NewMediumSQLite(sqliteDB(t), elemsInPage)
+		},
+	},
+}
+
+// sqliteDB returns a connection to a new, empty and migrated database
+// in the test's temp dir
+func sqliteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	return openSQLite(t, filepath.Join(t.TempDir(), "picker.db"))
+}
+
+// openSQLite opens the database, which is closed when the test ends
+func openSQLite(t *testing.T, filename string) *sql.DB {
+	t.Helper()
+
+	db, err := store.OpenSQLite(context.Background(), filename)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return db
+}
+
+func TestOpenSQLite_Migrated(t *testing.T) {
+	db := sqliteDB(t)
+
+	var versions int
+	err := db.QueryRow(`SELECT count(*) FROM schema_migrations`).Scan(&versions)
+	require.NoError(t, err)
+	require.NotZero(t, versions)
+
+	var mode string
+	err = db.QueryRow(`PRAGMA journal_mode`).Scan(&mode)
+	require.NoError(t, err)
+	require.Equal(t, "wal", mode)
+}
+
+func TestOpenSQLite_Reopen(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "picker.db")
+
+	db, err := store.OpenSQLite(ctx, filename)
+	require.NoError(t, err)
+
+	uid, err := store.NewUserSQLite(db).CreateNewUser(ctx, "test@example.com")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db, err = store.OpenSQLite(ctx, filename)
+	require.NoError(t, err)
+	defer db.Close()
+
+	got, err := store.NewUserSQLite(db).GetUser(ctx, "test@example.com")
+	require.NoError(t, err)
+	require.Equal(t, uid, got)
+}
+
+func TestOpenSQLite_TimesRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	m := store.NewMediumSQLite(sqliteDB(t), 1)
+
+	err := m.AddSource(ctx, "some-user-id", "google.com")
+	require.NoError(t, err)
+
+	sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+	require.NoError(t, err)
+	require.Len(t, sources, 1)
+	require.WithinDuration(t, time.Now(), sources[0].CreatedDate, time.Minute)
+}
+
+func TestOpenSQLite_UpgradesSources(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "picker.db")
+
+	// A source from before 0003, which was added with a multiplier of 0
+	db, err := store.OpenSQLite(ctx, filename)
+	require.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM schema_migrations WHERE version IN ('0003_add_last_picked_at.sql', '0009_default_multiplier.sql')`)
+	require.NoError(t, err)
+	_, err = db.Exec(`ALTER TABLE medium_sources DROP COLUMN last_picked_at`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO medium_sources (id, user_id, url, created_date, modified_date, latest_item_date)
+		VALUES ('some-id', 'some-user-id', 'google.com', $1, $1, $1)`, time.Now().UTC())
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	m := store.NewMediumSQLite(openSQLite(t, filename), 1)
+
+	sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+	require.NoError(t, err)
+	require.Len(t, sources, 1)
+	require.Equal(t, float32(1), sources[0].Multiplier)
+	require.True(t, sources[0].LastPickedAt.IsZero(), sources[0].LastPickedAt)
+}
+
+func TestUserPostgres_Conformance(t *testing.T) {
+	storetest.RunUserStorerSuite(t, func(t *testing.T) func() storetest.UserStorer {
+		db := postgresDB(t)
+		return func() storetest.UserStorer {
+			return store.NewUserPostgres(db)
+		}
+	})
+}
+
+func TestMediumPostgres_Conformance(t *testing.T) {
+	storetest.RunMediumSourceStorerSuite(t, func(t *testing.T, elemsInPage int) func() storetest.MediumSourceStorer {
+		db := postgresDB(t)
+		return func() storetest.MediumSourceStorer {
+			return store.NewMediumPostgres(db, elemsInPage)
+		}
+	})
+}
+
+func TestUserSQLite_Conformance(t *testing.T) {
+	storetest.RunUserStorerSuite(t, func(t *testing.T) func() storetest.UserStorer {
+		filename := filepath.Join(t.TempDir(), "picker.db")
+		return func() storetest.UserStorer {
+			return store.NewUserSQLite(openSQLite(t, filename))
+		}
+	})
+}
+
+func TestMediumSQLite_Conformance(t *testing.T) {
+	storetest.RunMediumSourceStorerSuite(t, func(t *testing.T, elemsInPage int) func() storetest.MediumSourceStorer {
+		filename := filepath.Join(t.TempDir(), "picker.db")
+		return func() storetest.MediumSourceStorer {
+			return store.NewMediumSQLite(openSQLite(t, filename), elemsInPage)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"net/url"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/ankur22/medium-picker/internal/err"
+)
+
+const (
+	ErrCannotOpenSQLite  = err.Const("cannot open sqlite")
+	ErrCannotQuerySQLite = err.Const("cannot query sqlite")
+)
+
+func init() {
+	Register("sqlite", openSQLiteBackend)
+}
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// OpenSQLite opens, or creates, the database file in WAL mode and brings
+// its schema up to date. The connection can be shared between UserSQLite
+// and MediumSQLite.
+func OpenSQLite(ctx context.Context, filename string) (*sql.DB, error) {
+	q := url.Values{}
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Add("_pragma", "synchronous(NORMAL)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+filename+"?"+q.Encode())
+	if err != nil {
+		return nil, ErrCannotOpenSQLite.Wrap(err)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, ErrCannotOpenSQLite.Wrap(err)
+	}
+
+	if err := migrate(ctx, db, sqliteMigrations, "migrations/sqlite"); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+var sqliteDialect = dialect{
+	errQuery: ErrCannotQuerySQLite,
+	isUniqueViolation: func(e error) bool {
+		var sqliteErr *sqlite.Error
+		if !errors.As(e, &sqliteErr) {
+			return false
+		}
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	},
+}
+
+// UserSQLite is the type that will store the user information in SQLite
+type UserSQLite struct {
+	userSQL
+}
+
+// NewUserSQLite will create a new instance of UserSQLite
+// db should be opened with OpenSQLite so that the schema is up to date
+func NewUserSQLite(db *sql.DB) *UserSQLite {
+	return &UserSQLite{userSQL{db: db, dialect: sqliteDialect}}
+}
+
+// MediumSQLite is the type that will store the medium information in SQLite
+type MediumSQLite struct {
+	mediumSQL
+}
+
+// NewMediumSQLite will create a new instance of MediumSQLite
+// db should be opened with OpenSQLite so that the schema is up to date
+func NewMediumSQLite(db *sql.DB, elemsInPage int) *MediumSQLite {
+	return &MediumSQLite{mediumSQL{db: db, dialect: sqliteDialect, elemsInPage: elemsInPage}}
+}
+
+// openSQLiteBackend keeps the users and sources in a database
+// file, e.g. sqlite:///data/picker.db
+func openSQLiteBackend(ctx context.Context, dsn *url.URL, opts Options) (*Backend, error) {
+	filename, err := dsnPath(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := OpenSQLite(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	users := NewUserSQLite(db)
+	medium := NewMediumSQLite(db, opts.ElemsInPage)
+
+	return newBackend(users, medium, backendFuncs{
+		close: func(context.Context) error {
+			return db.Close()
+		},
+		dump: func(ctx context.Context) (*Dump, error) {
+			return dumpSQL(ctx, db, sqliteDialect)
+		},
+		check: func(ctx context.Context, repair bool) ([]Problem, error) {
+			return checkSQL(ctx, &users.userSQL, &medium.mediumSQL, repair)
+		},
+	}), nil
+}
@@ -0,0 +1,629 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+// RunMediumSourceStorerSuite checks that the stores made by newStore
+// behave like every other medium store
+func RunMediumSourceStorerSuite(t *testing.T, newStore MediumSourceStorerFactory) {
+	t.Run("AddSource", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		err := m.AddSource(ctx, "some-user-id", "google.com")
+		require.NoError(t, err)
+
+		sources, next, err := m.GetAllSourceData(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		assert.Empty(t, next)
+		require.Len(t, sources, 1)
+
+		got := sources[0]
+		assert.NotEmpty(t, got.ID)
+		assert.Equal(t, "google.com", got.URL)
+		assert.Equal(t, "some-user-id", got.UserID)
+		assert.Empty(t, got.Hash)
+		assert.Zero(t, got.Hit)
+		assert.Equal(t, float32(1), got.Multiplier)
+		assert.True(t, got.LastPickedAt.IsZero())
+		assert.Zero(t, got.Reads+got.Skips+got.Loves+got.Dislikes)
+		assert.True(t, got.LastFeedbackAt.IsZero())
+		assert.WithinDuration(t, time.Now(), got.CreatedDate, time.Minute)
+		assert.WithinDuration(t, time.Now(), got.ModifiedDate, time.Minute)
+	})
+
+	t.Run("AddSource already exists", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		err := m.AddSource(ctx, "some-user-id", "google.com")
+		require.NoError(t, err)
+
+		err = m.AddSource(ctx, "some-user-id", "google.com")
+		assert.True(t, errors.Is(err, store.ErrMediumSourceAlreadyExists), err)
+
+		// Sources are per user
+		err = m.AddSource(ctx, "another-user-id", "google.com")
+		assert.NoError(t, err)
+	})
+
+	t.Run("GetUserIDs", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		ids, err := m.GetUserIDs(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, ids)
+
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "google.com"))
+		require.NoError(t, m.AddSource(ctx, "another-user-id", "google.com"))
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "bing.com"))
+
+		ids, err = m.GetUserIDs(ctx)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"some-user-id", "another-user-id"}, ids)
+	})
+
+	t.Run("GetSources user without sources", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		sources, next, err := m.GetSources(ctx, "some-user-id", "")
+		assert.NoError(t, err)
+		assert.Empty(t, sources)
+		assert.Empty(t, next)
+
+		all, next, err := m.GetAllSourceData(ctx, "some-user-id", "")
+		assert.NoError(t, err)
+		assert.Empty(t, all)
+		assert.Empty(t, next)
+	})
+
+	t.Run("GetSources invalid cursor", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		err := m.AddSource(ctx, "some-user-id", "google.com")
+		require.NoError(t, err)
+
+		_, _, err = m.GetSources(ctx, "some-user-id", "not a cursor")
+		assert.True(t, errors.Is(err, store.ErrInvalidCursor), err)
+
+		_, _, err = m.GetAllSourceData(ctx, "some-user-id", "not a cursor")
+		assert.True(t, errors.Is(err, store.ErrInvalidCursor), err)
+	})
+
+	pages := []struct {
+		name        string
+		elemsInPage int
+		count       int
+		wantPages   int
+	}{
+		{name: "one source", elemsInPage: 1, count: 1, wantPages: 1},
+		{name: "less than a page", elemsInPage: 5, count: 3, wantPages: 1},
+		{name: "exactly one page", elemsInPage: 5, count: 5, wantPages: 1},
+		{name: "one more than a page", elemsInPage: 5, count: 6, wantPages: 2},
+		{name: "exact number of pages", elemsInPage: 5, count: 15, wantPages: 3},
+		{name: "partial last page", elemsInPage: 5, count: 12, wantPages: 3},
+	}
+	for _, tt := range pages {
+		t.Run("GetSources pages "+tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := newStore(t, tt.elemsInPage)()
+
+			var want []string
+			for i := 0; i < tt.count; i++ {
+				url := fmt.Sprintf("%02dgoogle.com", i)
+				want = append(want, url)
+				require.NoError(t, m.AddSource(ctx, "some-user-id", url))
+			}
+
+			var got []string
+			var cursor string
+			var pages int
+			for {
+				sources, next, err := m.GetSources(ctx, "some-user-id", cursor)
+				require.NoError(t, err)
+				require.LessOrEqual(t, len(sources), tt.elemsInPage)
+				require.NotEmpty(t, sources, "a page with a cursor is never empty")
+				pages++
+
+				for _, s := range sources {
+					got = append(got, s.URL)
+				}
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+
+			assert.Equal(t, want, got)
+			assert.Equal(t, tt.wantPages, pages)
+
+			// GetAllSourceData pages the same way
+			var gotAll []string
+			cursor = ""
+			for {
+				sources, next, err := m.GetAllSourceData(ctx, "some-user-id", cursor)
+				require.NoError(t, err)
+				for _, s := range sources {
+					gotAll = append(gotAll, s.URL)
+				}
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+			assert.Equal(t, want, gotAll)
+		})
+	}
+
+	t.Run("GetSources cursor is stable", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 2)()
+
+		for _, s := range []string{"a.com", "b.com", "c.com", "d.com", "e.com"} {
+			require.NoError(t, m.AddSource(ctx, "some-user-id", s))
+		}
+
+		first, next, err := m.GetSources(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		require.Len(t, first, 2)
+
+		// Neither deleting a source from an earlier page nor adding a
+		// new one changes what comes after the cursor
+		require.NoError(t, m.DeleteSource(ctx, "some-user-id", first[0].ID))
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "f.com"))
+
+		var got []string
+		for next != "" {
+			var sources []store.Source
+			sources, next, err = m.GetSources(ctx, "some-user-id", next)
+			require.NoError(t, err)
+			for _, s := range sources {
+				got = append(got, s.URL)
+			}
+		}
+
+		assert.Equal(t, []string{"c.com", "d.com", "e.com", "f.com"}, got)
+	})
+
+	t.Run("UpdateSource", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "google.com"))
+
+		sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+		require.NoError(t, err)
+
+		want := updated(sources[0])
+		require.NoError(t, m.UpdateSource(ctx, "some-user-id", want))
+
+		sources, _, err = m.GetAllSourceData(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		require.Len(t, sources, 1)
+		assertMedium(t, want, sources[0])
+	})
+
+	t.Run("GetSource", func(t *testing.T) {
+		ctx := context.Background()
+		open := newStore(t, 10)
+		m := open()
+
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "google.com"))
+		require.NoError(t, m.AddSource(ctx, "another-user-id", "google.com"))
+
+		sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		require.Len(t, sources, 1)
+
+		got, err := m.GetSource(ctx, "some-user-id", sources[0].ID)
+		require.NoError(t, err)
+		assertMedium(t, sources[0], got)
+
+		// The source belongs to another user
+		_, err = m.GetSource(ctx, "another-user-id", sources[0].ID)
+		assert.True(t, errors.Is(err, store.ErrCannotFindMedium), err)
+
+		_, err = m.GetSource(ctx, "unknown-user-id", sources[0].ID)
+		assert.True(t, errors.Is(err, store.ErrUserNotFound), err)
+
+		want := updated(sources[0])
+		require.NoError(t, m.UpdateSource(ctx, "some-user-id", want))
+		got, err = m.GetSource(ctx, "some-user-id", want.ID)
+		require.NoError(t, err)
+		assertMedium(t, want, got)
+
+		// The index is rebuilt when the store is opened again
+		closeStore(t, m)
+		m = open()
+		got, err = m.GetSource(ctx, "some-user-id", want.ID)
+		require.NoError(t, err)
+		assertMedium(t, want, got)
+
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "bing.com"))
+		require.NoError(t, m.DeleteSource(ctx, "some-user-id", want.ID))
+		_, err = m.GetSource(ctx, "some-user-id", want.ID)
+		assert.True(t, errors.Is(err, store.ErrCannotFindMedium), err)
+	})
+
+	t.Run("UpdateSource not found", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "google.com"))
+
+		err := m.UpdateSource(ctx, "another-user-id", store.Medium{ID: "some-id"})
+		assert.True(t, errors.Is(err, store.ErrUserNotFound), err)
+
+		err = m.UpdateSource(ctx, "some-user-id", store.Medium{ID: "some-id"})
+		assert.True(t, errors.Is(err, store.ErrCannotFindMedium), err)
+	})
+
+	t.Run("ModifySource", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "google.com"))
+
+		sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		require.Len(t, sources, 1)
+
+		want := updated(sources[0])
+		err = m.ModifySource(ctx, "some-user-id", want.ID, func(source *store.Medium) error {
+			assertMedium(t, sources[0], *source)
+			*source = want
+			// The id, user and url can't be changed
+			source.ID = "another-id"
+			source.UserID = "another-user-id"
+			source.URL = "bing.com"
+			return nil
+		})
+		require.NoError(t, err)
+
+		got, err := m.GetSource(ctx, "some-user-id", want.ID)
+		require.NoError(t, err)
+		assertMedium(t, want, got)
+
+		// Nothing is written when fn fails
+		fail := errors.New("some error")
+		err = m.ModifySource(ctx, "some-user-id", want.ID, func(source *store.Medium) error {
+			source.Hit = 100
+			return fail
+		})
+		assert.True(t, errors.Is(err, fail), err)
+
+		got, err = m.GetSource(ctx, "some-user-id", want.ID)
+		require.NoError(t, err)
+		assert.Equal(t, want.Hit, got.Hit)
+	})
+
+	t.Run("ModifySource not found", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "google.com"))
+
+		fn := func(source *store.Medium) error {
+			t.Error("fn was called for a source that doesn't exist")
+			return nil
+		}
+
+		err := m.ModifySource(ctx, "another-user-id", "some-id", fn)
+		assert.True(t, errors.Is(err, store.ErrUserNotFound), err)
+
+		err = m.ModifySource(ctx, "some-user-id", "some-id", fn)
+		assert.True(t, errors.Is(err, store.ErrCannotFindMedium), err)
+	})
+
+	t.Run("DeleteSource", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "google.com"))
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "bing.com"))
+
+		sources, _, err := m.GetSources(ctx, "some-user-id", "")
+		require.NoError(t, err)
+
+		require.NoError(t, m.DeleteSource(ctx, "some-user-id", sources[0].ID))
+
+		left, _, err := m.GetSources(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		assert.Equal(t, sources[1:], left)
+
+		// The url can be added again once it's deleted
+		assert.NoError(t, m.AddSource(ctx, "some-user-id", sources[0].URL))
+	})
+
+	t.Run("DeleteSource last source", func(t *testing.T) {
+		ctx := context.Background()
+		open := newStore(t, 10)
+		m := open()
+
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "google.com"))
+		require.NoError(t, m.AddSource(ctx, "another-user-id", "bing.com"))
+
+		sources, _, err := m.GetSources(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		require.Len(t, sources, 1)
+		require.NoError(t, m.DeleteSource(ctx, "some-user-id", sources[0].ID))
+
+		// A user whose sources are all deleted has none, like
+		// one that never added any, before and after a reopen
+		check := func(m MediumSourceStorer) {
+			left, next, err := m.GetSources(ctx, "some-user-id", "")
+			assert.NoError(t, err)
+			assert.Empty(t, left)
+			assert.Empty(t, next)
+
+			all, next, err := m.GetAllSourceData(ctx, "some-user-id", "")
+			assert.NoError(t, err)
+			assert.Empty(t, all)
+			assert.Empty(t, next)
+
+			ids, err := m.GetUserIDs(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"another-user-id"}, ids)
+
+			_, err = m.GetSource(ctx, "some-user-id", sources[0].ID)
+			assert.True(t, errors.Is(err, store.ErrUserNotFound), err)
+		}
+		check(m)
+
+		closeStore(t, m)
+		m = open()
+		defer closeStore(t, m)
+		check(m)
+
+		assert.NoError(t, m.AddSource(ctx, "some-user-id", "google.com"))
+	})
+
+	t.Run("DeleteSource not found", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "google.com"))
+
+		err := m.DeleteSource(ctx, "another-user-id", "some-id")
+		assert.True(t, errors.Is(err, store.ErrUserNotFound), err)
+
+		err = m.DeleteSource(ctx, "some-user-id", "some-id")
+		assert.True(t, errors.Is(err, store.ErrCannotFindMedium), err)
+	})
+
+	t.Run("ImportSource", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		want := updated(store.Medium{
+			ID:          "some-id",
+			URL:         "google.com",
+			UserID:      "some-user-id",
+			CreatedDate: time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC),
+		})
+		require.NoError(t, m.ImportSource(ctx, want))
+
+		// Importing the same source again is harmless
+		require.NoError(t, m.ImportSource(ctx, want))
+
+		sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		require.Len(t, sources, 1)
+		assertMedium(t, want, sources[0])
+
+		// Another source can't take the url
+		err = m.ImportSource(ctx, store.Medium{ID: "another-id", URL: "google.com", UserID: "some-user-id"})
+		assert.True(t, errors.Is(err, store.ErrMediumSourceAlreadyExists), err)
+
+		// Imported sources are paginated in the order they were created
+		// rather than the order they were imported in
+		older := store.Medium{
+			ID:          "older-id",
+			URL:         "bing.com",
+			UserID:      "some-user-id",
+			CreatedDate: time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC),
+		}
+		require.NoError(t, m.ImportSource(ctx, older))
+
+		sources, _, err = m.GetAllSourceData(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		require.Len(t, sources, 2)
+		assert.Equal(t, older.ID, sources[0].ID)
+		assert.Equal(t, want.ID, sources[1].ID)
+	})
+
+	t.Run("concurrent AddSource", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 100)()
+
+		const n = 20
+
+		var wg sync.WaitGroup
+		errs := make([]error, 2*n)
+		for i := 0; i < n; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = m.AddSource(ctx, "some-user-id", fmt.Sprintf("%d.com", i))
+			}(i)
+			// Everyone adds the same source, only one of them can win
+			go func(i int) {
+				defer wg.Done()
+				errs[n+i] = m.AddSource(ctx, "some-user-id", "google.com")
+			}(i)
+		}
+		wg.Wait()
+
+		var dupes int
+		for _, err := range errs {
+			if errors.Is(err, store.ErrMediumSourceAlreadyExists) {
+				dupes++
+				continue
+			}
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, n-1, dupes)
+
+		sources, _, err := m.GetSources(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		assert.Len(t, sources, n+1)
+	})
+
+	t.Run("concurrent UpdateSource", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 100)()
+
+		const n = 10
+		for i := 0; i < n; i++ {
+			require.NoError(t, m.AddSource(ctx, "some-user-id", fmt.Sprintf("%d.com", i)))
+		}
+
+		sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for _, s := range sources {
+			wg.Add(1)
+			go func(s store.Medium) {
+				defer wg.Done()
+				s.Hit = 5
+				assert.NoError(t, m.UpdateSource(ctx, "some-user-id", s))
+			}(s)
+		}
+		wg.Wait()
+
+		sources, _, err = m.GetAllSourceData(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		for _, s := range sources {
+			assert.Equal(t, 5, s.Hit)
+		}
+	})
+
+	t.Run("concurrent ModifySource", func(t *testing.T) {
+		ctx := context.Background()
+		m := newStore(t, 10)()
+
+		require.NoError(t, m.AddSource(ctx, "some-user-id", "google.com"))
+
+		sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		require.Len(t, sources, 1)
+
+		// Every change is made to the source as it is at the time, so
+		// none of them overwrite each other
+		const n = 20
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, m.ModifySource(ctx, "some-user-id", sources[0].ID, func(source *store.Medium) error {
+					source.Hit++
+					return nil
+				}))
+			}()
+		}
+		wg.Wait()
+
+		got, err := m.GetSource(ctx, "some-user-id", sources[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, n, got.Hit)
+	})
+
+	t.Run("persists across reopen", func(t *testing.T) {
+		ctx := context.Background()
+		open := newStore(t, 10)
+		m := open()
+
+		for _, s := range []string{"google.com", "bing.com", "yahoo.com"} {
+			require.NoError(t, m.AddSource(ctx, "some-user-id", s))
+		}
+
+		sources, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		require.Len(t, sources, 3)
+
+		want := updated(sources[0])
+		require.NoError(t, m.UpdateSource(ctx, "some-user-id", want))
+		require.NoError(t, m.DeleteSource(ctx, "some-user-id", sources[1].ID))
+
+		closeStore(t, m)
+		m = open()
+		defer closeStore(t, m)
+
+		got, _, err := m.GetAllSourceData(ctx, "some-user-id", "")
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assertMedium(t, want, got[0])
+		assert.Equal(t, sources[2].ID, got[1].ID)
+
+		ids, err := m.GetUserIDs(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"some-user-id"}, ids)
+	})
+}
+
+// updated changes every field that a store has to keep. The times are
+// whole seconds so that they survive the precision of every backend.
+func updated(m store.Medium) store.Medium {
+	m.Hit = 2
+	m.Hash = "a09sdj"
+	m.Simhash = 1 << 63
+	m.Multiplier = 1.5
+	m.ModifiedDate = time.Date(2020, 10, 16, 7, 30, 0, 0, time.UTC)
+	m.ETag = `"v1"`
+	m.LastModified = "Wed, 21 Oct 2015 07:28:00 GMT"
+	m.FeedURL = m.URL + "/feed"
+	m.LatestItemID = "42"
+	m.LatestItemTitle = "Some post"
+	m.LatestItemLink = m.URL + "/some-post"
+	m.LatestItemDate = time.Date(2020, 10, 15, 9, 0, 0, 0, time.UTC)
+	m.LastPickedAt = time.Date(2020, 10, 16, 8, 0, 0, 0, time.UTC)
+	m.Reads = 3
+	m.Skips = 4
+	m.Loves = 1
+	m.Dislikes = 2
+	m.LastFeedbackAt = time.Date(2020, 10, 16, 9, 0, 0, 0, time.UTC)
+	return m
+}
+
+func assertMedium(t *testing.T, want, got store.Medium) {
+	t.Helper()
+
+	assert.Equal(t, want.ID, got.ID)
+	assert.Equal(t, want.URL, got.URL)
+	assert.Equal(t, want.UserID, got.UserID)
+	assert.Equal(t, want.Hit, got.Hit)
+	assert.Equal(t, want.Hash, got.Hash)
+	assert.Equal(t, want.Simhash, got.Simhash)
+	assert.Equal(t, want.Multiplier, got.Multiplier)
+	assert.True(t, want.CreatedDate.Equal(got.CreatedDate), "created date %v != %v", want.CreatedDate, got.CreatedDate)
+	assert.True(t, want.ModifiedDate.Equal(got.ModifiedDate), "modified date %v != %v", want.ModifiedDate, got.ModifiedDate)
+	assert.Equal(t, want.ETag, got.ETag)
+	assert.Equal(t, want.LastModified, got.LastModified)
+	assert.Equal(t, want.FeedURL, got.FeedURL)
+	assert.Equal(t, want.LatestItemID, got.LatestItemID)
+	assert.Equal(t, want.LatestItemTitle, got.LatestItemTitle)
+	assert.Equal(t, want.LatestItemLink, got.LatestItemLink)
+	assert.True(t, want.LatestItemDate.Equal(got.LatestItemDate), "latest item date %v != %v", want.LatestItemDate, got.LatestItemDate)
+	assert.True(t, want.LastPickedAt.Equal(got.LastPickedAt), "last picked at %v != %v", want.LastPickedAt, got.LastPickedAt)
+	assert.Equal(t, want.Reads, got.Reads)
+	assert.Equal(t, want.Skips, got.Skips)
+	assert.Equal(t, want.Loves, got.Loves)
+	assert.Equal(t, want.Dislikes, got.Dislikes)
+	assert.True(t, want.LastFeedbackAt.Equal(got.LastFeedbackAt), "last feedback at %v != %v", want.LastFeedbackAt, got.LastFeedbackAt)
+}
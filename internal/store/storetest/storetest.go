@@ -0,0 +1,55 @@
+// Package storetest is a conformance suite for the store backends. Every
+// backend has to pass it so that the REST handlers, the crawler and the
+// picker behave the same no matter where the data is kept.
+package storetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/crawler"
+	"github.com/ankur22/medium-picker/internal/rest"
+	"github.com/ankur22/medium-picker/internal/service"
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+// UserStorer is everything that a user store is used for
+type UserStorer interface {
+	rest.UserStorer
+	store.UserStorer
+}
+
+// MediumSourceStorer is everything that a medium store is used for
+type MediumSourceStorer interface {
+	rest.MediumSourceStorer
+	crawler.MediumSourceStorer
+	service.MediumSourceStorer
+	store.MediumStorer
+}
+
+// Closer is implemented by the stores that have to be closed before
+// they are opened again, e.g. to release a file
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// UserStorerFactory creates new, empty, storage for a test and returns
+// a func that opens a store on it. Every store that the func opens has
+// to share the storage, so that the suite can check that what's written
+// survives the store being closed and opened again.
+type UserStorerFactory func(t *testing.T) func() UserStorer
+
+// MediumSourceStorerFactory is the same as UserStorerFactory, but the
+// stores have to return elemsInPage sources per page
+type MediumSourceStorerFactory func(t *testing.T, elemsInPage int) func() MediumSourceStorer
+
+// closeStore closes s when it needs closing before it's opened again
+func closeStore(t *testing.T, s interface{}) {
+	t.Helper()
+
+	if c, ok := s.(Closer); ok {
+		require.NoError(t, c.Close(context.Background()))
+	}
+}
@@ -0,0 +1,257 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+// RunUserStorerSuite checks that the stores made by newStore
+// behave like every other user store
+func RunUserStorerSuite(t *testing.T, newStore UserStorerFactory) {
+	t.Run("CreateNewUser", func(t *testing.T) {
+		ctx := context.Background()
+		u := newStore(t)()
+
+		uid, err := u.CreateNewUser(ctx, "test@example.com")
+		require.NoError(t, err)
+		assert.NotEmpty(t, uid)
+
+		another, err := u.CreateNewUser(ctx, "another@example.com")
+		require.NoError(t, err)
+		assert.NotEqual(t, uid, another)
+	})
+
+	t.Run("CreateNewUser already exists", func(t *testing.T) {
+		ctx := context.Background()
+		u := newStore(t)()
+
+		_, err := u.CreateNewUser(ctx, "test@example.com")
+		require.NoError(t, err)
+
+		uid, err := u.CreateNewUser(ctx, "test@example.com")
+		assert.Empty(t, uid)
+		assert.True(t, errors.Is(err, store.ErrUserAlreadyExists), err)
+	})
+
+	t.Run("GetUser", func(t *testing.T) {
+		ctx := context.Background()
+		u := newStore(t)()
+
+		want, err := u.CreateNewUser(ctx, "test@example.com")
+		require.NoError(t, err)
+
+		got, err := u.GetUser(ctx, "test@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("GetUser not found", func(t *testing.T) {
+		ctx := context.Background()
+		u := newStore(t)()
+
+		uid, err := u.GetUser(ctx, "test@example.com")
+		assert.Empty(t, uid)
+		assert.True(t, errors.Is(err, store.ErrUserNotFound), err)
+	})
+
+	t.Run("IsUser", func(t *testing.T) {
+		ctx := context.Background()
+		u := newStore(t)()
+
+		uid, err := u.CreateNewUser(ctx, "test@example.com")
+		require.NoError(t, err)
+
+		ok, err := u.IsUser(ctx, uid)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = u.IsUser(ctx, "not-a-user-id")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("ListUsers", func(t *testing.T) {
+		ctx := context.Background()
+		u := newStore(t)()
+
+		users, err := u.ListUsers(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, users)
+
+		var want []store.User
+		for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+			id, err := u.CreateNewUser(ctx, email)
+			require.NoError(t, err)
+			want = append(want, store.User{ID: id, Email: email})
+		}
+		sort.Slice(want, func(i, j int) bool {
+			return want[i].ID < want[j].ID
+		})
+
+		users, err = u.ListUsers(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, want, users)
+	})
+
+	t.Run("ImportUser", func(t *testing.T) {
+		ctx := context.Background()
+		u := newStore(t)()
+
+		user := store.User{ID: "some-user-id", Email: "test@example.com"}
+		require.NoError(t, u.ImportUser(ctx, user))
+
+		got, err := u.GetUser(ctx, user.Email)
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID, got)
+
+		// Importing the same user again is harmless
+		assert.NoError(t, u.ImportUser(ctx, user))
+
+		err = u.ImportUser(ctx, store.User{ID: "another-user-id", Email: user.Email})
+		assert.True(t, errors.Is(err, store.ErrUserAlreadyExists), err)
+
+		err = u.ImportUser(ctx, store.User{ID: user.ID, Email: "another@example.com"})
+		assert.True(t, errors.Is(err, store.ErrUserAlreadyExists), err)
+
+		users, err := u.ListUsers(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []store.User{user}, users)
+	})
+
+	t.Run("Settings", func(t *testing.T) {
+		ctx := context.Background()
+		open := newStore(t)
+		u := open()
+
+		uid, err := u.CreateNewUser(ctx, "test@example.com")
+		require.NoError(t, err)
+
+		got, err := u.GetSettings(ctx, uid)
+		assert.NoError(t, err)
+		assert.Equal(t, store.Settings{}, got)
+
+		want := store.Settings{Strategy: "some-strategy", Cooldown: 72 * time.Hour}
+		require.NoError(t, u.UpdateSettings(ctx, uid, want))
+
+		got, err = u.GetSettings(ctx, uid)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+
+		users, err := u.ListUsers(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []store.User{{ID: uid, Email: "test@example.com", Settings: want}}, users)
+
+		_, err = u.GetSettings(ctx, "some-user-id")
+		assert.True(t, errors.Is(err, store.ErrUserNotFound), err)
+		err = u.UpdateSettings(ctx, "some-user-id", want)
+		assert.True(t, errors.Is(err, store.ErrUserNotFound), err)
+
+		// Importing the user again updates its settings
+		require.NoError(t, u.ImportUser(ctx, store.User{ID: uid, Email: "test@example.com"}))
+		got, err = u.GetSettings(ctx, uid)
+		assert.NoError(t, err)
+		assert.Equal(t, store.Settings{}, got)
+
+		require.NoError(t, u.ImportUser(ctx, store.User{ID: "another-user-id", Email: "another@example.com", Settings: want}))
+
+		closeStore(t, u)
+		u = open()
+		defer closeStore(t, u)
+
+		got, err = u.GetSettings(ctx, "another-user-id")
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("concurrent CreateNewUser", func(t *testing.T) {
+		ctx := context.Background()
+		u := newStore(t)()
+
+		const n = 20
+
+		var wg sync.WaitGroup
+		ids := make([]string, n)
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ids[i], errs[i] = u.CreateNewUser(ctx, fmt.Sprintf("%d@example.com", i))
+			}(i)
+		}
+		wg.Wait()
+
+		seen := map[string]bool{}
+		for i := 0; i < n; i++ {
+			require.NoError(t, errs[i])
+			assert.False(t, seen[ids[i]], "duplicate id %s", ids[i])
+			seen[ids[i]] = true
+
+			got, err := u.GetUser(ctx, fmt.Sprintf("%d@example.com", i))
+			assert.NoError(t, err)
+			assert.Equal(t, ids[i], got)
+		}
+	})
+
+	t.Run("concurrent CreateNewUser with the same email", func(t *testing.T) {
+		ctx := context.Background()
+		u := newStore(t)()
+
+		const n = 10
+
+		var wg sync.WaitGroup
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = u.CreateNewUser(ctx, "test@example.com")
+			}(i)
+		}
+		wg.Wait()
+
+		var created int
+		for _, err := range errs {
+			if err == nil {
+				created++
+				continue
+			}
+			assert.True(t, errors.Is(err, store.ErrUserAlreadyExists), err)
+		}
+		assert.Equal(t, 1, created)
+	})
+
+	t.Run("persists across reopen", func(t *testing.T) {
+		ctx := context.Background()
+		open := newStore(t)
+		u := open()
+
+		uid, err := u.CreateNewUser(ctx, "test@example.com")
+		require.NoError(t, err)
+
+		closeStore(t, u)
+		u = open()
+		defer closeStore(t, u)
+
+		got, err := u.GetUser(ctx, "test@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, uid, got)
+
+		ok, err := u.IsUser(ctx, uid)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		_, err = u.CreateNewUser(ctx, "test@example.com")
+		assert.True(t, errors.Is(err, store.ErrUserAlreadyExists), err)
+	})
+}
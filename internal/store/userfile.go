@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -19,6 +20,8 @@ const (
 	ErrCannotOpenUserFile        = err.Const("cannot open user file")
 	ErrCannotReadUserFile        = err.Const("cannot read user file")
 	ErrCannotUnmarshallUserFile  = err.Const("cannot unmarshall user file")
+	ErrCannotMarshallUserFile    = err.Const("cannot marshall user file")
+	ErrCannotWriteUserFile       = err.Const("cannot write user file")
 	ErrUserAlreadyExists         = err.Const("user already exists")
 	ErrUserNotFound              = err.Const("user not found")
 	ErrMediumSourceAlreadyExists = err.Const("medium source already exits")
@@ -30,21 +33,54 @@ type UserFile struct {
 	ticker   time.Duration
 	emails   map[string]string
 	users    map[string]string
-	lock     sync.Mutex
+	// settings are the settings of the users that have changed them
+	settings map[string]Settings
+	journal  *journal
+	// lock guards the maps, the journal and dirty. Reads share it.
+	lock     sync.RWMutex
 	dirty    bool
+	readOnly bool
+	// flock stops other processes from opening the file
+	flock *fileLock
+	// saveLock stops two saves from racing to rename their temp files
+	saveLock  sync.Mutex
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 // NewUserFile will create a new instance of UserFile
-// This is not thread safe
+// This is not thread safe. It returns ErrFileLocked if another
+// process has the file open.
 func NewUserFile(ctx context.Context, filename string, ticker time.Duration) (*UserFile, error) {
+	return openUserFile(ctx, filename, ticker, false)
+}
+
+// OpenUserFileReadOnly opens the users in filename for reading, e.g. by
+// a tool, without being able to change them. Other processes can open
+// the file read only at the same time, but not for writing.
+func OpenUserFileReadOnly(ctx context.Context, filename string) (*UserFile, error) {
+	return openUserFile(ctx, filename, 0, true)
+}
+
+func openUserFile(ctx context.Context, filename string, ticker time.Duration, readOnly bool) (*UserFile, error) {
 	u := UserFile{
 		filename: filename,
 		ticker:   ticker,
 		emails:   make(map[string]string),
 		users:    make(map[string]string),
+		settings: make(map[string]Settings),
+		readOnly: readOnly,
+		done:     make(chan struct{}),
 	}
 
+	l, err := lockFile(filename, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	u.flock = l
+
 	if err := u.load(ctx); err != nil {
+		_ = l.release()
 		return nil, err
 	}
 
@@ -61,18 +97,19 @@ func (u *UserFile) CreateNewUser(ctx context.Context, email string) (string, err
 		return "", ErrUserAlreadyExists
 	}
 
-	u.emails[email] = uuid.New().String()
-	u.users[u.emails[email]] = email
-	u.dirty = true
+	e := userEntry{Op: opCreate, UserID: uuid.New().String(), Email: email}
+	if err := u.write(e); err != nil {
+		return "", err
+	}
 
-	return u.emails[email], nil
+	return e.UserID, nil
 }
 
 // GetUser will retrieve the user details. It will return
 // the uuid of the user.
 func (u *UserFile) GetUser(ctx context.Context, email string) (string, error) {
-	u.lock.Lock()
-	defer u.lock.Unlock()
+	u.lock.RLock()
+	defer u.lock.RUnlock()
 
 	if v, ok := u.emails[email]; ok {
 		return v, nil
@@ -82,8 +119,8 @@ func (u *UserFile) GetUser(ctx context.Context, email string) (string, error) {
 
 // IsUser checks whether the given userID is valid
 func (u *UserFile) IsUser(ctx context.Context, userID string) (bool, error) {
-	u.lock.Lock()
-	defer u.lock.Unlock()
+	u.lock.RLock()
+	defer u.lock.RUnlock()
 
 	if _, ok := u.users[userID]; ok {
 		return true, nil
@@ -91,57 +128,255 @@ func (u *UserFile) IsUser(ctx context.Context, userID string) (bool, error) {
 	return false, nil
 }
 
+// ListUsers returns every user, ordered by id
+func (u *UserFile) ListUsers(ctx context.Context) ([]User, error) {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	return u.listUsers(), nil
+}
+
+// listUsers returns every user, ordered by id. The lock has to be held.
+func (u *UserFile) listUsers() []User {
+	users := make([]User, 0, len(u.users))
+	for id, email := range u.users {
+		users = append(users, User{ID: id, Email: email, Settings: u.settings[id]})
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	return users
+}
+
+// ImportUser adds the user with its id and settings. Importing a user
+// that is already there only updates its settings, but it's an error
+// if either the id or the email belong to another user.
+func (u *UserFile) ImportUser(ctx context.Context, user User) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	id, emailFound := u.emails[user.Email]
+	email, idFound := u.users[user.ID]
+	if emailFound && idFound && id == user.ID && email == user.Email {
+		if u.settings[user.ID] == user.Settings {
+			return nil
+		}
+		return u.write(userEntry{Op: opSettings, UserID: user.ID, Settings: &user.Settings})
+	}
+	if emailFound || idFound {
+		return ErrUserAlreadyExists
+	}
+
+	return u.write(userEntry{Op: opCreate, UserID: user.ID, Email: user.Email, Settings: &user.Settings})
+}
+
+// GetSettings returns the settings of the user
+func (u *UserFile) GetSettings(ctx context.Context, userID string) (Settings, error) {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	if _, ok := u.users[userID]; !ok {
+		return Settings{}, ErrUserNotFound
+	}
+	return u.settings[userID], nil
+}
+
+// UpdateSettings replaces the settings of the user
+func (u *UserFile) UpdateSettings(ctx context.Context, userID string, settings Settings) error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if _, ok := u.users[userID]; !ok {
+		return ErrUserNotFound
+	}
+	return u.write(userEntry{Op: opSettings, UserID: userID, Settings: &settings})
+}
+
+// write journals the entry and applies it. The lock has to be held.
+func (u *UserFile) write(e userEntry) error {
+	if u.readOnly {
+		return ErrReadOnly
+	}
+
+	if err := u.journal.append(e); err != nil {
+		return err
+	}
+	u.apply(e)
+
+	return nil
+}
+
 // Start will start the background job that will periodically save
-// what's in memory
+// what's in memory. When ctx is cancelled a final save is performed
+// before returning. It returns nil once Close has been called.
 func (u *UserFile) Start(ctx context.Context) error {
+	if u.readOnly {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-u.done:
+			return nil
+		}
+	}
+
 	t := time.NewTicker(u.ticker)
 	defer t.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
+			if err := u.save(ctx); err != nil {
+				return err
+			}
 			return ctx.Err()
+		case <-u.done:
+			return nil
 		case <-t.C:
 		}
 
-		u.lock.Lock()
-		defer u.lock.Unlock()
-
-		if !u.dirty {
-			continue
+		if err := u.save(ctx); err != nil {
+			logging.Error(ctx, "cannot save user file", zap.Error(err))
 		}
+	}
+}
 
-		f, err := os.Create(u.filename)
-		if err != nil {
-			return ErrCannotOpenUserFile.Wrap(err)
-		}
-		defer func() {
-			if err := f.Close(); err != nil {
-				logging.Error(ctx, "cannot close user file", zap.Error(err))
-			}
-		}()
+// Flush saves any changes that haven't been saved yet
+func (u *UserFile) Flush(ctx context.Context) error {
+	return u.save(ctx)
+}
 
-		data := userData{
-			Emails: u.emails,
-			Users:  u.users,
-		}
+// Close stops the background job started by Start, saves any
+// changes that haven't been saved yet, closes the journal and
+// lets other processes open the file
+func (u *UserFile) Close(ctx context.Context) error {
+	u.closeOnce.Do(func() { close(u.done) })
+	if err := u.save(ctx); err != nil {
+		return err
+	}
 
-		bb, err := json.Marshal(&data)
-		if err != nil {
-			logging.Error(ctx, "cannot marshal user data", zap.Error(err))
-			continue
-		}
+	u.lock.Lock()
+	defer u.lock.Unlock()
 
-		if _, err := f.Write(bb); err != nil {
-			logging.Error(ctx, "cannot write user data", zap.Error(err))
-			continue
-		}
+	if u.flock == nil {
+		return nil
+	}
 
-		u.dirty = false
+	var err error
+	if u.journal != nil {
+		err = u.journal.close()
 	}
+	if lErr := u.flock.release(); err == nil {
+		err = lErr
+	}
+	u.flock = nil
+
+	return err
+}
+
+// save compacts the journal into a snapshot of the users. The snapshot
+// is written to a temp file that replaces the file on disk, so that a
+// crash mid save doesn't lose the users, and then the entries that are
+// in the snapshot are dropped from the journal. The lock is only held
+// while the users are copied, not while they're marshalled or written.
+func (u *UserFile) save(ctx context.Context) error {
+	if u.readOnly {
+		return nil
+	}
+
+	u.saveLock.Lock()
+	defer u.saveLock.Unlock()
+
+	u.lock.Lock()
+	if !u.dirty {
+		u.lock.Unlock()
+		return nil
+	}
+
+	data := userData{
+		Emails:   copyStrings(u.emails),
+		Users:    copyStrings(u.users),
+		Settings: make(map[string]Settings, len(u.settings)),
+	}
+	for k, v := range u.settings {
+		data.Settings[k] = v
+	}
+	offset := u.journal.size
+	u.dirty = false
+	u.lock.Unlock()
+
+	bb, err := userFormat.marshal(&data)
+	if err != nil {
+		u.setDirty()
+		return ErrCannotMarshallUserFile.Wrap(err)
+	}
+
+	if err := writeFileAtomic(u.filename, bb); err != nil {
+		u.setDirty()
+		return ErrCannotWriteUserFile.Wrap(err)
+	}
+
+	// Replaying the entries that are also in the snapshot is harmless,
+	// so a crash before the journal is compacted doesn't lose anything
+	u.lock.Lock()
+	err = u.journal.compact(offset)
+	u.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	logging.Info(ctx, "Saved user file", zap.String("filename", u.filename))
+
+	return nil
 }
 
+// setDirty makes the next save write the users, after one failed
+func (u *UserFile) setDirty() {
+	u.lock.Lock()
+	u.dirty = true
+	u.lock.Unlock()
+}
+
+// copyStrings returns a copy of m
+func copyStrings(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// load reads the snapshot and then replays the journal over it,
+// before opening the journal for new entries unless it's read only
 func (u *UserFile) load(ctx context.Context) error {
+	if err := u.loadSnapshot(ctx); err != nil {
+		return err
+	}
+
+	apply := func(entry []byte) error {
+		var e userEntry
+		if err := json.Unmarshal(entry, &e); err != nil {
+			return err
+		}
+		u.apply(e)
+		return nil
+	}
+
+	if u.readOnly {
+		_, err := replayJournal(journalFilename(u.filename), apply)
+		return err
+	}
+
+	j, err := openJournal(journalFilename(u.filename), apply)
+	if err != nil {
+		return err
+	}
+	u.journal = j
+
+	return nil
+}
+
+func (u *UserFile) loadSnapshot(ctx context.Context) error {
 	if _, err := os.Stat(u.filename); os.IsNotExist(err) {
 		return nil
 	}
@@ -162,18 +397,93 @@ func (u *UserFile) load(ctx context.Context) error {
 	}
 
 	var data userData
-	err = json.Unmarshal(bb, &data)
+	version, err := userFormat.unmarshal(bb, &data)
 	if err != nil {
 		return ErrCannotUnmarshallUserFile.Wrap(err)
 	}
+	if version < userFormat.version() {
+		logging.Info(ctx, "Upgraded user file", zap.String("filename", u.filename),
+			zap.Int("from", version), zap.Int("to", userFormat.version()))
+		// Saved in the current version on the next flush
+		u.dirty = true
+	}
 
-	u.emails = data.Emails
-	u.users = data.Users
+	if data.Emails != nil {
+		u.emails = data.Emails
+	}
+	if data.Users != nil {
+		u.users = data.Users
+	}
+	if data.Settings != nil {
+		u.settings = data.Settings
+	}
 
 	return nil
 }
 
+// apply makes the change in the entry to the users in memory
+func (u *UserFile) apply(e userEntry) {
+	switch e.Op {
+	case opCreate:
+		u.emails[e.Email] = e.UserID
+		u.users[e.UserID] = e.Email
+		if e.Settings != nil {
+			u.setSettings(e.UserID, *e.Settings)
+		}
+	case opSettings:
+		u.setSettings(e.UserID, *e.Settings)
+	case opDeleteEmail:
+		delete(u.emails, e.Email)
+	}
+	u.dirty = true
+}
+
+// setSettings keeps the settings of a user, unless they're the defaults
+func (u *UserFile) setSettings(userID string, settings Settings) {
+	if settings == (Settings{}) {
+		delete(u.settings, userID)
+		return
+	}
+	u.settings[userID] = settings
+}
+
+// User is a user and the email that they signed up with
+type User struct {
+	ID       string   `json:"id"`
+	Email    string   `json:"email"`
+	Settings Settings `json:"settings"`
+}
+
+// Settings are how a user wants their sources to be picked. The zero
+// value is the server's defaults.
+type Settings struct {
+	// Strategy is the name of the strategy that picks the user's
+	// sources when a pick doesn't name one
+	Strategy string `json:"strategy,omitempty"`
+	// Cooldown is how long a source isn't picked again for, unless
+	// there aren't enough others
+	Cooldown time.Duration `json:"cooldown,omitempty"`
+}
+
 type userData struct {
 	Emails map[string]string `json:"emails"`
 	Users  map[string]string `json:"users"`
+	// Settings were added in version 2
+	Settings map[string]Settings `json:"settings,omitempty"`
+}
+
+const (
+	opCreate = "create"
+	// opSettings replaces the settings of a user
+	opSettings = "settings"
+	// opDeleteEmail removes an email that points at the wrong user
+	opDeleteEmail = "delete_email"
+)
+
+// userEntry is a line in the user journal
+type userEntry struct {
+	Op       string    `json:"op"`
+	UserID   string    `json:"user_id"`
+	Email    string    `json:"email"`
+	Settings *Settings `json:"settings,omitempty"`
 }
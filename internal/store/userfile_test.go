@@ -2,7 +2,10 @@ package store_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -10,10 +13,11 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/ankur22/medium-picker/internal/store"
+	"github.com/ankur22/medium-picker/internal/store/storetest"
 )
 
 func TestNewUserFile_Success(t *testing.T) {
-	u, err := store.NewUserFile(context.Background(), "some-file.txt", time.Second)
+	u, err := store.NewUserFile(context.Background(), filepath.Join(t.TempDir(), "some-file.txt"), time.Second)
 	assert.NoError(t, err)
 	assert.NotNil(t, u)
 }
@@ -36,7 +40,7 @@ func TestUserFile_CreateNewUser_Success(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			u, err := store.NewUserFile(context.Background(), "some-file.txt", time.Second)
+			u, err := store.NewUserFile(context.Background(), filepath.Join(t.TempDir(), "some-file.txt"), time.Second)
 			require.NoError(t, err)
 
 			uid, err := u.CreateNewUser(context.Background(), tt.args.email)
@@ -64,7 +68,7 @@ func TestUserFile_GetUser_Success(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			u, err := store.NewUserFile(context.Background(), "some-file.txt", time.Second)
+			u, err := store.NewUserFile(context.Background(), filepath.Join(t.TempDir(), "some-file.txt"), time.Second)
 			require.NoError(t, err)
 
 			uid, err := u.CreateNewUser(context.Background(), tt.args.email)
@@ -98,7 +102,7 @@ func TestUserFile_GetUser_Failure(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			u, err := store.NewUserFile(context.Background(), "some-file.txt", time.Second)
+			u, err := store.NewUserFile(context.Background(), filepath.Join(t.TempDir(), "some-file.txt"), time.Second)
 			require.NoError(t, err)
 
 			uid, err := u.GetUser(context.Background(), tt.args.email)
@@ -135,7 +139,7 @@ func TestUserFile_IsUser_Success(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			u, err := store.NewUserFile(context.Background(), "some-file.txt", time.Second)
+			u, err := store.NewUserFile(context.Background(), filepath.Join(t.TempDir(), "some-file.txt"), time.Second)
 			require.NoError(t, err)
 
 			var uid string
@@ -151,3 +155,375 @@ func TestUserFile_IsUser_Success(t *testing.T) {
 		})
 	}
 }
+
+func TestUserFile_Flush(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "users.json")
+
+	u, err := store.NewUserFile(ctx, filename, time.Hour)
+	require.NoError(t, err)
+
+	uid, err := u.CreateNewUser(ctx, "test@example.com")
+	require.NoError(t, err)
+
+	err = u.Flush(ctx)
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filename + ".tmp-*")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	u.Crash()
+	reopened, err := store.NewUserFile(ctx, filename, time.Hour)
+	require.NoError(t, err)
+
+	got, err := reopened.GetUser(ctx, "test@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, uid, got)
+}
+
+func TestUserFile_Start(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(ctx context.Context, cancel context.CancelFunc, u *store.UserFile) error
+		want error
+	}{
+		{
+			name: "final save when ctx is cancelled",
+			stop: func(ctx context.Context, cancel context.CancelFunc, u *store.UserFile) error {
+				cancel()
+				return nil
+			},
+			want: context.Canceled,
+		},
+		{
+			name: "final save on close",
+			stop: func(ctx context.Context, cancel context.CancelFunc, u *store.UserFile) error {
+				return u.Close(ctx)
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			filename := filepath.Join(t.TempDir(), "users.json")
+
+			u, err := store.NewUserFile(ctx, filename, time.Hour)
+			require.NoError(t, err)
+
+			done := make(chan error)
+			go func() {
+				done <- u.Start(ctx)
+			}()
+
+			uid, err := u.CreateNewUser(ctx, "test@example.com")
+			require.NoError(t, err)
+
+			err = tt.stop(ctx, cancel, u)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, <-done)
+
+			u.Crash()
+			reopened, err := store.NewUserFile(context.Background(), filename, time.Hour)
+			require.NoError(t, err)
+
+			got, err := reopened.GetUser(context.Background(), "test@example.com")
+			assert.NoError(t, err)
+			assert.Equal(t, uid, got)
+		})
+	}
+}
+
+func TestUserFile_Flush_Failure(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "users.json")
+
+	u, err := store.NewUserFile(ctx, filename, time.Hour)
+	require.NoError(t, err)
+
+	_, err = u.CreateNewUser(ctx, "test@example.com")
+	require.NoError(t, err)
+
+	// A dir can't be replaced by the snapshot
+	err = os.Mkdir(filename, 0o755)
+	require.NoError(t, err)
+
+	err = u.Flush(ctx)
+	assert.True(t, errors.Is(err, store.ErrCannotWriteUserFile))
+
+	// The changes are kept so that the next flush tries again
+	err = os.Remove(filename)
+	require.NoError(t, err)
+
+	err = u.Flush(ctx)
+	assert.NoError(t, err)
+	assert.FileExists(t, filename)
+}
+
+func TestUserFile_Journal(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "users.json")
+
+	u, err := store.NewUserFile(ctx, filename, time.Hour)
+	require.NoError(t, err)
+
+	uid, err := u.CreateNewUser(ctx, "test@example.com")
+	require.NoError(t, err)
+
+	// The user is in the journal without waiting for a flush
+	assert.NoFileExists(t, filename)
+
+	u.Crash()
+	reopened, err := store.NewUserFile(ctx, filename, time.Hour)
+	require.NoError(t, err)
+
+	got, err := reopened.GetUser(ctx, "test@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, uid, got)
+
+	// Compaction moves the journal into the snapshot
+	err = reopened.Close(ctx)
+	require.NoError(t, err)
+
+	info, err := os.Stat(filename + ".journal")
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	reopened, err = store.NewUserFile(ctx, filename, time.Hour)
+	require.NoError(t, err)
+
+	got, err = reopened.GetUser(ctx, "test@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, uid, got)
+}
+
+func TestUserFile_Journal_Replay(t *testing.T) {
+	tests := []struct {
+		name    string
+		journal string
+		want    error
+		users   []string
+	}{
+		{
+			name:    "torn final entry is dropped",
+			journal: `{"op":"create","user_id":"1","email":"a@example.com"}` + "\n" + `{"op":"create","user_id":"2","em`,
+			users:   []string{"a@example.com"},
+		},
+		{
+			name:    "corrupt entry",
+			journal: `{"op":"create","user_id":"1","email":"a@example.com"}` + "\n" + `not json` + "\n",
+			want:    store.ErrCannotReplayJournal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			filename := filepath.Join(t.TempDir(), "users.json")
+
+			err := os.WriteFile(filename+".journal", []byte(tt.journal), 0o644)
+			require.NoError(t, err)
+
+			u, err := store.NewUserFile(ctx, filename, time.Hour)
+			if tt.want != nil {
+				assert.True(t, errors.Is(err, tt.want))
+				return
+			}
+			require.NoError(t, err)
+
+			for _, email := range tt.users {
+				_, err := u.GetUser(ctx, email)
+				assert.NoError(t, err)
+			}
+
+			// New entries are appended after the last complete entry
+			_, err = u.CreateNewUser(ctx, "b@example.com")
+			require.NoError(t, err)
+
+			u.Crash()
+			reopened, err := store.NewUserFile(ctx, filename, time.Hour)
+			require.NoError(t, err)
+
+			_, err = reopened.GetUser(ctx, "b@example.com")
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestUserFile_Lock(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+		open     func(ctx context.Context, filename string) (*store.UserFile, error)
+		want     error
+	}{
+		{
+			name: "second open fails",
+			open: func(ctx context.Context, filename string) (*store.UserFile, error) {
+				return store.NewUserFile(ctx, filename, time.Hour)
+			},
+			want: store.ErrFileLocked,
+		},
+		{
+			name: "read only open fails while it's open for writing",
+			open: func(ctx context.Context, filename string) (*store.UserFile, error) {
+				return store.OpenUserFileReadOnly(ctx, filename)
+			},
+			want: store.ErrFileLocked,
+		},
+		{
+			name:     "open fails while it's open read only",
+			readOnly: true,
+			open: func(ctx context.Context, filename string) (*store.UserFile, error) {
+				return store.NewUserFile(ctx, filename, time.Hour)
+			},
+			want: store.ErrFileLocked,
+		},
+		{
+			name:     "read only opens share it",
+			readOnly: true,
+			open: func(ctx context.Context, filename string) (*store.UserFile, error) {
+				return store.OpenUserFileReadOnly(ctx, filename)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			filename := filepath.Join(t.TempDir(), "users.json")
+
+			var u *store.UserFile
+			var err error
+			if tt.readOnly {
+				u, err = store.OpenUserFileReadOnly(ctx, filename)
+			} else {
+				u, err = store.NewUserFile(ctx, filename, time.Hour)
+			}
+			require.NoError(t, err)
+
+			other, err := tt.open(ctx, filename)
+			if tt.want != nil {
+				assert.True(t, errors.Is(err, tt.want), err)
+			} else {
+				require.NoError(t, err)
+				require.NoError(t, other.Close(ctx))
+			}
+
+			// It can be opened once it's closed
+			err = u.Close(ctx)
+			require.NoError(t, err)
+
+			u, err = store.NewUserFile(ctx, filename, time.Hour)
+			require.NoError(t, err)
+			assert.NoError(t, u.Close(ctx))
+		})
+	}
+}
+
+func TestUserFile_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "users.json")
+
+	u, err := store.NewUserFile(ctx, filename, time.Hour)
+	require.NoError(t, err)
+
+	uid, err := u.CreateNewUser(ctx, "test@example.com")
+	require.NoError(t, err)
+	u.Crash()
+
+	ro, err := store.OpenUserFileReadOnly(ctx, filename)
+	require.NoError(t, err)
+
+	// The journal is replayed
+	got, err := ro.GetUser(ctx, "test@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, uid, got)
+
+	_, err = ro.CreateNewUser(ctx, "other@example.com")
+	assert.True(t, errors.Is(err, store.ErrReadOnly), err)
+	err = ro.ImportUser(ctx, store.User{ID: "some-id", Email: "other@example.com"})
+	assert.True(t, errors.Is(err, store.ErrReadOnly), err)
+
+	// Nothing is saved, so the journal is left for the next writer
+	err = ro.Close(ctx)
+	require.NoError(t, err)
+	assert.NoFileExists(t, filename)
+	info, err := os.Stat(filename + ".journal")
+	require.NoError(t, err)
+	assert.NotZero(t, info.Size())
+}
+
+func TestUserFile_Version(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string
+		wantSettings store.Settings
+		wantErr      error
+	}{
+		{
+			name: "Unversioned file is upgraded",
+			file: `{"emails":{"test@example.com":"some-user-id"},"users":{"some-user-id":"test@example.com"}}`,
+		},
+		{
+			name: "Version 1 is upgraded",
+			file: `{"version":1,"data":{"emails":{"test@example.com":"some-user-id"},"users":{"some-user-id":"test@example.com"}}}`,
+		},
+		{
+			name:         "Current version",
+			file:         `{"version":2,"data":{"emails":{"test@example.com":"some-user-id"},"users":{"some-user-id":"test@example.com"},"settings":{"some-user-id":{"strategy":"hits"}}}}`,
+			wantSettings: store.Settings{Strategy: "hits"},
+		},
+		{
+			name:    "Newer version is refused",
+			file:    `{"version":3,"data":{"emails":{},"users":{}}}`,
+			wantErr: store.ErrUnsupportedFileVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			filename := filepath.Join(t.TempDir(), "some-file.txt")
+			require.NoError(t, os.WriteFile(filename, []byte(tt.file), 0600))
+
+			u, err := store.NewUserFile(ctx, filename, time.Second)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), err)
+				return
+			}
+			require.NoError(t, err)
+
+			uid, err := u.GetUser(ctx, "test@example.com")
+			assert.NoError(t, err)
+			assert.Equal(t, "some-user-id", uid)
+
+			settings, err := u.GetSettings(ctx, uid)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSettings, settings)
+
+			// The file is always saved in the current version
+			require.NoError(t, u.Close(ctx))
+			bb, err := os.ReadFile(filename)
+			require.NoError(t, err)
+			var e struct {
+				Version int             `json:"version"`
+				Data    json.RawMessage `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(bb, &e))
+			assert.Equal(t, 2, e.Version)
+			assert.Contains(t, string(e.Data), `"test@example.com":"some-user-id"`)
+		})
+	}
+}
+
+func TestUserFile_Conformance(t *testing.T) {
+	storetest.RunUserStorerSuite(t, func(t *testing.T) func() storetest.UserStorer {
+		filename := filepath.Join(t.TempDir(), "users.json")
+		return func() storetest.UserStorer {
+			u, err := store.NewUserFile(context.Background(), filename, time.Hour)
+			require.NoError(t, err)
+			return u
+		}
+	})
+}
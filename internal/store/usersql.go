@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// userSQL stores the user information in a database through database/sql.
+// The statements are written so that they work with every dialect.
+type userSQL struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// CreateNewUser will create a new user that is needed to
+// add medium sources. It returns the uuid of the new user.
+func (u *userSQL) CreateNewUser(ctx context.Context, email string) (string, error) {
+	id := uuid.New().String()
+	now := time.Now().UTC()
+
+	_, err := u.db.ExecContext(ctx,
+		`INSERT INTO users (user_id, email, created_date, modified_date) VALUES ($1, $2, $3, $4)`,
+		id, email, now, now)
+	if u.dialect.isUniqueViolation(err) {
+		return "", ErrUserAlreadyExists
+	}
+	if err != nil {
+		return "", u.dialect.errQuery.Wrap(err)
+	}
+
+	return id, nil
+}
+
+// GetUser will retrieve the user details. It will return
+// the uuid of the user.
+func (u *userSQL) GetUser(ctx context.Context, email string) (string, error) {
+	var id string
+	err := u.db.QueryRowContext(ctx, `SELECT user_id FROM users WHERE email = $1`, email).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", u.dialect.errQuery.Wrap(err)
+	}
+
+	return id, nil
+}
+
+// IsUser checks whether the given userID is valid
+func (u *userSQL) IsUser(ctx context.Context, userID string) (bool, error) {
+	var ok bool
+	err := u.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE user_id = $1)`, userID).Scan(&ok)
+	if err != nil {
+		return false, u.dialect.errQuery.Wrap(err)
+	}
+
+	return ok, nil
+}
+
+// ListUsers returns every user, ordered by id
+func (u *userSQL) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := u.db.QueryContext(ctx, `SELECT user_id, email, pick_strategy, pick_cooldown FROM users ORDER BY user_id`)
+	if err != nil {
+		return nil, u.dialect.errQuery.Wrap(err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var v User
+		var cooldown int64
+		if err := rows.Scan(&v.ID, &v.Email, &v.Settings.Strategy, &cooldown); err != nil {
+			return nil, u.dialect.errQuery.Wrap(err)
+		}
+		v.Settings.Cooldown = time.Duration(cooldown)
+		users = append(users, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, u.dialect.errQuery.Wrap(err)
+	}
+
+	return users, nil
+}
+
+// ImportUser adds the user with its id and settings. Importing a user
+// that is already there only updates its settings, but it's an error
+// if either the id or the email belong to another user.
+func (u *userSQL) ImportUser(ctx context.Context, user User) error {
+	now := time.Now().UTC()
+
+	_, err := u.db.ExecContext(ctx,
+		`INSERT INTO users (user_id, email, created_date, modified_date, pick_strategy, pick_cooldown)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		user.ID, user.Email, now, now, user.Settings.Strategy, int64(user.Settings.Cooldown))
+	if u.dialect.isUniqueViolation(err) {
+		id, gErr := u.GetUser(ctx, user.Email)
+		if gErr == nil && id == user.ID {
+			return u.UpdateSettings(ctx, user.ID, user.Settings)
+		}
+		return ErrUserAlreadyExists
+	}
+	if err != nil {
+		return u.dialect.errQuery.Wrap(err)
+	}
+
+	return nil
+}
+
+// GetSettings returns the settings of the user
+func (u *userSQL) GetSettings(ctx context.Context, userID string) (Settings, error) {
+	var v Settings
+	var cooldown int64
+	err := u.db.QueryRowContext(ctx, `SELECT pick_strategy, pick_cooldown FROM users WHERE user_id = $1`, userID).
+		Scan(&v.Strategy, &cooldown)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Settings{}, ErrUserNotFound
+	}
+	if err != nil {
+		return Settings{}, u.dialect.errQuery.Wrap(err)
+	}
+	v.Cooldown = time.Duration(cooldown)
+
+	return v, nil
+}
+
+// UpdateSettings replaces the settings of the user
+func (u *userSQL) UpdateSettings(ctx context.Context, userID string, settings Settings) error {
+	res, err := u.db.ExecContext(ctx,
+		`UPDATE users SET pick_strategy = $2, pick_cooldown = $3, modified_date = $4 WHERE user_id = $1`,
+		userID, settings.Strategy, int64(settings.Cooldown), time.Now().UTC())
+	if err != nil {
+		return u.dialect.errQuery.Wrap(err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return u.dialect.errQuery.Wrap(err)
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
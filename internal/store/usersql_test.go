@@ -0,0 +1,113 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankur22/medium-picker/internal/store"
+)
+
+func TestUserSQL_CreateNewUser_Success(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			u := b.newUser(t)
+
+			uid, err := u.CreateNewUser(ctx, "test@example.com")
+			assert.NoError(t, err)
+			assert.NotEmpty(t, uid)
+		})
+	}
+}
+
+func TestUserSQL_CreateNewUser_Failure(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			u := b.newUser(t)
+
+			_, err := u.CreateNewUser(ctx, "test@example.com")
+			require.NoError(t, err)
+
+			uid, err := u.CreateNewUser(ctx, "test@example.com")
+			assert.Empty(t, uid)
+			assert.True(t, errors.Is(err, store.ErrUserAlreadyExists))
+		})
+	}
+}
+
+func TestUserSQL_GetUser_Success(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			u := b.newUser(t)
+
+			want, err := u.CreateNewUser(ctx, "test@example.com")
+			require.NoError(t, err)
+
+			got, err := u.GetUser(ctx, "test@example.com")
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestUserSQL_GetUser_Failure(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			u := b.newUser(t)
+
+			uid, err := u.GetUser(ctx, "test@example.com")
+			assert.Empty(t, uid)
+			assert.True(t, errors.Is(err, store.ErrUserNotFound))
+		})
+	}
+}
+
+func TestUserSQL_IsUser_Success(t *testing.T) {
+	for _, b := range sqlBackends {
+		t.Run(b.name, func(t *testing.T) {
+			tests := []struct {
+				name string
+				want bool
+			}{
+				{
+					name: "User exists",
+					want: true,
+				},
+				{
+					name: "User doesn't exist",
+					want: false,
+				},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					ctx := context.Background()
+
+					u := b.newUser(t)
+
+					uid := "not-a-uuid"
+					if tt.want {
+						var err error
+						uid, err = u.CreateNewUser(ctx, "test@example.com")
+						require.NoError(t, err)
+					}
+
+					got, err := u.IsUser(ctx, uid)
+					assert.NoError(t, err)
+					assert.Equal(t, tt.want, got)
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ankur22/medium-picker/internal/err"
+)
+
+const (
+	ErrUnsupportedFileVersion = err.Const("file was written by a newer version")
+	ErrCannotUpgradeFile      = err.Const("cannot upgrade file")
+)
+
+// upgrade turns the data of a snapshot from one version
+// of its format into the next version
+type upgrade func(data json.RawMessage) (json.RawMessage, error)
+
+// fileFormat is the versioned format of a file store snapshot. The
+// snapshot is written in an envelope with the version, e.g.
+//
+//	{"version":1,"data":{...}}
+//
+// Files from before the envelope are version 0 and are the bare data.
+//
+// The journal isn't versioned. It's compacted into the snapshot on
+// every flush, so only a crash leaves entries behind, and a field
+// that's added to Medium is just zero in older entries.
+type fileFormat struct {
+	// upgrades[i] upgrades version i to version i+1, so the
+	// current version is the number of upgrades. Changing how
+	// the data is encoded means appending an upgrade.
+	upgrades []upgrade
+}
+
+// envelope is what a snapshot is written in
+type envelope struct {
+	Version *int            `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// version is the version that snapshots are written in
+func (f fileFormat) version() int {
+	return len(f.upgrades)
+}
+
+// marshal writes v in an envelope with the current version
+func (f fileFormat) marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	version := f.version()
+	return json.Marshal(envelope{Version: &version, Data: data})
+}
+
+// unmarshal reads a snapshot of any version up to the current one into v,
+// upgrading it on the way. It returns the version that it was written in.
+func (f fileFormat) unmarshal(bb []byte, v interface{}) (int, error) {
+	var e envelope
+	if err := json.Unmarshal(bb, &e); err != nil {
+		return 0, err
+	}
+
+	version, data := 0, json.RawMessage(bb)
+	if e.Version != nil {
+		version, data = *e.Version, e.Data
+	}
+
+	if version > f.version() {
+		return version, ErrUnsupportedFileVersion.Wrap(fmt.Errorf("version %d, this build reads up to version %d", version, f.version()))
+	}
+
+	for i := version; i < f.version(); i++ {
+		var err error
+		data, err = f.upgrades[i](data)
+		if err != nil {
+			return version, ErrCannotUpgradeFile.Wrap(fmt.Errorf("from version %d: %w", i, err))
+		}
+	}
+
+	return version, json.Unmarshal(data, v)
+}
+
+// unwrapped is the upgrade from version 0, when the data was written
+// without an envelope, to version 1. The data itself didn't change.
+func unwrapped(data json.RawMessage) (json.RawMessage, error) {
+	return data, nil
+}
+
+// multiplierVersion is the version that defaultMultiplier upgrades to
+const multiplierVersion = 2
+
+// defaultMultiplier is the upgrade from version 1 to version 2, when
+// sources started to be picked in proportion to their multiplier. Every
+// source was added with a multiplier of 0, which is now 1.
+func defaultMultiplier(data json.RawMessage) (json.RawMessage, error) {
+	// The fields are kept as they are, as decoding the simhash
+	// into an interface{} would round it to a float64
+	var sources map[string]map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, err
+	}
+
+	for _, val := range sources {
+		for _, v := range val {
+			var m float64
+			if raw, ok := v["multiplier"]; ok {
+				if err := json.Unmarshal(raw, &m); err != nil {
+					return nil, err
+				}
+			}
+			if m == 0 {
+				v["multiplier"] = json.RawMessage("1")
+			}
+		}
+	}
+
+	return json.Marshal(sources)
+}
+
+// dropLastPickedHash is the upgrade from version 2 to version 3, when
+// sources stopped keeping their hash from when they were last picked.
+// Whether they have changed since is told from when they last changed.
+func dropLastPickedHash(data json.RawMessage) (json.RawMessage, error) {
+	var sources map[string]map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, err
+	}
+
+	for _, val := range sources {
+		for _, v := range val {
+			delete(v, "last_picked_hash")
+		}
+	}
+
+	return json.Marshal(sources)
+}
+
+// feedbackCounts is the upgrade from version 3 to version 4, when
+// sources started to count the feedback on them. The files from before
+// then just don't have any, so the data doesn't change.
+func feedbackCounts(data json.RawMessage) (json.RawMessage, error) {
+	return data, nil
+}
+
+// userSettings is the upgrade from version 1 to version 2, when users
+// got settings. The files from before them just don't have any, so the
+// data doesn't change, but a build that doesn't know about settings
+// refuses the file rather than dropping them on its next save.
+func userSettings(data json.RawMessage) (json.RawMessage, error) {
+	return data, nil
+}
+
+// userFormat is the format of the users.json snapshot
+var userFormat = fileFormat{
+	upgrades: []upgrade{
+		unwrapped,
+		userSettings,
+	},
+}
+
+// mediumFormat is the format of the mediums.json snapshot
+var mediumFormat = fileFormat{
+	upgrades: []upgrade{
+		unwrapped,
+		defaultMultiplier,
+		dropLastPickedHash,
+		feedbackCounts,
+	},
+}
@@ -20,7 +20,24 @@ type NewMediumSourceRequest struct {
 	Source string `json:"source"`
 }
 
+type FeedbackRequest struct {
+	Signal string `json:"signal"`
+}
+
 type Source struct {
-	URL string `json:"url"`
-	ID  string `json:"id"`
+	URL              string `json:"url"`
+	ID               string `json:"id"`
+	ItemTitle        string `json:"itemTitle,omitempty"`
+	ItemLink         string `json:"itemLink,omitempty"`
+	NewSinceLastRead bool   `json:"newSinceLastRead,omitempty"`
+}
+
+type GetMediumSourcesResponse struct {
+	Sources  []Source `json:"sources"`
+	NextPage string   `json:"nextPage,omitempty"`
+}
+
+type Settings struct {
+	Strategy string `json:"strategy"`
+	Cooldown string `json:"cooldown,omitempty"`
 }